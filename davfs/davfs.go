@@ -0,0 +1,92 @@
+// Package davfs exposes an fs.FS as a read-only WebDAV file system.
+//
+// Usage:
+//
+//	http.Handle("/docs/", davfs.Handler("/docs", assets))
+//	log.Fatal(http.ListenAndServe("localhost:http", nil))
+package davfs
+
+import (
+	"context"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+
+	"golang.org/x/net/webdav"
+)
+
+// FileSystem adapts an fs.FS to webdav.FileSystem.
+// All modifications fail with fs.ErrPermission.
+type FileSystem struct {
+	fsys http.FileSystem
+}
+
+// New creates a read-only webdav.FileSystem serving the contents of fsys.
+func New(fsys fs.FS) *FileSystem {
+	return &FileSystem{http.FS(fsys)}
+}
+
+// Handler returns an http.Handler serving fsys over WebDAV.
+// The prefix is stripped from request paths, as in webdav.Handler.
+func Handler(prefix string, fsys fs.FS) http.Handler {
+	return &webdav.Handler{
+		Prefix:     prefix,
+		FileSystem: New(fsys),
+		LockSystem: webdav.NewMemLS(),
+	}
+}
+
+// OpenFile implements webdav.FileSystem, opening files for reading.
+func (fsys *FileSystem) OpenFile(ctx context.Context, name string, flag int, perm os.FileMode) (webdav.File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_APPEND|os.O_CREATE|os.O_TRUNC) != 0 {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrPermission}
+	}
+	f, err := fsys.open(name)
+	if err != nil {
+		return nil, err
+	}
+	return file{f}, nil
+}
+
+// Stat implements webdav.FileSystem, returning a fs.FileInfo that describes the file.
+func (fsys *FileSystem) Stat(ctx context.Context, name string) (os.FileInfo, error) {
+	f, err := fsys.open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return f.Stat()
+}
+
+// Mkdir implements webdav.FileSystem. It always fails.
+func (fsys *FileSystem) Mkdir(ctx context.Context, name string, perm os.FileMode) error {
+	return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrPermission}
+}
+
+// RemoveAll implements webdav.FileSystem. It always fails.
+func (fsys *FileSystem) RemoveAll(ctx context.Context, name string) error {
+	return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrPermission}
+}
+
+// Rename implements webdav.FileSystem. It always fails.
+func (fsys *FileSystem) Rename(ctx context.Context, oldName, newName string) error {
+	return &os.LinkError{Op: "rename", Old: oldName, New: newName, Err: fs.ErrPermission}
+}
+
+func (fsys *FileSystem) open(name string) (http.File, error) {
+	// webdav names may have trailing slashes, http.FS expects clean names
+	return fsys.fsys.Open(path.Clean("/" + name))
+}
+
+type file struct {
+	http.File
+}
+
+func (f file) Write(p []byte) (int, error) {
+	return 0, fs.ErrPermission
+}
+
+// Check interface implementations
+var _ webdav.FileSystem = &FileSystem{}
+var _ webdav.File = file{}
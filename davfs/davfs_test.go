@@ -0,0 +1,65 @@
+package davfs_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ncruces/go-fs/davfs"
+	"github.com/ncruces/go-fs/memfs"
+)
+
+func TestHandler(t *testing.T) {
+	fsys := memfs.Create()
+	if err := fsys.Create("dir/hi.txt", "text/plain", time.Now(), strings.NewReader("Hello, world!")); err != nil {
+		t.Fatal(err)
+	}
+
+	handler := davfs.Handler("/dav", fsys)
+
+	tests := []struct {
+		method string
+		path   string
+		status int
+	}{
+		{"GET", "/dav/dir/hi.txt", http.StatusOK},
+		{"GET", "/dav/missing.txt", http.StatusNotFound},
+		{"PROPFIND", "/dav/dir/", http.StatusMultiStatus},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(tt.method, tt.path, nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != tt.status {
+			t.Errorf("%s %s: got %d, want %d", tt.method, tt.path, w.Code, tt.status)
+		}
+	}
+}
+
+func TestHandler_readOnly(t *testing.T) {
+	fsys := memfs.Create()
+	if err := fsys.Create("hi.txt", "text/plain", time.Now(), strings.NewReader("Hello, world!")); err != nil {
+		t.Fatal(err)
+	}
+
+	handler := davfs.Handler("", fsys)
+
+	for _, method := range []string{"PUT", "DELETE", "MKCOL", "MOVE", "COPY"} {
+		r := httptest.NewRequest(method, "/hi.txt", nil)
+		r.Header.Set("Destination", "/copy.txt")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code < 400 {
+			t.Errorf("%s: got %d, want an error", method, w.Code)
+		}
+	}
+
+	if data, err := fsys.ReadFile("hi.txt"); err != nil || string(data) != "Hello, world!" {
+		t.Errorf("hi.txt modified: %q, %v", data, err)
+	}
+	if _, err := fsys.Stat("copy.txt"); err == nil {
+		t.Error("copy.txt created")
+	}
+}
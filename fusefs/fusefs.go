@@ -0,0 +1,16 @@
+// Package fusefs mounts an fs.FS as a read-only FUSE file system.
+//
+// This is mostly useful for debugging, e.g. to inspect exactly what was
+// generated or embedded, with standard tools.
+//
+// FUSE support is optional, and requires building with the fuse tag:
+//
+//	go build -tags fuse
+//
+// Without it, Mount always fails with ErrUnsupported.
+package fusefs
+
+import "errors"
+
+// ErrUnsupported is returned by Mount when FUSE support is not compiled in.
+var ErrUnsupported = errors.New("fusefs: build with the fuse tag to enable FUSE support")
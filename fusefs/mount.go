@@ -0,0 +1,169 @@
+//go:build fuse
+
+package fusefs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"sync"
+	"syscall"
+
+	fusefs "github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+// Server is a mounted file system.
+type Server struct {
+	server *fuse.Server
+}
+
+// Mount mounts fsys read-only at dir.
+// The file system is served in the background until unmounted.
+func Mount(dir string, fsys fs.FS) (*Server, error) {
+	root := &node{fsys: fsys, path: "."}
+	server, err := fusefs.Mount(dir, root, &fusefs.Options{
+		MountOptions: fuse.MountOptions{
+			FsName: "memfs",
+			Name:   "memfs",
+
+			Options: []string{"ro"},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Server{server}, nil
+}
+
+// Wait waits for the file system to be unmounted.
+func (s *Server) Wait() {
+	s.server.Wait()
+}
+
+// Unmount unmounts the file system.
+func (s *Server) Unmount() error {
+	return s.server.Unmount()
+}
+
+type node struct {
+	fusefs.Inode
+	fsys fs.FS
+	path string
+}
+
+func (n *node) Getattr(ctx context.Context, f fusefs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	info, err := fs.Stat(n.fsys, n.path)
+	if err != nil {
+		return errno(err)
+	}
+	setAttr(&out.Attr, info)
+	return 0
+}
+
+func (n *node) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fusefs.Inode, syscall.Errno) {
+	name = path.Join(n.path, name)
+	info, err := fs.Stat(n.fsys, name)
+	if err != nil {
+		return nil, errno(err)
+	}
+	setAttr(&out.Attr, info)
+	child := &node{fsys: n.fsys, path: name}
+	return n.NewInode(ctx, child, fusefs.StableAttr{Mode: out.Mode & syscall.S_IFMT}), 0
+}
+
+func (n *node) Readdir(ctx context.Context) (fusefs.DirStream, syscall.Errno) {
+	entries, err := fs.ReadDir(n.fsys, n.path)
+	if err != nil {
+		return nil, errno(err)
+	}
+	list := make([]fuse.DirEntry, len(entries))
+	for i, e := range entries {
+		list[i].Name = e.Name()
+		list[i].Mode = fuse.S_IFREG
+		if e.IsDir() {
+			list[i].Mode = fuse.S_IFDIR
+		}
+	}
+	return fusefs.NewListDirStream(list), 0
+}
+
+func (n *node) Open(ctx context.Context, flags uint32) (fusefs.FileHandle, uint32, syscall.Errno) {
+	if flags&(syscall.O_WRONLY|syscall.O_RDWR|syscall.O_APPEND|syscall.O_TRUNC) != 0 {
+		return nil, 0, syscall.EROFS
+	}
+	f, err := n.fsys.Open(n.path)
+	if err != nil {
+		return nil, 0, errno(err)
+	}
+	return &handle{file: f}, fuse.FOPEN_KEEP_CACHE, 0
+}
+
+type handle struct {
+	mtx  sync.Mutex
+	file fs.File
+}
+
+func (h *handle) Read(ctx context.Context, dest []byte, off int64) (fuse.ReadResult, syscall.Errno) {
+	var n int
+	var err error
+	if r, ok := h.file.(io.ReaderAt); ok {
+		n, err = r.ReadAt(dest, off)
+	} else if s, ok := h.file.(io.Seeker); ok {
+		h.mtx.Lock()
+		defer h.mtx.Unlock()
+		if _, err = s.Seek(off, io.SeekStart); err == nil {
+			n, err = io.ReadFull(h.file, dest)
+		}
+	} else {
+		return nil, syscall.ESPIPE
+	}
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, errno(err)
+	}
+	return fuse.ReadResultData(dest[:n]), 0
+}
+
+func (h *handle) Release(ctx context.Context) syscall.Errno {
+	return errno(h.file.Close())
+}
+
+func setAttr(out *fuse.Attr, info fs.FileInfo) {
+	mode := uint32(info.Mode().Perm())
+	if info.IsDir() {
+		mode |= syscall.S_IFDIR
+	} else {
+		mode |= syscall.S_IFREG
+	}
+	out.Mode = mode
+	out.Size = uint64(info.Size())
+	out.Nlink = 1
+	mtime := info.ModTime()
+	out.SetTimes(nil, &mtime, &mtime)
+}
+
+func errno(err error) syscall.Errno {
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, fs.ErrNotExist):
+		return syscall.ENOENT
+	case errors.Is(err, fs.ErrPermission):
+		return syscall.EACCES
+	case errors.Is(err, fs.ErrInvalid):
+		return syscall.EINVAL
+	case errors.Is(err, fs.ErrClosed):
+		return syscall.EBADF
+	}
+	return syscall.EIO
+}
+
+// Check interface implementations
+var _ fusefs.NodeGetattrer = &node{}
+var _ fusefs.NodeLookuper = &node{}
+var _ fusefs.NodeReaddirer = &node{}
+var _ fusefs.NodeOpener = &node{}
+var _ fusefs.FileReader = &handle{}
+var _ fusefs.FileReleaser = &handle{}
@@ -0,0 +1,35 @@
+//go:build fuse
+
+package fusefs_test
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/ncruces/go-fs/fusefs"
+	"github.com/ncruces/go-fs/memfs"
+)
+
+func TestMount(t *testing.T) {
+	fsys := memfs.Create()
+	if err := fsys.Create("dir/hi.txt", "text/plain", time.Now(), strings.NewReader("Hello, world!")); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	server, err := fusefs.Mount(dir, fsys)
+	if err != nil {
+		t.Skip(err)
+	}
+	defer server.Unmount()
+
+	if err := fstest.TestFS(os.DirFS(dir), "dir/hi.txt"); err != nil {
+		t.Error(err)
+	}
+	if err := os.WriteFile(dir+"/dir/hi.txt", nil, 0644); err == nil {
+		t.Error("want error writing to a read-only mount")
+	}
+}
@@ -0,0 +1,21 @@
+//go:build !fuse
+
+package fusefs
+
+import "io/fs"
+
+// Server is a mounted file system.
+type Server struct{}
+
+// Mount mounts fsys read-only at dir.
+func Mount(dir string, fsys fs.FS) (*Server, error) {
+	return nil, ErrUnsupported
+}
+
+// Wait waits for the file system to be unmounted.
+func (s *Server) Wait() {}
+
+// Unmount unmounts the file system.
+func (s *Server) Unmount() error {
+	return ErrUnsupported
+}
@@ -3,12 +3,15 @@ module github.com/ncruces/go-fs
 go 1.20
 
 require (
+	github.com/fsnotify/fsnotify v1.8.0
 	github.com/gabriel-vasile/mimetype v1.4.7
+	github.com/hanwen/go-fuse/v2 v2.6.3
 	github.com/tdewolff/minify/v2 v2.21.2
 	golang.org/x/net v0.33.0
+	golang.org/x/text v0.21.0
 )
 
 require (
 	github.com/tdewolff/parse/v2 v2.7.19 // indirect
-	golang.org/x/text v0.21.0 // indirect
+	golang.org/x/sys v0.28.0 // indirect
 )
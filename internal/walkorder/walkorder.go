@@ -0,0 +1,21 @@
+// Package walkorder compares names in fs.WalkDir order,
+// the order memfs expects generated files in.
+package walkorder
+
+import "strings"
+
+// Less reports if name a comes before b, in fs.WalkDir order:
+// a directory before its contents, and siblings in lexical order.
+func Less(a, b string) bool {
+	for {
+		ea, ra, moreA := strings.Cut(a, "/")
+		eb, rb, moreB := strings.Cut(b, "/")
+		switch {
+		case ea != eb:
+			return ea < eb
+		case !moreA || !moreB:
+			return !moreA && moreB
+		}
+		a, b = ra, rb
+	}
+}
@@ -0,0 +1,52 @@
+package walkorder_test
+
+import (
+	"io/fs"
+	"sort"
+	"testing"
+	"testing/fstest"
+
+	"github.com/ncruces/go-fs/internal/walkorder"
+)
+
+func TestLess(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a.txt":       {},
+		"a/b.txt":     {},
+		"a-b/c.txt":   {},
+		"a/b/c.txt":   {},
+		"b/a.txt":     {},
+		"b/b/c.txt":   {},
+		"b.txt":       {},
+		"c/d/e/f.txt": {},
+		"c/d.txt":     {},
+		"c/d-e/f.txt": {},
+		"café.txt":    {},
+		"cafñ.txt":    {},
+	}
+
+	var want []string
+	fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+		if !d.IsDir() {
+			want = append(want, path)
+		}
+		return err
+	})
+
+	var got []string
+	for name := range fsys {
+		got = append(got, name)
+	}
+	sort.Slice(got, func(i, j int) bool { return walkorder.Less(got[i], got[j]) })
+
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got %q, want %q", got, want)
+		}
+	}
+	for _, name := range want {
+		if walkorder.Less(name, name) {
+			t.Errorf("Less(%q, %q) = true", name, name)
+		}
+	}
+}
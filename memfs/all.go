@@ -0,0 +1,42 @@
+//go:build go1.23
+
+package memfs
+
+import (
+	"io/fs"
+	"iter"
+)
+
+// All returns an iterator over all files, and their metadata, in fs.WalkDir order.
+// Files in mounted file systems are not included.
+//
+// Usage:
+//
+//	for name, info := range assets.All() {
+//		log.Println(name, info.Size())
+//	}
+func (fsys *FileSystem) All() iter.Seq2[string, fs.FileInfo] {
+	return func(yield func(string, fs.FileInfo) bool) {
+		fsys.all(&fsys.root, ".", yield)
+	}
+}
+
+func (fsys *FileSystem) all(n *node, name string, yield func(string, fs.FileInfo) bool) bool {
+	for _, kid := range fsys.kids(n) {
+		p := kid.name()
+		if name != "." {
+			p = name + "/" + p
+		}
+		switch {
+		case kid.dir:
+			if !fsys.all(kid, p, yield) {
+				return false
+			}
+		case kid.mount == nil:
+			if !yield(p, &kid.obj) {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,44 @@
+//go:build go1.23
+
+package memfs_test
+
+import (
+	"reflect"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/ncruces/go-fs/memfs"
+)
+
+func TestFileSystem_All(t *testing.T) {
+	fsys := memfs.Create()
+	for _, name := range []string{"a.txt", "b/c.txt", "b/d/e.txt", "f.txt"} {
+		fsys.CreateString(name, "text/plain", time.Time{}, 0, len(name), name)
+	}
+	if err := fsys.Mount("m", fstest.MapFS{"x.txt": {}}); err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for name, info := range fsys.All() {
+		if info.Size() != int64(len(name)) {
+			t.Errorf("%s: got size %d", name, info.Size())
+		}
+		names = append(names, name)
+	}
+	if want := []string{"a.txt", "b/c.txt", "b/d/e.txt", "f.txt"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("got %v, want %v", names, want)
+	}
+
+	names = nil
+	for name := range fsys.All() {
+		names = append(names, name)
+		if name == "b/c.txt" {
+			break
+		}
+	}
+	if want := []string{"a.txt", "b/c.txt"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("got %v, want %v", names, want)
+	}
+}
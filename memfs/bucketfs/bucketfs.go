@@ -0,0 +1,46 @@
+// Package bucketfs loads a memfs.FileSystem from object storage
+// (S3, GCS, Azure Blob Storage, …) through the Go CDK.
+//
+// This is a separate module, so the Go CDK isn't a dependency of memfs.
+// Drivers are registered by importing them, e.g.:
+//
+//	import _ "gocloud.dev/blob/s3blob"
+//	import _ "gocloud.dev/blob/gcsblob"
+package bucketfs
+
+import (
+	"context"
+	"strings"
+
+	"github.com/ncruces/go-fs/memfs"
+	"gocloud.dev/blob"
+)
+
+// LoadBucket loads the objects under prefix, in the bucket opened from bucketURL,
+// into a new FileSystem instance.
+// Objects are named relative to prefix, and compressed with the specified compression level.
+//
+// Usage:
+//
+//	assets, err := bucketfs.LoadBucket(ctx, "s3://my-bucket?region=us-west-1", "static/", gzip.BestCompression)
+func LoadBucket(ctx context.Context, bucketURL, prefix string, level int) (*memfs.FileSystem, error) {
+	bucket, err := blob.OpenBucket(ctx, bucketURL)
+	if err != nil {
+		return nil, err
+	}
+	defer bucket.Close()
+	return Load(ctx, bucket, prefix, level)
+}
+
+// Load is like LoadBucket, for an open bucket.
+func Load(ctx context.Context, bucket *blob.Bucket, prefix string, level int) (*memfs.FileSystem, error) {
+	// blob expects prefixes to end in the delimiter
+	if prefix = strings.Trim(prefix, "/"); prefix != "" {
+		bucket = blob.PrefixedBucket(bucket, prefix+"/")
+		defer bucket.Close()
+	}
+	bucket.SetIOFSCallback(func() (context.Context, *blob.ReaderOptions) {
+		return ctx, nil
+	})
+	return memfs.LoadCompressedContext(ctx, bucket, level)
+}
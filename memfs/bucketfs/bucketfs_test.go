@@ -0,0 +1,55 @@
+package bucketfs_test
+
+import (
+	"compress/gzip"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/ncruces/go-fs/memfs/bucketfs"
+	"gocloud.dev/blob"
+	_ "gocloud.dev/blob/memblob"
+)
+
+func TestLoad(t *testing.T) {
+	ctx := context.Background()
+	bucket, err := blob.OpenBucket(ctx, "mem://")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bucket.Close()
+
+	html := strings.Repeat("<p>Hello, world!</p>", 100)
+	for key, data := range map[string]string{
+		"static/index.html":  html,
+		"static/css/app.css": "body{}",
+		"private/secret.txt": "secret",
+	} {
+		if err := bucket.WriteAll(ctx, key, []byte(data), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fsys, err := bucketfs.Load(ctx, bucket, "static", gzip.BestCompression)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err := fsys.ReadFile("index.html"); err != nil || string(data) != html {
+		t.Errorf("got %q, %v", data, err)
+	}
+	if data, err := fsys.ReadFile("css/app.css"); err != nil || string(data) != "body{}" {
+		t.Errorf("got %q, %v", data, err)
+	}
+	if _, err := fsys.Stat("secret.txt"); err == nil {
+		t.Error("want error")
+	}
+	if stats := fsys.Stats(); stats.Files != 2 || stats.Stored >= stats.Size {
+		t.Errorf("got %+v", stats)
+	}
+}
+
+func TestLoadBucket(t *testing.T) {
+	if _, err := bucketfs.LoadBucket(context.Background(), "nope://bucket", "", gzip.NoCompression); err == nil {
+		t.Error("want error")
+	}
+}
@@ -0,0 +1,39 @@
+package memfs
+
+import "strings"
+
+// Builder builds a FileSystem, one file at a time.
+// Errors are collected, so they're only checked once, by Build.
+//
+// Usage:
+//
+//	site, err := memfs.NewBuilder().
+//		Add("index.html", "<h1>Hello, world!</h1>").
+//		Add("app.js", script, memfs.WithCompression(gzip.BestCompression)).
+//		Build()
+type Builder struct {
+	fsys *FileSystem
+	err  error
+}
+
+// NewBuilder creates a Builder for an empty FileSystem.
+func NewBuilder() *Builder {
+	return &Builder{fsys: Create()}
+}
+
+// Add adds a file with the given content.
+// Does nothing if a previous call failed.
+func (b *Builder) Add(name, content string, opts ...FileOption) *Builder {
+	if b.err == nil {
+		b.err = b.fsys.CreateFile(name, strings.NewReader(content), opts...)
+	}
+	return b
+}
+
+// Build returns the FileSystem, or the first error adding files.
+func (b *Builder) Build() (*FileSystem, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	return b.fsys, nil
+}
@@ -0,0 +1,477 @@
+package memfs
+
+import (
+	"container/list"
+	"errors"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Cache is a read-through cache in front of a (slow) fs.FS.
+//
+// Files are loaded into memory the first time they're opened,
+// and gzip-compressed with the specified compression level.
+// Directories are always read from the underlying file system.
+// Files stay cached until they expire, are invalidated, or are evicted to stay under the size limit
+// (files being opened or served are not evicted until done).
+//
+// Cache is safe for concurrent use.
+type Cache struct {
+	fsys  *FileSystem
+	from  fs.FS
+	level int
+
+	mtx     sync.Mutex
+	ttl     func(name string) time.Duration
+	stale   func(name string) time.Duration
+	limit   int64
+	calls   map[string]*cacheCall
+	entries map[string]*list.Element
+	lru     list.List
+	stats   CacheStats
+}
+
+// CacheStats describes the state of a Cache.
+type CacheStats struct {
+	Files     int   // number of cached files
+	Size      int64 // size of cached files, as stored in memory
+	Hits      int64 // number of lookups served from the cache
+	Misses    int64 // number of lookups that loaded a file
+	Stale     int64 // number of hits served stale, while revalidating
+	Evictions int64 // number of files evicted to stay under the size limit
+}
+
+type cacheCall struct {
+	wg  sync.WaitGroup
+	err error
+	gen int // bumped when the file is invalidated while loading
+}
+
+type cacheEntry struct {
+	name    string
+	size    int64
+	expires time.Time
+	stale   time.Time // expired, can be served until revalidated
+	pins    int       // opens, reads or requests in progress, which prevent eviction
+}
+
+var errIsDir = errors.New("is a directory")
+var errInvalidated = errors.New("invalidated while loading")
+
+// NewCache creates a read-through cache of an fs.FS.
+// Files are gzip-compressed with the specified compression level.
+func NewCache(from fs.FS, level int) *Cache {
+	fsys := Create()
+	fsys.mtx = new(sync.RWMutex)
+	return &Cache{
+		fsys:    fsys,
+		from:    from,
+		level:   level,
+		calls:   map[string]*cacheCall{},
+		entries: map[string]*list.Element{},
+	}
+}
+
+// SetLimit sets the maximum size of cached files, as stored in memory.
+// Least recently used files are evicted to stay under the limit.
+// Zero or negative means no limit.
+func (c *Cache) SetLimit(bytes int64) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.limit = bytes
+	c.evict()
+}
+
+// Hook adds hooks to the chain called while serving HTTP requests, like FileSystem.Hook.
+func (c *Cache) Hook(hooks Hooks) {
+	c.fsys.Hook(hooks)
+}
+
+// Stats returns statistics about the cache.
+func (c *Cache) Stats() CacheStats {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.stats
+}
+
+// SetTTL sets a function that returns how long each file stays cached.
+// A zero or negative duration caches the file until invalidated.
+// Affects files loaded after the call.
+func (c *Cache) SetTTL(ttl func(name string) time.Duration) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.ttl = ttl
+}
+
+// SetStale sets a function that returns how long each file can be served after it expires,
+// while it's reloaded from the underlying file system in the background (stale-while-revalidate),
+// so latency loading files doesn't affect requests.
+// If reloading fails, the stale file keeps being served until the end of this window
+// (unless it no longer exists).
+// Affects files loaded after the call.
+func (c *Cache) SetStale(stale func(name string) time.Duration) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.stale = stale
+}
+
+// Invalidate removes the named file from the cache.
+// It will be reloaded from the underlying file system the next time it's opened.
+func (c *Cache) Invalidate(name string) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.invalidate(name)
+}
+
+// InvalidatePrefix removes all files with names starting with prefix from the cache.
+// Use a trailing slash to invalidate a directory.
+func (c *Cache) InvalidatePrefix(prefix string) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	for name := range c.entries {
+		if strings.HasPrefix(name, prefix) {
+			c.invalidate(name)
+		}
+	}
+	for name, call := range c.calls {
+		if _, ok := c.entries[name]; !ok && strings.HasPrefix(name, prefix) {
+			call.gen++
+		}
+	}
+}
+
+// invalidate removes name from the cache,
+// and discards the file being loaded, if any.
+// Call with the lock held.
+func (c *Cache) invalidate(name string) {
+	if call, ok := c.calls[name]; ok {
+		call.gen++
+	}
+	if e, ok := c.entries[name]; ok {
+		c.stats.Files--
+		c.stats.Size -= e.Value.(*cacheEntry).size
+		delete(c.entries, name)
+		c.lru.Remove(e)
+	}
+	c.fsys.Remove(name)
+}
+
+// evict removes least recently used files until the cache is under the size limit.
+// The most recently used file, and pinned files, are never evicted.
+func (c *Cache) evict() {
+	for e := c.lru.Back(); c.limit > 0 && c.stats.Size > c.limit && e != nil && e != c.lru.Front(); {
+		prev := e.Prev()
+		if entry := e.Value.(*cacheEntry); entry.pins == 0 {
+			c.invalidate(entry.name)
+			c.stats.Evictions++
+		}
+		e = prev
+	}
+}
+
+// pin pins the cached entry for name, if any, so it isn't evicted.
+// Call with the lock held.
+func (c *Cache) pin(name string) *cacheEntry {
+	if e, ok := c.entries[name]; ok {
+		entry := e.Value.(*cacheEntry)
+		entry.pins++
+		return entry
+	}
+	return nil
+}
+
+// unpin releases entries pinned by fill,
+// and evicts files that were kept over the size limit while pinned.
+func (c *Cache) unpin(entries ...*cacheEntry) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	for _, entry := range entries {
+		if entry != nil {
+			entry.pins--
+		}
+	}
+	c.evict()
+}
+
+// Open implements fs.FS, opening files for reading.
+// Files are loaded into the cache, directories are opened from the underlying file system.
+func (c *Cache) Open(name string) (fs.File, error) {
+	switch entry, _, err := c.fill(name); err {
+	case nil:
+		defer c.unpin(entry)
+		return c.fsys.Open(name)
+	case errIsDir:
+		return c.from.Open(name)
+	default:
+		return nil, err
+	}
+}
+
+// ReadFile implements fs.ReadFileFS, reading the named file and returning its contents.
+func (c *Cache) ReadFile(name string) ([]byte, error) {
+	switch entry, _, err := c.fill(name); err {
+	case nil:
+		defer c.unpin(entry)
+		return c.fsys.ReadFile(name)
+	case errIsDir:
+		return nil, fs.ErrInvalid
+	default:
+		return nil, err
+	}
+}
+
+// Stat implements fs.StatFS, returning a fs.FileInfo that describes the file.
+func (c *Cache) Stat(name string) (fs.FileInfo, error) {
+	switch entry, _, err := c.fill(name); err {
+	case nil:
+		defer c.unpin(entry)
+		return c.fsys.Stat(name)
+	case errIsDir:
+		return fs.Stat(c.from, name)
+	default:
+		return nil, err
+	}
+}
+
+// ReadDir implements fs.ReadDirFS, reading the named directory from the underlying file system.
+func (c *Cache) ReadDir(name string) ([]fs.DirEntry, error) {
+	return fs.ReadDir(c.from, name)
+}
+
+// ServeHTTP implements http.Handler, like FileSystem.ServeHTTP.
+func (c *Cache) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.fsys.hook(w, r, func(w http.ResponseWriter, r *http.Request) {
+		name := requestName(r)
+		loaded, pinned := c.prefetch(name)
+		defer c.unpin(pinned...)
+		setLoaded(w, loaded)
+		c.fsys.serveFile(w, r, name)
+	})
+}
+
+// ServeFile replaces http.ServeFile, like FileSystem.ServeFile.
+func (c *Cache) ServeFile(w http.ResponseWriter, r *http.Request, name string) {
+	if !validServeName(name) {
+		c.fsys.hook(w, r, func(w http.ResponseWriter, r *http.Request) {
+			entry, loaded, _ := c.fill("404.html")
+			defer c.unpin(entry)
+			setLoaded(w, loaded)
+			c.fsys.notFound(w, r)
+		})
+		return
+	}
+	setRequestName(r, name)
+	c.fsys.hook(w, r, func(w http.ResponseWriter, r *http.Request) {
+		name := requestName(r)
+		loaded, pinned := c.prefetch(name)
+		defer c.unpin(pinned...)
+		setLoaded(w, loaded)
+		c.fsys.serveFile(w, r, name)
+	})
+}
+
+// ServeContent replaces http.ServeContent, like FileSystem.ServeContent.
+func (c *Cache) ServeContent(w http.ResponseWriter, r *http.Request, name string) {
+	c.fsys.hook(w, r, func(w http.ResponseWriter, r *http.Request) {
+		entry, loaded, _ := c.fill(name)
+		defer c.unpin(entry)
+		setLoaded(w, loaded)
+		c.fsys.serveContent(w, r, name)
+	})
+}
+
+// prefetch loads the files needed to serve name:
+// the file itself, the index.html (or index.htm, index.xhtml) of a directory, or 404.html.
+// Reports whether any file was loaded, and returns the pinned files, to unpin when served.
+func (c *Cache) prefetch(name string) (loaded bool, pinned []*cacheEntry) {
+	entry, loaded, err := c.fill(name)
+	if err == errIsDir {
+		for _, index := range c.fsys.index(name) {
+			if entry, loaded, err = c.fill(path.Join(name, index)); err == nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		entry, loaded, err = c.fill("404.html")
+	}
+	if err == nil {
+		pinned = append(pinned, entry)
+	}
+	return loaded, pinned
+}
+
+// fill loads name into the cache, unless it's already there,
+// and reports whether it was loaded.
+// The entry is pinned, so it isn't evicted before the caller is done with it, and calls unpin.
+// Concurrent loads of the same name are coalesced.
+func (c *Cache) fill(name string) (entry *cacheEntry, loaded bool, err error) {
+	for {
+		if entry := c.hit(name); entry != nil {
+			return entry, false, nil
+		}
+
+		c.mtx.Lock()
+		if call, ok := c.calls[name]; ok {
+			c.mtx.Unlock()
+			call.wg.Wait()
+			if call.err != nil {
+				return nil, false, call.err
+			}
+			c.mtx.Lock()
+			entry := c.pin(name)
+			c.mtx.Unlock()
+			if entry != nil {
+				return entry, true, nil
+			}
+			continue // evicted before it could be pinned
+		}
+		call := new(cacheCall)
+		call.wg.Add(1)
+		c.calls[name] = call
+		c.mtx.Unlock()
+
+		for {
+			entry, call.err = c.load(name, call, true)
+			if call.err != errInvalidated {
+				break
+			}
+		}
+		call.wg.Done()
+
+		c.mtx.Lock()
+		delete(c.calls, name)
+		c.mtx.Unlock()
+		return entry, call.err == nil, call.err
+	}
+}
+
+// hit checks if name is cached and fresh, marks it as recently used, and pins it.
+// Stale files are revalidated in the background, expired files are invalidated
+// (unless pinned, then they're reloaded in place).
+func (c *Cache) hit(name string) *cacheEntry {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	e, ok := c.entries[name]
+	if !ok {
+		return nil
+	}
+	entry := e.Value.(*cacheEntry)
+	if !entry.expires.IsZero() {
+		now := time.Now()
+		switch {
+		case now.Before(entry.expires):
+		case now.Before(entry.stale):
+			c.revalidate(name)
+			c.stats.Stale++
+		default:
+			if entry.pins == 0 {
+				c.invalidate(name)
+			}
+			return nil
+		}
+	}
+	c.lru.MoveToFront(e)
+	c.stats.Hits++
+	entry.pins++
+	return entry
+}
+
+// revalidate reloads name in the background, unless it's already loading.
+// Call with the lock held.
+func (c *Cache) revalidate(name string) {
+	if _, ok := c.calls[name]; ok {
+		return
+	}
+	call := new(cacheCall)
+	call.wg.Add(1)
+	c.calls[name] = call
+
+	go func() {
+		_, call.err = c.load(name, call, false)
+		call.wg.Done()
+
+		c.mtx.Lock()
+		defer c.mtx.Unlock()
+		if errors.Is(call.err, fs.ErrNotExist) || call.err == errIsDir {
+			c.invalidate(name)
+		}
+		delete(c.calls, name)
+	}()
+}
+
+// load loads name into the cache, and pins it if pin is true.
+// Fails with errInvalidated if name is invalidated before it's cached,
+// which discards the loaded file.
+func (c *Cache) load(name string, call *cacheCall, pin bool) (*cacheEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, fs.ErrInvalid
+	}
+	c.mtx.Lock()
+	gen := call.gen
+	c.mtx.Unlock()
+
+	f, err := c.from.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, errIsDir
+	}
+	err = c.fsys.CreateCompressed(name, "", info.ModTime(), f, c.level)
+	if err != nil {
+		return nil, err
+	}
+	o, _ := c.fsys.object(name)
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if call.gen != gen {
+		c.fsys.Remove(name)
+		return nil, errInvalidated
+	}
+	var entry *cacheEntry
+	if e, ok := c.entries[name]; ok {
+		// update in place, keeping pins
+		entry = e.Value.(*cacheEntry)
+		c.stats.Size -= entry.size
+		c.lru.MoveToFront(e)
+	} else {
+		entry = &cacheEntry{name: name}
+		c.entries[name] = c.lru.PushFront(entry)
+		c.stats.Files++
+	}
+	entry.size = int64(len(o.data))
+	entry.expires, entry.stale = time.Time{}, time.Time{}
+	if c.ttl != nil {
+		if ttl := c.ttl(name); ttl > 0 {
+			entry.expires = time.Now().Add(ttl)
+			entry.stale = entry.expires
+			if c.stale != nil {
+				entry.stale = entry.stale.Add(c.stale(name))
+			}
+		}
+	}
+	if pin {
+		entry.pins++
+	}
+	c.stats.Size += entry.size
+	c.stats.Misses++
+	c.evict()
+	return entry, nil
+}
+
+// Check interface implementations
+var _ fs.ReadFileFS = &Cache{}
+var _ fs.ReadDirFS = &Cache{}
+var _ fs.StatFS = &Cache{}
+var _ http.Handler = &Cache{}
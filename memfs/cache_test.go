@@ -0,0 +1,299 @@
+package memfs_test
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io/fs"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/ncruces/go-fs/memfs"
+)
+
+type countingFS struct {
+	fs.FS
+	opens atomic.Int32
+}
+
+func (c *countingFS) Open(name string) (fs.File, error) {
+	c.opens.Add(1)
+	return c.FS.Open(name)
+}
+
+func TestCache(t *testing.T) {
+	from := &countingFS{FS: fstest.MapFS{
+		"index.html":     {Data: []byte(strings.Repeat("<p>Hello, world!</p>", 100)), Mode: 0444},
+		"dir/a.txt":      {Data: []byte("a"), Mode: 0444},
+		"dir/b.txt":      {Data: []byte("b"), Mode: 0444},
+		"dir/index.html": {Data: []byte("<p>Index</p>"), Mode: 0444},
+	}}
+
+	cache := memfs.NewCache(from, gzip.BestCompression)
+
+	if err := fstest.TestFS(cache, "index.html", "dir/a.txt", "dir/b.txt", "dir/index.html"); err != nil {
+		t.Fatal(err)
+	}
+
+	opens := from.opens.Load()
+	if data, err := fs.ReadFile(cache, "dir/a.txt"); err != nil || string(data) != "a" {
+		t.Errorf("ReadFile(dir/a.txt): got %q, %v", data, err)
+	}
+	if n := from.opens.Load(); n != opens {
+		t.Errorf("cached file was reloaded")
+	}
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	cache.ServeHTTP(w, r)
+	if w.Code != 200 || w.Header().Get("Content-Encoding") != "gzip" || w.Header().Get("ETag") == "" {
+		t.Errorf("GET /: got %d, %v", w.Code, w.Header())
+	}
+
+	r = httptest.NewRequest("GET", "/missing", nil)
+	w = httptest.NewRecorder()
+	cache.ServeHTTP(w, r)
+	if w.Code != 404 {
+		t.Errorf("GET /missing: got %d", w.Code)
+	}
+}
+
+func TestCache_ServeFile(t *testing.T) {
+	from := fstest.MapFS{
+		"hi.txt":         {Data: []byte("Hello, world!")},
+		"404.html":       {Data: []byte("<p>Not found!</p>")},
+		"dir/index.html": {Data: []byte("<p>Index</p>")},
+	}
+	cache := memfs.NewCache(from, gzip.NoCompression)
+
+	tests := []struct {
+		name     string
+		status   int
+		location string
+	}{
+		{name: "hi.txt", status: 200},
+		{name: "dir", status: 301, location: "dir/"},
+		{name: "../hi.txt", status: 404},
+		{name: "dir/../hi.txt", status: 404},
+		{name: "..", status: 404},
+		{name: "\\evil.com", status: 404},
+		{name: "dir\\index.html", status: 404},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		cache.ServeFile(w, httptest.NewRequest("GET", "/some/route", nil), tt.name)
+		if w.Code != tt.status || w.Header().Get("Location") != tt.location {
+			t.Errorf("%q: got %d, %q", tt.name, w.Code, w.Header().Get("Location"))
+		}
+		if tt.status == 404 && w.Body.String() != "<p>Not found!</p>" {
+			t.Errorf("%q: got %q", tt.name, w.Body)
+		}
+	}
+}
+
+func TestCache_Invalidate(t *testing.T) {
+	from := fstest.MapFS{
+		"blog/a.html": {Data: []byte("a1")},
+		"blog/b.html": {Data: []byte("b1")},
+		"news.html":   {Data: []byte("n1")},
+	}
+
+	cache := memfs.NewCache(from, gzip.NoCompression)
+	cache.SetTTL(func(name string) time.Duration {
+		if name == "news.html" {
+			return time.Millisecond
+		}
+		return 0
+	})
+
+	for _, name := range []string{"blog/a.html", "blog/b.html", "news.html"} {
+		if _, err := cache.ReadFile(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	from["blog/a.html"].Data = []byte("a2")
+	from["blog/b.html"].Data = []byte("b2")
+	from["news.html"].Data = []byte("n2")
+
+	check := func(name, want string) {
+		t.Helper()
+		if data, err := cache.ReadFile(name); err != nil {
+			t.Error(err)
+		} else if string(data) != want {
+			t.Errorf("%s: got %q, want %q", name, data, want)
+		}
+	}
+
+	check("blog/a.html", "a1")
+	time.Sleep(2 * time.Millisecond)
+	check("news.html", "n2")
+
+	cache.Invalidate("blog/a.html")
+	check("blog/a.html", "a2")
+	check("blog/b.html", "b1")
+
+	cache.InvalidatePrefix("blog/")
+	check("blog/b.html", "b2")
+}
+
+func TestCache_Invalidate_loading(t *testing.T) {
+	from := fstest.MapFS{
+		"a.html": {Data: []byte("a1")},
+		"b.html": {Data: []byte("b1")},
+	}
+
+	var cache *memfs.Cache
+	var opens int
+	invalidate := func(name string) {
+		opens++
+		if opens == 1 {
+			// invalidated after it was opened, but before it's cached
+			from[name] = &fstest.MapFile{Data: []byte(name[:1] + "2")}
+			if name == "a.html" {
+				cache.Invalidate(name)
+			} else {
+				cache.InvalidatePrefix("b")
+			}
+		}
+	}
+	cache = memfs.NewCache(hookFS{from, invalidate}, gzip.NoCompression)
+
+	for _, tt := range []struct{ name, want string }{{"a.html", "a2"}, {"b.html", "b2"}} {
+		opens = 0
+		if data, err := cache.ReadFile(tt.name); err != nil || string(data) != tt.want {
+			t.Errorf("%s: got %q, %v", tt.name, data, err)
+		}
+		if data, err := cache.ReadFile(tt.name); err != nil || string(data) != tt.want {
+			t.Errorf("%s: got %q, %v", tt.name, data, err)
+		}
+		if opens != 2 {
+			t.Errorf("%s: got %d opens, want 2", tt.name, opens)
+		}
+	}
+}
+
+type hookFS struct {
+	fs.FS
+	open func(name string)
+}
+
+func (h hookFS) Open(name string) (fs.File, error) {
+	f, err := h.FS.Open(name)
+	h.open(name)
+	return f, err
+}
+
+func TestCache_SetLimit(t *testing.T) {
+	from := fstest.MapFS{
+		"a.txt": {Data: []byte(strings.Repeat("a", 100))},
+		"b.txt": {Data: []byte(strings.Repeat("b", 100))},
+		"c.txt": {Data: []byte(strings.Repeat("c", 100))},
+	}
+
+	cache := memfs.NewCache(from, gzip.NoCompression)
+	cache.SetLimit(250)
+
+	for _, name := range []string{"a.txt", "b.txt", "a.txt", "c.txt"} {
+		if _, err := cache.ReadFile(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := memfs.CacheStats{Files: 2, Size: 200, Hits: 1, Misses: 3, Evictions: 1}
+	if got := cache.Stats(); got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	// b.txt was the least recently used
+	if _, err := cache.ReadFile("a.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if got := cache.Stats(); got.Hits != 2 || got.Misses != 3 {
+		t.Errorf("a.txt was evicted: %+v", got)
+	}
+}
+
+func TestCache_SetStale(t *testing.T) {
+	from := fstest.MapFS{
+		"news.html": {Data: []byte("n1")},
+	}
+
+	cache := memfs.NewCache(from, gzip.NoCompression)
+	cache.SetTTL(func(name string) time.Duration { return time.Millisecond })
+	cache.SetStale(func(name string) time.Duration { return time.Hour })
+
+	if _, err := cache.ReadFile("news.html"); err != nil {
+		t.Fatal(err)
+	}
+	from["news.html"].Data = []byte("n2")
+	time.Sleep(2 * time.Millisecond)
+
+	// served stale, while revalidating
+	if data, err := cache.ReadFile("news.html"); err != nil || string(data) != "n1" {
+		t.Errorf("got %q, %v", data, err)
+	}
+	if got := cache.Stats(); got.Stale != 1 {
+		t.Errorf("got %+v", got)
+	}
+
+	for i := 0; ; i++ {
+		if data, err := cache.ReadFile("news.html"); err != nil {
+			t.Fatal(err)
+		} else if string(data) == "n2" {
+			break
+		}
+		if i > 100 {
+			t.Fatal("not revalidated")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestCache_SetLimit_concurrent(t *testing.T) {
+	from := fstest.MapFS{}
+	var names []string
+	for i := 0; i < 20; i++ {
+		name := fmt.Sprintf("%02d.txt", i)
+		from[name] = &fstest.MapFile{Data: []byte(strings.Repeat(name, 50))}
+		names = append(names, name)
+	}
+
+	cache := memfs.NewCache(from, gzip.NoCompression)
+	cache.SetLimit(700) // 2 files
+
+	var wg sync.WaitGroup
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				name := names[(g*7+i)%len(names)]
+				f, err := cache.Open(name)
+				if err != nil {
+					t.Errorf("Open(%s): %v", name, err)
+					return
+				}
+				f.Close()
+				if _, err := cache.ReadFile(name); err != nil {
+					t.Errorf("ReadFile(%s): %v", name, err)
+					return
+				}
+				if _, err := cache.Stat(name); err != nil {
+					t.Errorf("Stat(%s): %v", name, err)
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	if got := cache.Stats(); got.Size > 700 {
+		t.Errorf("over the limit: %+v", got)
+	}
+}
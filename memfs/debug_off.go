@@ -0,0 +1,5 @@
+//go:build !memfs_debug
+
+package memfs
+
+const debug = false
@@ -0,0 +1,7 @@
+//go:build memfs_debug
+
+package memfs
+
+// debug enables checks that are too strict, or too expensive, for production
+// (build with -tags memfs_debug).
+const debug = true
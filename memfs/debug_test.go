@@ -0,0 +1,41 @@
+//go:build memfs_debug
+
+package memfs_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ncruces/go-fs/memfs"
+)
+
+// Other tests create files out of order on purpose, so run only these:
+//
+//	go test -tags memfs_debug -run CreateString_order
+
+func TestFileSystem_CreateString_order(t *testing.T) {
+	tests := []struct {
+		names []string
+		panic string
+	}{
+		{[]string{"a/b.txt", "a.txt", "b/a.txt", "b/b/c.txt", "c.txt"}, ""},
+		{[]string{"a.txt", "b.txt", "a.txt"}, `file "a.txt" after "b.txt"`},
+		{[]string{"a.txt", "a/b.txt"}, `file "a/b.txt" after "a.txt"`},
+		{[]string{"b/c.txt", "b/c.txt"}, `duplicate file "b/c.txt"`},
+	}
+	for _, tt := range tests {
+		func() {
+			defer func() {
+				msg, _ := recover().(string)
+				if tt.panic == "" && msg != "" || !strings.Contains(msg, tt.panic) {
+					t.Errorf("%q: got %q, want %q", tt.names, msg, tt.panic)
+				}
+			}()
+			fsys := memfs.Create()
+			for _, name := range tt.names {
+				fsys.CreateString(name, "text/plain", time.Time{}, 0, 1, "x")
+			}
+		}()
+	}
+}
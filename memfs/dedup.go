@@ -0,0 +1,71 @@
+package memfs
+
+import (
+	"strings"
+	"unsafe"
+)
+
+// Identifies file contents, as stored in memory.
+type blobKey struct {
+	hash uint32
+	size int
+	len  int
+}
+
+func (o *object) blobKey() blobKey {
+	return blobKey{o.hash, o.size, len(o.data)}
+}
+
+// dedup shares the contents of obj with an existing file, if they're identical,
+// other than for the gzip header.
+// Must be called with the lock held.
+func (fsys *FileSystem) dedup(obj *object) {
+	if obj.hash == 0 || obj.data == "" {
+		return
+	}
+	key := obj.blobKey()
+	if data, ok := fsys.blobs[key]; ok {
+		if gzipBody(data) == gzipBody(obj.data) {
+			obj.data = data
+		}
+		return
+	}
+	if fsys.blobs == nil {
+		fsys.blobs = map[blobKey]string{}
+	}
+	fsys.blobs[key] = obj.data
+}
+
+// undedup forgets the contents of a file being removed.
+func (fsys *FileSystem) undedup(n *node) {
+	if n.dir || n.mount != nil || n.obj.data == "" {
+		return
+	}
+	key := n.obj.blobKey()
+	if data, ok := fsys.blobs[key]; ok && sameData(data, n.obj.data) {
+		delete(fsys.blobs, key)
+	}
+}
+
+// sameData reports if a and b share memory.
+func sameData(a, b string) bool {
+	return unsafe.StringData(a) == unsafe.StringData(b)
+}
+
+// gzipBody skips the gzip header written by compress,
+// which has the name and modification time of the file.
+func gzipBody(data string) string {
+	const fname = 0x08
+	if len(data) < 10 || data[:3] != "\x1f\x8b\x08" || data[3]&^fname != 0 {
+		return data
+	}
+	body := data[10:]
+	if data[3]&fname != 0 {
+		i := strings.IndexByte(body, 0)
+		if i < 0 {
+			return data
+		}
+		body = body[i+1:]
+	}
+	return body
+}
@@ -0,0 +1,26 @@
+package memfs
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// sourceDateEpoch returns the time set by the SOURCE_DATE_EPOCH environment variable
+// (https://reproducible-builds.org/specs/source-date-epoch/),
+// or the zero time if it's unset or invalid.
+func sourceDateEpoch() time.Time {
+	epoch, err := strconv.ParseInt(os.Getenv("SOURCE_DATE_EPOCH"), 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Unix(epoch, 0)
+}
+
+// clampTime returns t, or max if it's later (and max is not zero).
+func clampTime(t, max time.Time) time.Time {
+	if !max.IsZero() && t.After(max) {
+		return max
+	}
+	return t
+}
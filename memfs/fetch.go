@@ -0,0 +1,198 @@
+package memfs
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LoadURLs fetches URLs into a new FileSystem instance.
+// Keys of urls are file names, values are URLs.
+// Files are compressed with the specified compression level.
+//
+// URLs are fetched concurrently, and retried on network and server errors.
+// A URL fragment with a Subresource Integrity checksum (sha256-, sha384- or sha512-)
+// is verified against the content, and never sent to the server.
+//
+// Usage:
+//
+//	assets, err := memfs.LoadURLs(ctx, map[string]string{
+//		"js/lib.min.js": "https://cdn.example.com/lib@1.2.3/lib.min.js#sha384-…",
+//	}, gzip.BestCompression)
+func LoadURLs(ctx context.Context, urls map[string]string, level int) (*FileSystem, error) {
+	names := make([]string, 0, len(urls))
+	for name := range urls {
+		if !fs.ValidPath(name) || name == "." {
+			return nil, fs.ErrInvalid
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg   sync.WaitGroup
+		once sync.Once
+		fail error
+		next = make(chan int)
+		res  = make([]fetched, len(names))
+	)
+	for i := 0; i < fetchConcurrency && i < len(names); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				var err error
+				res[i], err = fetch(ctx, urls[names[i]])
+				if err != nil {
+					once.Do(func() {
+						fail = fmt.Errorf("memfs: %s: %w", names[i], err)
+						cancel()
+					})
+				}
+			}
+		}()
+	}
+feed:
+	for i := range names {
+		select {
+		case next <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(next)
+	wg.Wait()
+
+	if fail != nil {
+		return nil, fail
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	fsys := Create()
+	for i, name := range names {
+		r := res[i]
+		err := fsys.CreateFile(name, bytes.NewReader(r.data),
+			WithMIME(r.mime), WithModTime(r.modtime), WithCompression(level))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return fsys, nil
+}
+
+const (
+	fetchConcurrency = 8
+	fetchRetries     = 3
+)
+
+type fetched struct {
+	data    []byte
+	mime    string
+	modtime time.Time
+}
+
+// fetch gets a URL, retrying on network and server errors,
+// and verifies its integrity checksum.
+func fetch(ctx context.Context, rawURL string) (fetched, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fetched{}, err
+	}
+	integrity := u.Fragment
+	u.Fragment = ""
+	u.RawFragment = ""
+
+	var res fetched
+	for attempt := 0; ; attempt++ {
+		var retry bool
+		res, retry, err = fetchOnce(ctx, u.String())
+		if err == nil || !retry || attempt >= fetchRetries {
+			break
+		}
+		select {
+		case <-time.After(time.Duration(100<<attempt) * time.Millisecond):
+		case <-ctx.Done():
+			return fetched{}, ctx.Err()
+		}
+	}
+	if err != nil {
+		return fetched{}, err
+	}
+	if integrity != "" {
+		if err := verifyIntegrity(integrity, res.data); err != nil {
+			return fetched{}, err
+		}
+	}
+	return res, nil
+}
+
+func fetchOnce(ctx context.Context, url string) (res fetched, retry bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fetched{}, false, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fetched{}, ctx.Err() == nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		retry = resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+		return fetched{}, retry, fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	res.data, err = io.ReadAll(resp.Body)
+	if err != nil {
+		return fetched{}, ctx.Err() == nil, err
+	}
+	if resp.ContentLength >= 0 && int64(len(res.data)) != resp.ContentLength {
+		return fetched{}, true, io.ErrUnexpectedEOF
+	}
+
+	// generic types are better sniffed
+	if res.mime = resp.Header.Get("Content-Type"); strings.HasPrefix(res.mime, "application/octet-stream") {
+		res.mime = ""
+	}
+	res.modtime, _ = http.ParseTime(resp.Header.Get("Last-Modified"))
+	return res, false, nil
+}
+
+// verifyIntegrity verifies a Subresource Integrity checksum.
+func verifyIntegrity(integrity string, data []byte) error {
+	algo, want, _ := strings.Cut(integrity, "-")
+
+	var h hash.Hash
+	switch algo {
+	case "sha256":
+		h = sha256.New()
+	case "sha384":
+		h = sha512.New384()
+	case "sha512":
+		h = sha512.New()
+	default:
+		return fmt.Errorf("unsupported integrity checksum: %s", integrity)
+	}
+	h.Write(data)
+
+	if got := base64.StdEncoding.EncodeToString(h.Sum(nil)); got != want {
+		return fmt.Errorf("integrity checksum mismatch: got %s-%s", algo, got)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package memfs_test
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ncruces/go-fs/memfs"
+)
+
+func TestLoadURLs(t *testing.T) {
+	js := strings.Repeat("console.log('Hello, world!');\n", 100)
+	sum := sha256.Sum256([]byte(js))
+	integrity := "sha256-" + base64.StdEncoding.EncodeToString(sum[:])
+
+	var flaky atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/lib.js":
+			w.Header().Set("Content-Type", "text/javascript")
+			w.Header().Set("Last-Modified", "Wed, 01 Jan 2020 00:00:00 GMT")
+			w.Write([]byte(js))
+		case "/flaky.txt":
+			if flaky.Add(1) == 1 {
+				http.Error(w, "try again", http.StatusServiceUnavailable)
+				return
+			}
+			w.Write([]byte("ok"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	fsys, err := memfs.LoadURLs(context.Background(), map[string]string{
+		"js/lib.js": srv.URL + "/lib.js#" + integrity,
+		"flaky.txt": srv.URL + "/flaky.txt",
+	}, gzip.BestCompression)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err := fsys.ReadFile("js/lib.js"); err != nil || string(data) != js {
+		t.Errorf("got %q, %v", data, err)
+	}
+	if info, err := fsys.Stat("js/lib.js"); err != nil || info.ModTime().Year() != 2020 {
+		t.Errorf("got %v, %v", info, err)
+	}
+	if data, err := fsys.ReadFile("flaky.txt"); err != nil || string(data) != "ok" {
+		t.Errorf("got %q, %v", data, err)
+	}
+
+	for _, url := range []string{srv.URL + "/lib.js#sha256-bad", srv.URL + "/missing"} {
+		_, err := memfs.LoadURLs(context.Background(), map[string]string{"file": url}, gzip.NoCompression)
+		if err == nil {
+			t.Errorf("%s: want error", url)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package memfs
+
+import (
+	"io/fs"
+	"net/http"
+	"path"
+	"sort"
+)
+
+// Filter returns a read-only view of the file system
+// that only includes files for which pred returns true.
+//
+// Directories are visible if they contain any visible files.
+// The view implements http.Handler, like FileSystem.ServeHTTP.
+//
+// Usage:
+//
+//	// serve public/, but not templates/
+//	public := assets.Filter(func(path string, info fs.FileInfo) bool {
+//		return strings.HasPrefix(path, "public/")
+//	})
+func (fsys *FileSystem) Filter(pred func(path string, info fs.FileInfo) bool) fs.FS {
+	return filtered{fsys, pred}
+}
+
+type filtered struct {
+	fsys *FileSystem
+	pred func(path string, info fs.FileInfo) bool
+}
+
+// Open implements fs.FS.
+func (v filtered) Open(name string) (fs.File, error) {
+	info, err := v.stat(name)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return v.fsys.Open(name)
+	}
+	list, err := v.readDir(name)
+	if err != nil {
+		return nil, err
+	}
+	return &listDir{info: info, list: list}, nil
+}
+
+// ReadFile implements fs.ReadFileFS.
+func (v filtered) ReadFile(name string) ([]byte, error) {
+	if _, err := v.stat(name); err != nil {
+		return nil, err
+	}
+	return v.fsys.ReadFile(name)
+}
+
+// Stat implements fs.StatFS.
+func (v filtered) Stat(name string) (fs.FileInfo, error) {
+	return v.stat(name)
+}
+
+// ReadDir implements fs.ReadDirFS.
+func (v filtered) ReadDir(name string) ([]fs.DirEntry, error) {
+	info, err := v.stat(name)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fs.ErrInvalid
+	}
+	return v.readDir(name)
+}
+
+// ServeHTTP implements http.Handler, like FileSystem.ServeHTTP.
+// Hidden files are never served: not as default documents, negotiated alternatives, or 404.html.
+func (v filtered) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	v.fsys.hook(w, r, func(w http.ResponseWriter, r *http.Request) {
+		v.fsys.serveVisible(w, r, requestName(r), v.pred)
+	})
+}
+
+func (v filtered) stat(name string) (entryInfo, error) {
+	info, err := v.fsys.stat(name)
+	if err != nil {
+		return nil, err
+	}
+	if name != "." && !v.visible(name, info) {
+		return nil, fs.ErrNotExist
+	}
+	return info, nil
+}
+
+func (v filtered) readDir(name string) ([]fs.DirEntry, error) {
+	f, err := v.fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	entries, err := f.(fs.ReadDirFile).ReadDir(-1)
+	if err != nil {
+		return nil, err
+	}
+	var list []fs.DirEntry
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil {
+			return nil, err
+		}
+		if v.visible(path.Join(name, e.Name()), info) {
+			list = append(list, e)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name() < list[j].Name()
+	})
+	return list, nil
+}
+
+// visible checks if a file matches pred, or a directory contains any visible files.
+func (v filtered) visible(name string, info fs.FileInfo) bool {
+	if !info.IsDir() {
+		return v.pred(name, info)
+	}
+	visible := false
+	fs.WalkDir(v.fsys, name, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		if err == nil && v.pred(path, info) {
+			visible = true
+			return fs.SkipAll
+		}
+		return err
+	})
+	return visible
+}
+
+// A visibility reports whether a file can be served, for views like Filter.
+type visibility func(name string, info fs.FileInfo) bool
+
+// statVisible is like stat, but files for which visible (if not nil) returns false don't exist.
+func (fsys *FileSystem) statVisible(name string, visible visibility) (entryInfo, error) {
+	info, err := fsys.stat(name)
+	if err == nil && visible != nil && !info.IsDir() && !visible(name, info) {
+		return nil, fs.ErrNotExist
+	}
+	return info, err
+}
+
+// Check interface implementations
+var _ fs.ReadFileFS = filtered{}
+var _ fs.ReadDirFS = filtered{}
+var _ fs.StatFS = filtered{}
+var _ http.Handler = filtered{}
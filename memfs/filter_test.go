@@ -0,0 +1,86 @@
+package memfs_test
+
+import (
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/ncruces/go-fs/memfs"
+)
+
+func TestFileSystem_Filter(t *testing.T) {
+	fsys := memfs.Create()
+	for _, name := range []string{"public/index.html", "public/css/site.css", "templates/page.html", "public/secret.txt"} {
+		if err := fsys.Create(name, "", time.Now(), strings.NewReader(name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	view := fsys.Filter(func(path string, info fs.FileInfo) bool {
+		return strings.HasPrefix(path, "public/") && !strings.HasSuffix(path, ".txt")
+	})
+
+	if err := fstest.TestFS(view, "public/index.html", "public/css/site.css"); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"templates", "templates/page.html", "public/secret.txt"} {
+		if _, err := fs.Stat(view, name); err != fs.ErrNotExist {
+			t.Errorf("Stat(%s): got %v, want %v", name, err, fs.ErrNotExist)
+		}
+	}
+
+	handler := view.(http.Handler)
+	for path, want := range map[string]int{
+		"/public/":             http.StatusOK,
+		"/public/css/site.css": http.StatusOK,
+		"/public/secret.txt":   http.StatusNotFound,
+		"/templates/page.html": http.StatusNotFound,
+	} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if w.Code != want {
+			t.Errorf("GET %s: got %d, want %d", path, w.Code, want)
+		}
+	}
+}
+
+func TestFileSystem_Filter_negotiated(t *testing.T) {
+	fsys := memfs.Create()
+	fsys.SetLanguages("en", "pt")
+	fsys.SetImageFormats(".avif")
+	for _, name := range []string{"404.html", "page.html", "page.pt.html", "photo.avif", "photo.jpg"} {
+		if err := fsys.Create(name, "", time.Now(), strings.NewReader(name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	view := fsys.Filter(func(path string, info fs.FileInfo) bool {
+		return path == "page.html" || path == "photo.jpg"
+	}).(http.Handler)
+
+	tests := []struct {
+		path, header, value string
+		code                int
+		body                string
+	}{
+		{"/page.html", "Accept-Language", "pt", 200, "page.html"},
+		{"/photo.jpg", "Accept", "image/avif", 200, "photo.jpg"},
+		{"/page.pt.html", "", "", 404, "404 page not found\n"},
+		{"/missing", "", "", 404, "404 page not found\n"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", tt.path, nil)
+		if tt.header != "" {
+			r.Header.Set(tt.header, tt.value)
+		}
+		w := httptest.NewRecorder()
+		view.ServeHTTP(w, r)
+		if w.Code != tt.code || w.Body.String() != tt.body {
+			t.Errorf("GET %s: got %d %q, want %d %q", tt.path, w.Code, w.Body, tt.code, tt.body)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package memfs
+
+import (
+	"io/fs"
+	"strings"
+)
+
+// CaseInsensitive makes names case-insensitive:
+// names that don't match a file or directory exactly are matched ignoring case.
+// Names in mounted file systems are also matched ignoring case,
+// as long as the mounted file system is a case-insensitive *FileSystem.
+//
+// Fails with fs.ErrExist if existing names collide, ignoring case.
+// Afterwards, creating a file that collides with an existing name fails with fs.ErrExist.
+func (fsys *FileSystem) CaseInsensitive() error {
+	if fsys.mtx != nil {
+		fsys.mtx.Lock()
+		defer fsys.mtx.Unlock()
+	}
+	if err := fsys.checkSealed(); err != nil {
+		return err
+	}
+	if fsys.folds != nil {
+		return nil
+	}
+	folds := foldMap{}
+	if !folds.add(&fsys.root) {
+		return fs.ErrExist
+	}
+	fsys.folds = folds
+	return nil
+}
+
+// collides reports if creating a file at name would collide with an existing name, ignoring case.
+func (fsys *FileSystem) collides(name string) bool {
+	if fsys.folds == nil {
+		return false
+	}
+	dir := &fsys.root
+	for {
+		elem, rest, more := strings.Cut(name, "/")
+		n := dir.child(elem)
+		if n == nil {
+			return fsys.folds.collides(dir, elem)
+		}
+		if !more || !n.dir {
+			return false
+		}
+		dir, name = n, rest
+	}
+}
+
+// Maps names in directories, ignoring case, to their actual names.
+type foldMap map[foldKey]string
+
+// A name in a directory, ignoring case.
+type foldKey struct {
+	dir  *node
+	name string
+}
+
+func fold(dir *node, name string) foldKey {
+	return foldKey{dir, strings.ToLower(name)}
+}
+
+// add adds the entries of dir, and its subdirectories.
+// Returns false if names collide.
+func (folds foldMap) add(dir *node) bool {
+	for _, kid := range dir.kids {
+		key := fold(dir, kid.name())
+		if _, ok := folds[key]; ok {
+			return false
+		}
+		folds[key] = kid.name()
+		if kid.dir && !folds.add(kid) {
+			return false
+		}
+	}
+	return true
+}
+
+// remove removes n from dir, and the entries of n if it's a directory.
+func (folds foldMap) remove(dir, n *node) {
+	delete(folds, fold(dir, n.name()))
+	for _, kid := range n.kids {
+		folds.remove(n, kid)
+	}
+}
+
+// collides reports if the entry name of dir collides with an existing entry, ignoring case.
+func (folds foldMap) collides(dir *node, name string) bool {
+	other, ok := folds[fold(dir, name)]
+	return ok && other != name
+}
+
+// child finds the named entry of dir, ignoring case if folds isn't nil.
+func (folds foldMap) child(dir *node, name string) *node {
+	if n := dir.child(name); n != nil || folds == nil {
+		return n
+	}
+	if other, ok := folds[fold(dir, name)]; ok {
+		return dir.child(other)
+	}
+	return nil
+}
@@ -0,0 +1,25 @@
+//go:build !tinygo && !memfs_small
+
+package memfs
+
+import (
+	"net/http"
+
+	"golang.org/x/net/http/httpguts"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Set by the tinygo and memfs_small build tags.
+const small = false
+
+func nfc(name string) string {
+	return norm.NFC.String(name)
+}
+
+func acceptsGzip(header []string) bool {
+	return httpguts.HeaderValuesContainsToken(header, "gzip")
+}
+
+func sniff(data []byte) string {
+	return http.DetectContentType(data)
+}
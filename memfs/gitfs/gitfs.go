@@ -0,0 +1,70 @@
+// Package gitfs loads a memfs.FileSystem from the tree of a git commit,
+// through go-git, without checking it out to disk.
+//
+// This is a separate module, so go-git isn't a dependency of memfs.
+package gitfs
+
+import (
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/ncruces/go-fs/memfs"
+)
+
+// LoadRevision loads the tree of a revision (a branch, tag, commit hash, …)
+// of the git repository at path into a new FileSystem instance.
+// Files are compressed with the specified compression level,
+// and their modification time is the commit time.
+//
+// Usage:
+//
+//	docs, err := gitfs.LoadRevision(".", "v1.2.0", gzip.BestCompression)
+func LoadRevision(path, revision string, level int) (*memfs.FileSystem, error) {
+	repo, err := git.PlainOpenWithOptions(path, &git.PlainOpenOptions{DetectDotGit: true})
+	if err != nil {
+		return nil, err
+	}
+	return Load(repo, revision, level)
+}
+
+// Load is like LoadRevision, for an open repository.
+func Load(repo *git.Repository, revision string, level int) (*memfs.FileSystem, error) {
+	hash, err := repo.ResolveRevision(plumbing.Revision(revision))
+	if err != nil {
+		return nil, err
+	}
+	commit, err := repo.CommitObject(*hash)
+	if err != nil {
+		return nil, err
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, err
+	}
+	return LoadTree(tree, commit.Committer.When, level)
+}
+
+// LoadTree loads a tree into a new FileSystem instance.
+// Files are compressed with the specified compression level,
+// and have modtime as their modification time.
+// Symbolic links and submodules are skipped.
+func LoadTree(tree *object.Tree, modtime time.Time, level int) (*memfs.FileSystem, error) {
+	fsys := memfs.Create()
+	err := tree.Files().ForEach(func(f *object.File) error {
+		if !f.Mode.IsRegular() {
+			return nil
+		}
+		r, err := f.Reader()
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		return fsys.CreateFile(f.Name, r, memfs.WithModTime(modtime), memfs.WithCompression(level))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return fsys, nil
+}
@@ -0,0 +1,79 @@
+package gitfs_test
+
+import (
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/ncruces/go-fs/memfs/gitfs"
+)
+
+func TestLoadRevision(t *testing.T) {
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v1 := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	commit := func(when time.Time, files map[string]string) {
+		t.Helper()
+		for name, data := range files {
+			path := filepath.Join(dir, filepath.FromSlash(name))
+			if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, []byte(data), 0666); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := tree.Add(name); err != nil {
+				t.Fatal(err)
+			}
+		}
+		sig := &object.Signature{Name: "test", Email: "test@example.com", When: when}
+		hash, err := tree.Commit("commit", &git.CommitOptions{Author: sig, Committer: sig})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if when == v1 {
+			if _, err := repo.CreateTag("v1", hash, nil); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	commit(v1, map[string]string{"index.md": "# v1", "guide/intro.md": "intro"})
+	commit(v1.AddDate(1, 0, 0), map[string]string{"index.md": "# v2"})
+
+	tests := []struct {
+		revision string
+		index    string
+		year     int
+	}{
+		{"v1", "# v1", 2020},
+		{"HEAD", "# v2", 2021},
+	}
+	for _, tt := range tests {
+		fsys, err := gitfs.LoadRevision(dir, tt.revision, gzip.BestCompression)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if data, err := fsys.ReadFile("index.md"); err != nil || string(data) != tt.index {
+			t.Errorf("%s: got %q, %v", tt.revision, data, err)
+		}
+		if info, err := fsys.Stat("guide/intro.md"); err != nil || info.ModTime().Year() != tt.year {
+			t.Errorf("%s: got %v, %v", tt.revision, info, err)
+		}
+	}
+
+	if _, err := gitfs.LoadRevision(dir, "v3", gzip.NoCompression); err == nil {
+		t.Error("want error")
+	}
+}
@@ -0,0 +1,77 @@
+package memfs
+
+import "io/fs"
+
+// SetHistory keeps the previous n versions of each overwritten file, to open with OpenVersion
+// (e.g. so a hot reloading server can still serve the previous bundle to clients that reference it).
+// Zero or negative keeps none, and forgets kept versions.
+func (fsys *FileSystem) SetHistory(n int) error {
+	if fsys.mtx != nil {
+		fsys.mtx.Lock()
+		defer fsys.mtx.Unlock()
+	}
+	if err := fsys.checkSealed(); err != nil {
+		return err
+	}
+	fsys.history = n
+	for name, versions := range fsys.versions {
+		if n <= 0 {
+			delete(fsys.versions, name)
+		} else if len(versions) > n {
+			fsys.versions[name] = versions[:n]
+		}
+	}
+	return nil
+}
+
+// OpenVersion opens a version of the named file:
+// 0 is the current version, 1 the previous one, 2 the one before that, etc.
+// Previous versions are only kept after SetHistory.
+func (fsys *FileSystem) OpenVersion(name string, n int) (fs.File, error) {
+	if n == 0 {
+		return fsys.Open(name)
+	}
+	if !fs.ValidPath(name) || n < 0 {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+
+	var o object
+	func() {
+		if fsys.mtx != nil {
+			fsys.mtx.RLock()
+			defer fsys.mtx.RUnlock()
+		}
+		if versions := fsys.versions[fsys.normal(name)]; n <= len(versions) {
+			o = versions[n-1]
+		}
+	}()
+	if o.name == "" {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return fsys.open(name, o)
+}
+
+// keep keeps the version of a file being overwritten, if SetHistory was called.
+// Call with the lock held.
+func (fsys *FileSystem) keep(name string, old *node) {
+	if fsys.history <= 0 || old.dir || old.mount != nil {
+		return
+	}
+	o := old.obj
+	if old.lazy != nil {
+		var ok bool
+		if o, ok = old.lazy.produced(); !ok {
+			return
+		}
+		o.name, o.head = old.obj.name, old.obj.head
+	}
+
+	versions := fsys.versions[name]
+	if len(versions) >= fsys.history {
+		versions = versions[:fsys.history-1]
+	}
+	if fsys.versions == nil {
+		fsys.versions = map[string][]object{}
+	}
+	fsys.versions[name] = append([]object{o}, versions...)
+}
@@ -0,0 +1,117 @@
+package memfs
+
+import "net/http"
+
+// Hooks are called while serving HTTP requests
+// with ServeHTTP, ServeFile and ServeContent.
+// Any of the functions can be nil.
+type Hooks struct {
+	// OnRequest is called before the file is looked up.
+	// It can modify the request (e.g. to rewrite its URL),
+	// or write a response and return false to stop processing (e.g. to deny access).
+	OnRequest func(w http.ResponseWriter, r *http.Request) bool
+
+	// OnWrite is called before the response header is written.
+	// It can modify the header.
+	OnWrite func(w http.ResponseWriter, r *http.Request, status int)
+
+	// OnServe is called before a file is served, with information about it.
+	OnServe func(r *http.Request, info ServeInfo)
+
+	// OnDone is called after the response is complete,
+	// with its status code and the number of body bytes written.
+	OnDone func(r *http.Request, status int, written int64)
+}
+
+// ServeInfo describes a file being served, for the OnServe hook.
+type ServeInfo struct {
+	Name       string // name of the file
+	Compressed bool   // the file is stored gzip-compressed
+	Loaded     bool   // the file was loaded into a Cache to serve the request
+}
+
+// Hook adds hooks to the chain called while serving HTTP requests.
+// Hooks are called in the order they were added.
+func (fsys *FileSystem) Hook(hooks Hooks) error {
+	if err := fsys.checkSealed(); err != nil {
+		return err
+	}
+	fsys.hooks = append(fsys.hooks, hooks)
+	return nil
+}
+
+func (fsys *FileSystem) hook(w http.ResponseWriter, r *http.Request, serve http.HandlerFunc) {
+	if len(fsys.hooks) == 0 {
+		serve(w, r)
+		return
+	}
+
+	for _, h := range fsys.hooks {
+		if h.OnRequest != nil && !h.OnRequest(w, r) {
+			return
+		}
+	}
+
+	hw := &hookWriter{ResponseWriter: w, request: r, hooks: fsys.hooks}
+	serve(hw, r)
+	if hw.status == 0 {
+		hw.status = http.StatusOK
+	}
+
+	for _, h := range fsys.hooks {
+		if h.OnDone != nil {
+			h.OnDone(r, hw.status, hw.written)
+		}
+	}
+}
+
+type hookWriter struct {
+	http.ResponseWriter
+	request *http.Request
+	hooks   []Hooks
+	status  int
+	written int64
+	loaded  bool
+}
+
+// served calls the OnServe hooks, if w is hooked.
+func served(w http.ResponseWriter, r *http.Request, name string, o object) {
+	hw, ok := w.(*hookWriter)
+	if !ok {
+		return
+	}
+	info := ServeInfo{Name: name, Compressed: len(o.data) != o.size, Loaded: hw.loaded}
+	for _, h := range hw.hooks {
+		if h.OnServe != nil {
+			h.OnServe(r, info)
+		}
+	}
+}
+
+// setLoaded records that a Cache loaded a file to serve the request, if w is hooked.
+func setLoaded(w http.ResponseWriter, loaded bool) {
+	if hw, ok := w.(*hookWriter); ok && loaded {
+		hw.loaded = true
+	}
+}
+
+func (w *hookWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+		for _, h := range w.hooks {
+			if h.OnWrite != nil {
+				h.OnWrite(w.ResponseWriter, w.request, status)
+			}
+		}
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *hookWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	n, err := w.ResponseWriter.Write(p)
+	w.written += int64(n)
+	return n, err
+}
@@ -0,0 +1,107 @@
+package memfs_test
+
+import (
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ncruces/go-fs/memfs"
+)
+
+func TestFileSystem_Hook(t *testing.T) {
+	fsys := memfs.Create()
+	if err := fsys.Create("new.txt", "", time.Now(), strings.NewReader("Hello, world!")); err != nil {
+		t.Fatal(err)
+	}
+
+	var status int
+	var written int64
+	var info memfs.ServeInfo
+	fsys.Hook(memfs.Hooks{
+		OnRequest: func(w http.ResponseWriter, r *http.Request) bool {
+			if r.Header.Get("Authorization") == "" {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return false
+			}
+			if r.URL.Path == "/old.txt" {
+				r.URL.Path = "/new.txt"
+			}
+			return true
+		},
+		OnWrite: func(w http.ResponseWriter, r *http.Request, status int) {
+			w.Header().Set("X-Status", http.StatusText(status))
+		},
+		OnServe: func(r *http.Request, i memfs.ServeInfo) {
+			info = i
+		},
+		OnDone: func(r *http.Request, s int, n int64) {
+			status, written = s, n
+		},
+	})
+
+	w := httptest.NewRecorder()
+	fsys.ServeHTTP(w, httptest.NewRequest("GET", "/old.txt", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("got %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+
+	r := httptest.NewRequest("GET", "/old.txt", nil)
+	r.Header.Set("Authorization", "secret")
+	w = httptest.NewRecorder()
+	fsys.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || w.Body.String() != "Hello, world!" {
+		t.Errorf("got %d, %q", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get("X-Status"); got != "OK" {
+		t.Errorf("X-Status: got %q", got)
+	}
+	if status != http.StatusOK || written != 13 {
+		t.Errorf("OnDone: got %d, %d", status, written)
+	}
+	if info != (memfs.ServeInfo{Name: "new.txt"}) {
+		t.Errorf("OnServe: got %+v", info)
+	}
+}
+
+func TestFileSystem_Hook_filtered(t *testing.T) {
+	fsys := memfs.Create()
+	for _, name := range []string{"public.txt", "secret.txt"} {
+		if err := fsys.Create(name, "", time.Now(), strings.NewReader(name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var served []string
+	fsys.Hook(memfs.Hooks{
+		OnRequest: func(w http.ResponseWriter, r *http.Request) bool {
+			if r.URL.Path == "/old.txt" {
+				r.URL.Path = "/public.txt"
+			}
+			return true
+		},
+		OnServe: func(r *http.Request, i memfs.ServeInfo) {
+			served = append(served, i.Name)
+		},
+	})
+
+	view := fsys.Filter(func(path string, info fs.FileInfo) bool {
+		return path != "secret.txt"
+	}).(http.Handler)
+
+	for path, want := range map[string]int{
+		"/old.txt":    http.StatusOK,
+		"/secret.txt": http.StatusNotFound,
+	} {
+		w := httptest.NewRecorder()
+		view.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if w.Code != want {
+			t.Errorf("GET %s: got %d, want %d", path, w.Code, want)
+		}
+	}
+	if len(served) != 1 || served[0] != "public.txt" {
+		t.Errorf("OnServe: got %v", served)
+	}
+}
@@ -7,91 +7,299 @@ import (
 	"path"
 	"strconv"
 	"strings"
-
-	"golang.org/x/net/http/httpguts"
+	"time"
 )
 
 // ServeHTTP implements http.Handler using ServeFile.
 // Replaces http.FileServer.
 func (fsys *FileSystem) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	// same transform as http.FileServer.ServeHTTP()
-	upath := r.URL.Path
-	if !strings.HasPrefix(upath, "/") {
-		upath = "/" + upath
-		r.URL.Path = upath
-	}
-	upath = path.Clean(upath)
-
-	// same transform as http.FS.Open()
-	if upath == "/" {
-		upath = "."
-	} else {
-		upath = upath[1:]
-	}
-	fsys.serveFile(w, r, upath)
+	fsys.hook(w, r, func(w http.ResponseWriter, r *http.Request) {
+		fsys.serveFile(w, r, requestName(r))
+	})
 }
 
 // ServeFile replaces http.ServeFile.
 // Redirects to canonical paths.
-// Serves index.html for directories, 404.html for not found.
+// Serves index.html (or the documents set with SetIndex) for directories, 404.html for not found.
 // Doesn't list directories.
+// Names with ".." elements or backslashes are not found.
 func (fsys *FileSystem) ServeFile(w http.ResponseWriter, r *http.Request, name string) {
-	if name == "." {
-		r.URL.Path = "/"
-	} else {
-		r.URL.Path = "/" + name
+	if !validServeName(name) {
+		fsys.hook(w, r, fsys.notFound)
+		return
 	}
-	fsys.serveFile(w, r, name)
+	setRequestName(r, name)
+	fsys.hook(w, r, func(w http.ResponseWriter, r *http.Request) {
+		fsys.serveFile(w, r, requestName(r))
+	})
+}
+
+// validServeName reports if name is safe to map into URL space:
+// it can't go up a directory, and it has no backslashes,
+// which browsers treat as slashes when following redirects.
+func validServeName(name string) bool {
+	if strings.ContainsAny(name, "\\\x00") {
+		return false
+	}
+	for _, elem := range strings.Split(name, "/") {
+		if elem == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+// StripPrefixHandler returns a handler that serves fsys under a URL prefix, like:
+//
+//	http.StripPrefix(prefix, fsys)
+//
+// Unlike http.StripPrefix, the request URL is left unmodified,
+// so redirects to canonical paths keep pointing at the external URL.
+// Requests outside prefix are not found.
+func StripPrefixHandler(prefix string, fsys *FileSystem) http.Handler {
+	prefix = strings.TrimSuffix(prefix, "/")
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fsys.hook(w, r, func(w http.ResponseWriter, r *http.Request) {
+			upath, ok := strings.CutPrefix(requestPath(r), prefix)
+			if !ok || upath != "" && upath[0] != '/' {
+				fsys.notFound(w, r)
+				return
+			}
+			fsys.serveFile(w, r, pathName(upath))
+		})
+	})
 }
 
 // ServeContent replaces http.ServeContent.
 // Serves the named file.
 // No redirects or rewrites.
 func (fsys *FileSystem) ServeContent(w http.ResponseWriter, r *http.Request, name string) {
-	if o, ok := fsys.objs[name]; ok {
-		var reader io.ReadSeeker
-		if o.setHeaders(w, r) {
-			reader = strings.NewReader(o.data)
+	fsys.hook(w, r, func(w http.ResponseWriter, r *http.Request) {
+		fsys.serveContent(w, r, name)
+	})
+}
+
+// ServeFileFS replaces http.ServeFileFS.
+// If fsys is a *FileSystem, uses its ServeFile method.
+// Otherwise, serves fsys like http.FileServer would.
+// Names with ".." elements or backslashes are not found.
+func ServeFileFS(w http.ResponseWriter, r *http.Request, fsys fs.FS, name string) {
+	if m, ok := fsys.(*FileSystem); ok {
+		m.ServeFile(w, r, name)
+		return
+	}
+	if !validServeName(name) {
+		http.NotFound(w, r)
+		return
+	}
+	setRequestName(r, name)
+	http.FileServer(http.FS(fsys)).ServeHTTP(w, r)
+}
+
+// ServeContent replaces http.ServeContent.
+// If content is an unread file opened from a FileSystem,
+// it's served like FileSystem.ServeContent would, ignoring name and modtime,
+// and compressed files are served directly to accepting HTTP clients.
+func ServeContent(w http.ResponseWriter, r *http.Request, name string, modtime time.Time, content io.ReadSeeker) {
+	if o, ok := fileObject(content); ok {
+		o.serve(w, r, o.etag())
+	} else {
+		http.ServeContent(w, r, name, modtime, content)
+	}
+}
+
+// fileObject returns the object for an unread file opened from a FileSystem.
+func fileObject(f io.ReadSeeker) (object, bool) {
+	switch f := f.(type) {
+	case file:
+		return f.object, f.Size() == int64(f.Len())
+	case *zfile:
+		return f.object, f.pos == 0
+	}
+	return object{}, false
+}
+
+func (fsys *FileSystem) serveContent(w http.ResponseWriter, r *http.Request, name string) {
+	if len(fsys.pages) > 0 {
+		if p, ok := fsys.pages[fsys.normal(name)]; ok {
+			p.serve(fsys, w, r, name)
+			return
+		}
+	}
+	if o, ok := fsys.object(name); ok {
+		etag := fsys.etag(name, o)
+		o = fsys.modTime(fsys.caching(r, name, o, etag))
+		fsys.preloadLinks(w, o)
+		served(w, r, name, o)
+		o.serve(w, r, etag)
+	} else if f, err := fsys.Open(name); err == nil {
+		defer f.Close()
+		info, err := f.Stat()
+		reader, ok := f.(io.ReadSeeker)
+		if err == nil && ok && !info.IsDir() {
+			http.ServeContent(w, r, info.Name(), info.ModTime(), reader)
 		} else {
-			reader = &zfile{object: o}
+			http.NotFound(w, r)
 		}
-		http.ServeContent(w, r, o.name, o.time, reader)
 	} else {
 		http.NotFound(w, r)
 	}
 }
 
 func (fsys *FileSystem) serveFile(w http.ResponseWriter, r *http.Request, name string) {
-	if _, ok := fsys.dirs[name]; ok {
-		if name == "." {
-			name = "index.html"
-		} else {
-			name = name + "/index.html"
+	fsys.serveVisible(w, r, name, nil)
+}
+
+// serveVisible is like serveFile, but only serves files for which visible returns true:
+// default documents, negotiated alternatives, and 404.html included.
+func (fsys *FileSystem) serveVisible(w http.ResponseWriter, r *http.Request, name string, visible visibility) {
+	if strings.Contains(name, "\\") {
+		fsys.notFoundVisible(w, r, visible)
+		return
+	}
+	isDir := false
+	if s, err := fsys.stat(name); err == nil && s.IsDir() {
+		isDir = true
+		name = fsys.indexFile(name, visible)
+	}
+	file := fsys.negotiateLanguage(w, r, name, visible)
+	s, err := fsys.statVisible(file, visible)
+	if err != nil || s.IsDir() || name == "404.html" {
+		fsys.notFoundVisible(w, r, visible)
+		return
+	}
+
+	// same redirects as http.FileServer
+	url := r.URL.Path
+	if !isDir && name == fsys.indexFile(path.Dir(name), visible) {
+		localRedirect(w, r, "./")
+		return
+	}
+	if isDir {
+		if !strings.HasSuffix(url, "/") {
+			localRedirect(w, r, path.Base(url)+"/")
+			return
 		}
+	} else if strings.HasSuffix(url, "/") {
+		base := s.Name()
+		if file != name {
+			base = path.Base(name)
+		}
+		localRedirect(w, r, "../"+base)
+		return
 	}
-	if o, ok := fsys.objs[name]; ok && name != "404.html" {
-		var fs fs.FS
-		if o.setHeaders(w, r) {
-			fs = rawFileSystem{fsys}
-		} else {
-			fs = fsys
+
+	name = fsys.negotiateImage(w, r, file, visible)
+	fsys.serveContent(w, r, name)
+}
+
+// SetIndex sets the default documents for a directory and its subdirectories,
+// instead of index.html, index.htm and index.xhtml.
+// The first of names that exists is served.
+//
+// Usage:
+//
+//	assets.SetIndex("docs", "README.html", "index.html")
+func (fsys *FileSystem) SetIndex(dir string, names ...string) error {
+	if err := fsys.checkSealed(); err != nil {
+		return err
+	}
+	if fsys.indexes == nil {
+		fsys.indexes = map[string][]string{}
+	}
+	fsys.indexes[fsys.normal(dir)] = names
+	return nil
+}
+
+var defaultIndex = []string{"index.html", "index.htm", "index.xhtml"}
+
+// index returns the candidate names for the default document of a directory.
+func (fsys *FileSystem) index(dir string) []string {
+	dir = fsys.normal(dir)
+	for len(fsys.indexes) > 0 {
+		if names, ok := fsys.indexes[dir]; ok {
+			return names
 		}
-		http.FileServer(http.FS(fs)).ServeHTTP(w, r)
-	} else {
-		fsys.notFound(w, r)
+		if dir == "." {
+			break
+		}
+		dir = path.Dir(dir)
+	}
+	return defaultIndex
+}
+
+// indexFile returns the default document of a directory:
+// the first candidate that's a file, or the first candidate if none is.
+func (fsys *FileSystem) indexFile(dir string, visible visibility) string {
+	names := fsys.index(dir)
+	if len(names) == 0 {
+		return path.Join(dir, "index.html")
+	}
+	for _, name := range names {
+		if s, err := fsys.statVisible(path.Join(dir, name), visible); err == nil && !s.IsDir() {
+			return path.Join(dir, name)
+		}
+	}
+	return path.Join(dir, names[0])
+}
+
+// SetNotFound sets the handler called by ServeHTTP and ServeFile when a file isn't found,
+// instead of serving 404.html.
+// The handler can, e.g., return JSON errors for API paths, or delegate to a router.
+// Nil restores the default.
+func (fsys *FileSystem) SetNotFound(h http.Handler) error {
+	if err := fsys.checkSealed(); err != nil {
+		return err
+	}
+	fsys.notFoundHandler = h
+	return nil
+}
+
+// SetETagFunc sets the function that returns the entity tag of a file served by ServeHTTP, ServeFile and ServeContent,
+// instead of the base 36 hash of its content (e.g. a build ID and the file name, or a SHA prefix).
+// The tag is quoted, and made weak for compressed responses.
+// An empty tag disables the ETag header for the file.
+// Nil restores the default.
+func (fsys *FileSystem) SetETagFunc(f func(name string, info fs.FileInfo) string) error {
+	if err := fsys.checkSealed(); err != nil {
+		return err
 	}
+	fsys.etagFunc = f
+	return nil
+}
+
+// etag returns the entity tag of the named file.
+func (fsys *FileSystem) etag(name string, o object) string {
+	if fsys.etagFunc != nil {
+		return fsys.etagFunc(name, o)
+	}
+	return o.etag()
 }
 
 func (fsys *FileSystem) notFound(w http.ResponseWriter, r *http.Request) {
-	if o, ok := fsys.objs["404.html"]; ok {
+	fsys.notFoundVisible(w, r, nil)
+}
+
+// notFoundVisible is like notFound, but serves 404.html only if visible returns true for it.
+func (fsys *FileSystem) notFoundVisible(w http.ResponseWriter, r *http.Request, visible visibility) {
+	if fsys.notFoundHandler != nil {
+		fsys.notFoundHandler.ServeHTTP(w, r)
+	} else if o, ok := fsys.object("404.html"); ok && (visible == nil || visible("404.html", &o)) {
 		o.mime = "text/html; charset=utf-8"
-		o.hash = 0
+		served(w, r, "404.html", o)
 
-		var reader io.ReadSeeker
-		if o.setHeaders(w, r) {
+		var reader io.Reader
+		if raw := o.setHeaders(w, r, ""); !raw && o.raw != "" {
+			reader = strings.NewReader(o.raw)
+			w.Header().Set("Content-Length", strconv.Itoa(len(o.raw)))
+		} else if raw {
 			reader = strings.NewReader(o.data)
+			w.Header().Set("Content-Length", strconv.Itoa(len(o.data)))
 		} else {
-			reader = &zfile{object: o}
+			z := &zfile{object: o}
+			defer z.Close()
+			reader = z
+			w.Header().Set("Content-Length", strconv.Itoa(o.size))
 		}
 		w.WriteHeader(http.StatusNotFound)
 		if r.Method != "HEAD" {
@@ -102,15 +310,57 @@ func (fsys *FileSystem) notFound(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func (o object) setHeaders(w http.ResponseWriter, r *http.Request) (raw bool) {
+// requestName returns the file name for a request.
+func requestName(r *http.Request) string {
+	return pathName(requestPath(r))
+}
+
+// requestPath returns the request path, making it absolute.
+func requestPath(r *http.Request) string {
+	// same transform as http.FileServer.ServeHTTP()
+	upath := r.URL.Path
+	if !strings.HasPrefix(upath, "/") {
+		upath = "/" + upath
+		r.URL.Path = upath
+	}
+	return upath
+}
+
+// pathName returns the file name for a URL path.
+func pathName(upath string) string {
+	upath = path.Clean("/" + upath)
+
+	// same transform as http.FS.Open()
+	if upath == "/" {
+		return "."
+	}
+	return upath[1:]
+}
+
+// setRequestName sets the request path to the canonical path for name.
+func setRequestName(r *http.Request, name string) {
+	if name == "." {
+		r.URL.Path = "/"
+	} else {
+		r.URL.Path = "/" + name
+	}
+}
+
+// setHeaders sets the headers to serve o, with etag as its entity tag (if not empty),
+// and reports whether o.data is served as is.
+func (o object) setHeaders(w http.ResponseWriter, r *http.Request, etag string) (raw bool) {
 	raw = false
 	weak := false
 	header := w.Header()
+	for k, v := range o.head {
+		// clip, so appending doesn't modify o.head
+		header[k] = v[:len(v):len(v)]
+	}
 	if len(o.data) == o.size {
 		raw = true
 	} else {
 		header.Add("Vary", "Accept-Encoding")
-		if httpguts.HeaderValuesContainsToken(r.Header["Accept-Encoding"], "gzip") {
+		if acceptsGzip(r.Header["Accept-Encoding"]) {
 			header.Set("Content-Encoding", "gzip")
 			weak = true
 			raw = true
@@ -119,27 +369,20 @@ func (o object) setHeaders(w http.ResponseWriter, r *http.Request) (raw bool) {
 	if o.mime != "" {
 		header.Set("Content-Type", o.mime)
 	}
-	if o.hash != 0 {
-		if tag := strconv.FormatUint(uint64(o.hash), 36); weak {
-			header.Set("ETag", `W/"`+tag+`"`)
+	if etag != "" {
+		if weak {
+			header.Set("ETag", `W/"`+etag+`"`)
 		} else {
-			header.Set("ETag", `"`+tag+`"`)
+			header.Set("ETag", `"`+etag+`"`)
 		}
 	}
 	return
 }
 
-// Serve raw gzipped objects
-type rawFileSystem struct {
-	*FileSystem
-}
-
-func (fsys rawFileSystem) Open(name string) (fs.File, error) {
-	if o, ok := fsys.objs[name]; ok {
-		return file{o, strings.NewReader(o.data)}, nil
-	}
-	if d, ok := fsys.dirs[name]; ok {
-		return &dir{name: name, list: d, fsys: fsys.FileSystem}, nil
+// etag returns the default entity tag of o: its hash, in base 36.
+func (o object) etag() string {
+	if o.hash == 0 {
+		return ""
 	}
-	return nil, fs.ErrNotExist
+	return strconv.FormatUint(uint64(o.hash), 36)
 }
@@ -0,0 +1,813 @@
+package memfs_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/ncruces/go-fs/memfs"
+)
+
+func newHTTPTestFS(t testing.TB) *memfs.FileSystem {
+	fsys := memfs.Create()
+	modtime := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := fsys.Create("hi.txt", "text/plain", modtime, strings.NewReader("Hello, world!")); err != nil {
+		t.Fatal(err)
+	}
+	if err := fsys.CreateCompressed("dir/index.html", "", modtime, strings.NewReader(strings.Repeat("<p>Hello, world!</p>", 100)), gzip.BestCompression); err != nil {
+		t.Fatal(err)
+	}
+	return fsys
+}
+
+func TestFileSystem_ServeHTTP(t *testing.T) {
+	fsys := newHTTPTestFS(t)
+
+	tests := []struct {
+		name     string
+		path     string
+		header   map[string]string
+		status   int
+		body     string
+		location string
+	}{
+		{name: "file", path: "/hi.txt", status: 200, body: "Hello, world!"},
+		{name: "missing", path: "/missing", status: 404},
+		{name: "dir", path: "/dir", status: 301, location: "dir/"},
+		{name: "slash", path: "/hi.txt/", status: 301, location: "../hi.txt"},
+		{name: "index", path: "/dir/index.html", status: 301, location: "./"},
+		{name: "range", path: "/hi.txt", header: map[string]string{"Range": "bytes=7-11"}, status: 206, body: "world"},
+		{name: "suffix", path: "/hi.txt", header: map[string]string{"Range": "bytes=-6"}, status: 206, body: "world!"},
+		{name: "unsatisfiable", path: "/hi.txt", header: map[string]string{"Range": "bytes=20-"}, status: 416},
+		{name: "modified", path: "/hi.txt", header: map[string]string{"If-Modified-Since": "Wed, 01 Jan 2020 00:00:00 GMT"}, status: 304},
+		{name: "precondition", path: "/hi.txt", header: map[string]string{"If-Match": `"nope"`}, status: 412},
+		{name: "identity", path: "/dir/", status: 200, body: strings.Repeat("<p>Hello, world!</p>", 100)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", tt.path, nil)
+			for k, v := range tt.header {
+				r.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			fsys.ServeHTTP(w, r)
+			if w.Code != tt.status {
+				t.Errorf("got %d, want %d", w.Code, tt.status)
+			}
+			if tt.body != "" && w.Body.String() != tt.body {
+				t.Errorf("got %q, want %q", w.Body.String(), tt.body)
+			}
+			if got := w.Header().Get("Location"); got != tt.location {
+				t.Errorf("Location: got %q, want %q", got, tt.location)
+			}
+		})
+	}
+}
+
+func TestFileSystem_ServeHTTP_gzip(t *testing.T) {
+	fsys := newHTTPTestFS(t)
+
+	r := httptest.NewRequest("GET", "/dir/", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	fsys.ServeHTTP(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d", w.Code)
+	}
+	if got := w.Header().Get("Content-Encoding"); got != "gzip" {
+		t.Errorf("Content-Encoding: got %q", got)
+	}
+	etag := w.Header().Get("ETag")
+	if etag == "" {
+		t.Error("missing ETag")
+	}
+
+	r.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	fsys.ServeHTTP(w, r)
+	if w.Code != http.StatusNotModified {
+		t.Errorf("If-None-Match: got %d", w.Code)
+	}
+}
+
+func TestFileSystem_ServeHTTP_concurrent(t *testing.T) {
+	fsys := newHTTPTestFS(t)
+	want := strings.Repeat("<p>Hello, world!</p>", 100)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			r := httptest.NewRequest("GET", "/dir/", nil)
+			if i%2 == 0 {
+				r.Header.Set("Range", "bytes=20-39")
+			}
+			w := httptest.NewRecorder()
+			fsys.ServeHTTP(w, r)
+
+			switch {
+			case i%2 != 0 && w.Body.String() != want:
+				t.Errorf("got %q", w.Body.String())
+			case i%2 == 0 && w.Body.String() != want[20:40]:
+				t.Errorf("got %q", w.Body.String())
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestWithUncompressed(t *testing.T) {
+	content := strings.Repeat("<p>Hello, world!</p>", 100)
+	fsys := memfs.Create()
+	err := fsys.CreateFile("index.html", strings.NewReader(content),
+		memfs.WithCompression(gzip.BestCompression), memfs.WithUncompressed())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s := fsys.Stats(); s.Stored <= int64(len(content)) {
+		t.Errorf("got %d stored", s.Stored)
+	}
+	if data, err := fsys.ReadFile("index.html"); err != nil || string(data) != content {
+		t.Errorf("got %q, %v", data, err)
+	}
+
+	w := httptest.NewRecorder()
+	fsys.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Body.String() != content || w.Header().Get("Content-Encoding") != "" {
+		t.Errorf("got %q", w.Body.String())
+	}
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Range", "bytes=3-7")
+	w = httptest.NewRecorder()
+	fsys.ServeHTTP(w, r)
+	if w.Code != http.StatusPartialContent || w.Body.String() != content[3:8] {
+		t.Errorf("got %d, %q", w.Code, w.Body.String())
+	}
+
+	r = httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	w = httptest.NewRecorder()
+	fsys.ServeHTTP(w, r)
+	if w.Header().Get("Content-Encoding") != "gzip" || w.Body.Len() >= len(content) {
+		t.Errorf("got %q, %d bytes", w.Header().Get("Content-Encoding"), w.Body.Len())
+	}
+}
+
+func TestFileSystem_ServeHTTP_contentLength(t *testing.T) {
+	fsys := newHTTPTestFS(t)
+	if err := fsys.CreateCompressed("404.html", "", time.Now(), strings.NewReader(strings.Repeat("<p>Not found!</p>", 100)), gzip.BestCompression); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{"/hi.txt", "/dir/", "/missing"} {
+		for _, encoding := range []string{"", "gzip"} {
+			r := httptest.NewRequest("GET", path, nil)
+			r.Header.Set("Accept-Encoding", encoding)
+			w := httptest.NewRecorder()
+			fsys.ServeHTTP(w, r)
+
+			if got, want := w.Header().Get("Content-Length"), strconv.Itoa(w.Body.Len()); got != want {
+				t.Errorf("%s (%q): got %q, want %q", path, encoding, got, want)
+			}
+		}
+	}
+}
+
+func TestFileSystem_ServeHTTP_etag(t *testing.T) {
+	fsys := newHTTPTestFS(t)
+	fsys.CreateString("str.txt", "text/plain", time.Time{}, 0, 5, "hello")
+	if err := fsys.CreateCompressed("small.txt", "", time.Time{}, strings.NewReader("hello"), gzip.BestCompression); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{"/hi.txt", "/str.txt", "/small.txt", "/dir/"} {
+		w := httptest.NewRecorder()
+		fsys.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if w.Header().Get("ETag") == "" {
+			t.Errorf("%s: missing ETag", path)
+		}
+	}
+}
+
+func TestFileSystem_ServeHTTP_ifRange(t *testing.T) {
+	fsys := newHTTPTestFS(t)
+
+	get := func(path string, header map[string]string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", path, nil)
+		for k, v := range header {
+			r.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		fsys.ServeHTTP(w, r)
+		return w
+	}
+
+	etag := get("/hi.txt", nil).Header().Get("ETag")
+	gzipTag := get("/dir/", map[string]string{"Accept-Encoding": "gzip"}).Header().Get("ETag")
+
+	tests := []struct {
+		name    string
+		path    string
+		ifRange string
+		gzip    bool
+		status  int
+	}{
+		{name: "etag", path: "/hi.txt", ifRange: etag, status: 206},
+		{name: "stale etag", path: "/hi.txt", ifRange: `"stale"`, status: 200},
+		{name: "date", path: "/hi.txt", ifRange: "Wed, 01 Jan 2020 00:00:00 GMT", status: 206},
+		{name: "stale date", path: "/hi.txt", ifRange: "Tue, 31 Dec 2019 00:00:00 GMT", status: 200},
+		{name: "compressed etag", path: "/dir/", ifRange: strings.TrimPrefix(gzipTag, "W/"), status: 206},
+		{name: "weak etag", path: "/dir/", ifRange: gzipTag, gzip: true, status: 200},
+		{name: "gzip date", path: "/dir/", ifRange: "Wed, 01 Jan 2020 00:00:00 GMT", gzip: true, status: 206},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := map[string]string{"Range": "bytes=0-4", "If-Range": tt.ifRange}
+			if tt.gzip {
+				header["Accept-Encoding"] = "gzip"
+			}
+			if w := get(tt.path, header); w.Code != tt.status {
+				t.Errorf("got %d, want %d", w.Code, tt.status)
+			}
+		})
+	}
+}
+
+func TestStripPrefixHandler(t *testing.T) {
+	fsys := newHTTPTestFS(t)
+	fsys.CreateString("index.html", "text/html", time.Time{}, 0, 4, "home")
+	h := memfs.StripPrefixHandler("/static/", fsys)
+
+	tests := []struct {
+		path     string
+		status   int
+		location string
+	}{
+		{path: "/static/hi.txt", status: 200},
+		{path: "/static", status: 301, location: "static/"},
+		{path: "/static/", status: 200},
+		{path: "/static/dir", status: 301, location: "dir/"},
+		{path: "/static/dir/", status: 200},
+		{path: "/static/dir/index.html", status: 301, location: "./"},
+		{path: "/static/hi.txt/", status: 301, location: "../hi.txt"},
+		{path: "/staticky/hi.txt", status: 404},
+		{path: "/hi.txt", status: 404},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", tt.path, nil))
+		if w.Code != tt.status || w.Header().Get("Location") != tt.location {
+			t.Errorf("%s: got %d, %q", tt.path, w.Code, w.Header().Get("Location"))
+		}
+	}
+}
+
+func TestFileSystem_ServeFile(t *testing.T) {
+	fsys := newHTTPTestFS(t)
+
+	tests := []struct {
+		name     string
+		status   int
+		location string
+	}{
+		{name: "hi.txt", status: 200},
+		{name: "/hi.txt", status: 200},
+		{name: "dir/", status: 200},
+		{name: "dir", status: 301, location: "dir/"},
+		{name: "../hi.txt", status: 404},
+		{name: "dir/../hi.txt", status: 404},
+		{name: "..", status: 404},
+		{name: "\\evil.com", status: 404},
+		{name: "dir\\index.html", status: 404},
+		{name: "dir%2Findex.html", status: 404},
+		{name: "hi.txt\x00", status: 404},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		fsys.ServeFile(w, httptest.NewRequest("GET", "/some/route", nil), tt.name)
+		if w.Code != tt.status || w.Header().Get("Location") != tt.location {
+			t.Errorf("%q: got %d, %q", tt.name, w.Code, w.Header().Get("Location"))
+		}
+	}
+
+	for _, path := range []string{"/%5Cevil.com/", "/%5C%5Cevil.com", "/dir%5Cindex.html"} {
+		w := httptest.NewRecorder()
+		fsys.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if w.Code != http.StatusNotFound || w.Header().Get("Location") != "" {
+			t.Errorf("%s: got %d, %q", path, w.Code, w.Header().Get("Location"))
+		}
+	}
+}
+
+func TestServeFileFS(t *testing.T) {
+	tests := []struct {
+		fsys   fs.FS
+		name   string
+		status int
+	}{
+		{newHTTPTestFS(t), "hi.txt", 200},
+		{newHTTPTestFS(t), "dir/", 200},
+		{newHTTPTestFS(t), "../hi.txt", 404},
+		{fstest.MapFS{"hi.txt": {Data: []byte("Hello, world!")}}, "hi.txt", 200},
+		{fstest.MapFS{"hi.txt": {Data: []byte("Hello, world!")}}, "../hi.txt", 404},
+		{fstest.MapFS{"hi.txt": {Data: []byte("Hello, world!")}}, "missing.txt", 404},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		memfs.ServeFileFS(w, httptest.NewRequest("GET", "/some/route", nil), tt.fsys, tt.name)
+		if w.Code != tt.status {
+			t.Errorf("%T %q: got %d", tt.fsys, tt.name, w.Code)
+		}
+	}
+}
+
+func TestServeContent(t *testing.T) {
+	fsys := newHTTPTestFS(t)
+	want := strings.Repeat("<p>Hello, world!</p>", 100)
+
+	for _, encoding := range []string{"gzip", ""} {
+		f, err := fsys.Open("dir/index.html")
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Accept-Encoding", encoding)
+		w := httptest.NewRecorder()
+		memfs.ServeContent(w, r, "page.html", time.Time{}, f.(io.ReadSeeker))
+		f.Close()
+
+		if got := w.Header().Get("Content-Encoding"); got != encoding {
+			t.Errorf("Content-Encoding: got %q", got)
+		}
+		if encoding == "" && w.Body.String() != want {
+			t.Errorf("got %q", w.Body.String())
+		}
+	}
+
+	w := httptest.NewRecorder()
+	memfs.ServeContent(w, httptest.NewRequest("GET", "/", nil), "hi.txt", time.Time{}, strings.NewReader("hi"))
+	if w.Code != http.StatusOK || w.Body.String() != "hi" {
+		t.Errorf("got %d, %q", w.Code, w.Body.String())
+	}
+}
+
+func TestFileSystem_SetVersionParam(t *testing.T) {
+	fsys := newHTTPTestFS(t)
+	fsys.SetVersionParam("v")
+
+	version, ok := fsys.Version("hi.txt")
+	if !ok || version == "" {
+		t.Fatalf("got %q, %v", version, ok)
+	}
+	if _, ok := fsys.Version("dir"); ok {
+		t.Error("want false")
+	}
+
+	for query, want := range map[string]string{
+		"?v=" + version: "public, max-age=31536000, immutable",
+		"?v=stale":      "",
+		"":              "",
+	} {
+		w := httptest.NewRecorder()
+		fsys.ServeHTTP(w, httptest.NewRequest("GET", "/hi.txt"+query, nil))
+		if w.Code != http.StatusOK {
+			t.Errorf("%q: got %d", query, w.Code)
+		}
+		if got := w.Header().Get("Cache-Control"); got != want {
+			t.Errorf("%q: got %q", query, got)
+		}
+	}
+}
+
+func TestFileSystem_SetETagFunc(t *testing.T) {
+	fsys := newHTTPTestFS(t)
+	fsys.SetETagFunc(func(name string, info fs.FileInfo) string {
+		if name == "hi.txt" {
+			return ""
+		}
+		return "build1-" + name
+	})
+
+	tests := []struct {
+		path     string
+		encoding string
+		etag     string
+	}{
+		{"/hi.txt", "", ""},
+		{"/dir/", "", `"build1-dir/index.html"`},
+		{"/dir/", "gzip", `W/"build1-dir/index.html"`},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", tt.path, nil)
+		r.Header.Set("Accept-Encoding", tt.encoding)
+		w := httptest.NewRecorder()
+		fsys.ServeHTTP(w, r)
+		if got := w.Header().Get("ETag"); got != tt.etag {
+			t.Errorf("%s %q: got %q", tt.path, tt.encoding, got)
+		}
+	}
+
+	if v, ok := fsys.Version("dir/index.html"); !ok || v != "build1-dir/index.html" {
+		t.Errorf("got %q, %v", v, ok)
+	}
+	if _, ok := fsys.Version("hi.txt"); ok {
+		t.Error("want false")
+	}
+}
+
+func TestFileSystem_SetImmutable(t *testing.T) {
+	fsys, err := memfs.NewBuilder().
+		Add("index.html", "<h1>Hello</h1>").
+		Add("app.3f2a9c1b.js", "console.log('hello')").
+		Add("app.js", "console.log('hello')").
+		Add("vendor.0123abcd.js", "", memfs.WithHeaders(http.Header{"Cache-Control": {"private"}})).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fsys.SetImmutable(regexp.MustCompile(`[.-][0-9a-f]{8,}\.\w+$`).MatchString)
+
+	for path, want := range map[string]string{
+		"/":                   "no-cache",
+		"/app.3f2a9c1b.js":    "public, max-age=31536000, immutable",
+		"/app.js":             "",
+		"/vendor.0123abcd.js": "private",
+	} {
+		w := httptest.NewRecorder()
+		fsys.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if got := w.Header().Get("Cache-Control"); got != want {
+			t.Errorf("%s: got %q", path, got)
+		}
+	}
+}
+
+func TestFileSystem_SetLastModified(t *testing.T) {
+	fsys := memfs.Create()
+	modtime := time.Date(2020, 1, 1, 12, 34, 56, 0, time.UTC)
+	if err := fsys.Create("hi.txt", "text/plain", modtime, strings.NewReader("Hello, world!")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		precision time.Duration
+		want      string
+	}{
+		{0, "Wed, 01 Jan 2020 12:34:56 GMT"},
+		{time.Hour, "Wed, 01 Jan 2020 12:00:00 GMT"},
+		{-1, ""},
+	}
+	for _, tt := range tests {
+		fsys.SetLastModified(tt.precision)
+		w := httptest.NewRecorder()
+		fsys.ServeHTTP(w, httptest.NewRequest("GET", "/hi.txt", nil))
+		if got := w.Header().Get("Last-Modified"); got != tt.want {
+			t.Errorf("%v: got %q", tt.precision, got)
+		}
+	}
+}
+
+func TestFileSystem_SetLanguages(t *testing.T) {
+	fsys, err := memfs.NewBuilder().
+		Add("index.en.html", "Hello").
+		Add("index.pt.html", "Olá").
+		Add("page.html", "Page").
+		Add("page.pt-BR.html", "Página").
+		Add("hi.txt", "Hi").
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fsys.SetLanguages("en", "pt", "pt-BR")
+
+	tests := []struct {
+		path     string
+		accept   string
+		body     string
+		language string
+	}{
+		{"/", "", "Hello", "en"},
+		{"/", "pt-PT, en;q=0.5", "Olá", "pt"},
+		{"/", "fr, en;q=0.1, pt;q=0.5", "Olá", "pt"},
+		{"/", "fr", "Hello", "en"},
+		{"/page.html", "pt-BR", "Página", "pt-BR"},
+		{"/page.html", "pt-PT", "Page", ""},
+		{"/page.html", "", "Page", ""},
+		{"/index.pt.html", "en", "Olá", ""},
+		{"/hi.txt", "pt", "Hi", ""},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", tt.path, nil)
+		r.Header.Set("Accept-Language", tt.accept)
+		w := httptest.NewRecorder()
+		fsys.ServeHTTP(w, r)
+		if w.Code != http.StatusOK || w.Body.String() != tt.body {
+			t.Errorf("%s %q: got %d, %q", tt.path, tt.accept, w.Code, w.Body.String())
+		}
+		if got := w.Header().Get("Content-Language"); got != tt.language {
+			t.Errorf("%s %q: got Content-Language %q", tt.path, tt.accept, got)
+		}
+		vary := strings.Contains(strings.Join(w.Header().Values("Vary"), ","), "Accept-Language")
+		if want := tt.path != "/hi.txt" && tt.path != "/index.pt.html"; vary != want {
+			t.Errorf("%s %q: got Vary %q", tt.path, tt.accept, w.Header().Values("Vary"))
+		}
+	}
+
+	w := httptest.NewRecorder()
+	fsys.ServeHTTP(w, httptest.NewRequest("GET", "/page.html/", nil))
+	if w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != "../page.html" {
+		t.Errorf("got %d, %q", w.Code, w.Header().Get("Location"))
+	}
+}
+
+func TestFileSystem_SetNotFound(t *testing.T) {
+	fsys := newHTTPTestFS(t)
+	fsys.SetNotFound(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		io.WriteString(w, `{"error":"not found"}`)
+	}))
+
+	w := httptest.NewRecorder()
+	fsys.ServeHTTP(w, httptest.NewRequest("GET", "/api/missing", nil))
+	if w.Code != http.StatusNotFound || w.Body.String() != `{"error":"not found"}` {
+		t.Errorf("got %d, %q", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	fsys.ServeHTTP(w, httptest.NewRequest("GET", "/hi.txt", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("got %d", w.Code)
+	}
+}
+
+func TestFileSystem_SetIndex(t *testing.T) {
+	fsys := newHTTPTestFS(t)
+	fsys.CreateString("docs/README.html", "text/html", time.Time{}, 0, 6, "readme")
+	fsys.CreateString("docs/index.html", "text/html", time.Time{}, 0, 5, "index")
+	fsys.CreateString("docs/sub/README.html", "text/html", time.Time{}, 0, 3, "sub")
+	fsys.CreateString("legacy/index.htm", "text/html", time.Time{}, 0, 3, "htm")
+	fsys.CreateString("legacy/index.xhtml", "text/html", time.Time{}, 0, 5, "xhtml")
+	fsys.SetIndex("docs", "README.html")
+
+	tests := []struct {
+		path     string
+		status   int
+		body     string
+		location string
+	}{
+		{path: "/docs/", status: 200, body: "readme"},
+		{path: "/docs/sub/", status: 200, body: "sub"},
+		{path: "/docs/README.html", status: 301, location: "./"},
+		{path: "/docs/index.html", status: 200, body: "index"},
+		{path: "/dir/index.html", status: 301, location: "./"},
+		{path: "/legacy/", status: 200, body: "htm"},
+		{path: "/legacy/index.htm", status: 301, location: "./"},
+		{path: "/legacy/index.xhtml", status: 200, body: "xhtml"},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		fsys.ServeHTTP(w, httptest.NewRequest("GET", tt.path, nil))
+		if w.Code != tt.status || tt.body != "" && w.Body.String() != tt.body || w.Header().Get("Location") != tt.location {
+			t.Errorf("%s: got %d, %q, %q", tt.path, w.Code, w.Body.String(), w.Header().Get("Location"))
+		}
+	}
+}
+
+func TestFileSystem_CreateFile(t *testing.T) {
+	fsys := memfs.Create()
+	err := fsys.CreateFile("app.wasm", strings.NewReader("\x00asm"),
+		memfs.WithMIME("application/wasm"),
+		memfs.WithHash(12345),
+		memfs.WithHeaders(http.Header{"cross-origin-embedder-policy": {"require-corp"}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	fsys.ServeHTTP(w, httptest.NewRequest("GET", "/app.wasm", nil))
+	if got := w.Header().Get("Content-Type"); got != "application/wasm" {
+		t.Errorf("Content-Type: got %q", got)
+	}
+	if got := w.Header().Get("ETag"); got != `"9ix"` {
+		t.Errorf("ETag: got %q", got)
+	}
+	if got := w.Header().Get("Cross-Origin-Embedder-Policy"); got != "require-corp" {
+		t.Errorf("Cross-Origin-Embedder-Policy: got %q", got)
+	}
+}
+
+func TestFileSystem_SetHeaders(t *testing.T) {
+	fsys := newHTTPTestFS(t)
+	if err := fsys.SetHeaders("hi.txt", http.Header{"service-worker-allowed": {"/"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := fsys.SetHeaders("dir", http.Header{}); err == nil {
+		t.Error("want error for directory")
+	}
+
+	w := httptest.NewRecorder()
+	fsys.ServeHTTP(w, httptest.NewRequest("GET", "/hi.txt", nil))
+	if got := w.Header().Get("Service-Worker-Allowed"); got != "/" {
+		t.Errorf("Service-Worker-Allowed: got %q", got)
+	}
+	if w.Body.String() != "Hello, world!" {
+		t.Errorf("got %q", w.Body.String())
+	}
+
+	fsys.SetHeaders("hi.txt", nil)
+	w = httptest.NewRecorder()
+	fsys.ServeHTTP(w, httptest.NewRequest("GET", "/hi.txt", nil))
+	if got := w.Header().Get("Service-Worker-Allowed"); got != "" {
+		t.Errorf("Service-Worker-Allowed: got %q", got)
+	}
+}
+
+func TestFileSystem_SetImageFormats(t *testing.T) {
+	fsys := newHTTPTestFS(t)
+	fsys.CreateString("photo.jpg", "image/jpeg", time.Time{}, 0, 3, "jpg")
+	fsys.CreateString("photo.webp", "image/webp", time.Time{}, 0, 4, "webp")
+	fsys.CreateString("photo.avif", "image/avif", time.Time{}, 0, 4, "avif")
+	fsys.CreateString("logo.png", "image/png", time.Time{}, 0, 3, "png")
+	fsys.SetImageFormats(".avif", ".webp")
+
+	tests := []struct {
+		path   string
+		accept string
+		body   string
+		vary   bool
+	}{
+		{path: "/photo.jpg", body: "jpg", vary: true},
+		{path: "/photo.jpg", accept: "image/webp,*/*", body: "webp", vary: true},
+		{path: "/photo.jpg", accept: "image/avif,image/webp,*/*", body: "avif", vary: true},
+		{path: "/photo.jpg", accept: "image/avif;q=0, image/webp;q=0.8", body: "webp", vary: true},
+		{path: "/photo.webp", accept: "image/avif", body: "webp"},
+		{path: "/logo.png", accept: "image/avif,image/webp", body: "png"},
+		{path: "/hi.txt", accept: "image/avif,image/webp", body: "Hello, world!"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", tt.path, nil)
+		r.Header.Set("Accept", tt.accept)
+		w := httptest.NewRecorder()
+		fsys.ServeHTTP(w, r)
+		vary := false
+		for _, v := range w.Header().Values("Vary") {
+			vary = vary || v == "Accept"
+		}
+		if w.Body.String() != tt.body || vary != tt.vary {
+			t.Errorf("%s (%q): got %q, Vary %q", tt.path, tt.accept, w.Body.String(), w.Header().Values("Vary"))
+		}
+	}
+}
+
+func TestFileSystem_HTTP(t *testing.T) {
+	fsys := newHTTPTestFS(t)
+	html := strings.Repeat("<p>Hello, world!</p>", 100)
+
+	w := httptest.NewRecorder()
+	http.FileServer(fsys.HTTP()).ServeHTTP(w, httptest.NewRequest("GET", "/dir/index.html", nil))
+	if w.Code != http.StatusMovedPermanently {
+		t.Errorf("got %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	http.FileServer(fsys.HTTP()).ServeHTTP(w, httptest.NewRequest("GET", "/dir/", nil))
+	if w.Body.String() != html {
+		t.Errorf("got %q", w.Body.String())
+	}
+
+	raw := fsys.HTTPRaw()
+	f, err := raw.Open("/dir/index.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Sys() != "gzip" || info.Size() >= int64(len(html)) {
+		t.Errorf("got %v, %d", info.Sys(), info.Size())
+	}
+	z, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err := io.ReadAll(z); err != nil || string(data) != html {
+		t.Errorf("got %q, %v", data, err)
+	}
+
+	f, err = raw.Open("/hi.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if info, err := f.Stat(); err != nil || info.Sys().(*memfs.SysInfo).Encoding != "" {
+		t.Errorf("got %v, %v", info, err)
+	}
+	if data, err := io.ReadAll(f); err != nil || string(data) != "Hello, world!" {
+		t.Errorf("got %q, %v", data, err)
+	}
+}
+
+func BenchmarkFileSystem_ServeHTTP(b *testing.B) {
+	fsys := newHTTPTestFS(b)
+	r := httptest.NewRequest("GET", "/dir/", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		fsys.ServeHTTP(httptest.NewRecorder(), r)
+	}
+}
+
+func TestFileSystem_SetPreload(t *testing.T) {
+	fsys, err := memfs.NewBuilder().
+		Add("index.html", "<p>Hello</p>").
+		Add("app.css", "p{}").
+		Add("app.js", "alert()").
+		Add("font.woff2", "wOF2").
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"app.css", "app.js", "font.woff2"} {
+		if err := fsys.Tag(name, "critical"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fsys.SetPreload("critical")
+
+	w := httptest.NewRecorder()
+	fsys.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	want := []string{
+		"</app.css>; rel=preload; as=style",
+		"</app.js>; rel=preload; as=script",
+		"</font.woff2>; rel=preload; as=font; crossorigin",
+	}
+	if got := w.Header()["Link"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	w = httptest.NewRecorder()
+	fsys.ServeHTTP(w, httptest.NewRequest("GET", "/app.css", nil))
+	if got := w.Header()["Link"]; got != nil {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestFileSystem_ServeHTTP_corrupt(t *testing.T) {
+	html := strings.Repeat("<p>Hello, world!</p>", 100)
+	var buf bytes.Buffer
+	z := gzip.NewWriter(&buf)
+	z.Write([]byte(html))
+	z.Close()
+	gz := buf.String()
+	bad := []byte(gz)
+	bad[len(bad)-8] ^= 1 // CRC-32
+
+	fsys := memfs.Create()
+	fsys.CreateString("bad/crc.html", "text/html", time.Time{}, 0, len(html), string(bad))
+	fsys.CreateString("bad/short.html", "text/html", time.Time{}, 0, len(html)+1, gz)
+	fsys.CreateString("good.html", "text/html", time.Time{}, 0, len(html), gz)
+
+	for _, path := range []string{"/bad/crc.html", "/bad/short.html"} {
+		r := httptest.NewRequest("GET", path, nil)
+		w := httptest.NewRecorder()
+		fsys.ServeHTTP(w, r)
+		if w.Code != http.StatusInternalServerError {
+			t.Errorf("GET %s: got %d, want %d", path, w.Code, http.StatusInternalServerError)
+		}
+		if got := w.Header().Get("Content-Length"); got == strconv.Itoa(len(html)) {
+			t.Errorf("GET %s: got Content-Length %s", path, got)
+		}
+
+		r = httptest.NewRequest("GET", path, nil)
+		r.Header.Set("Accept-Encoding", "gzip")
+		w = httptest.NewRecorder()
+		fsys.ServeHTTP(w, r)
+		if w.Code != http.StatusOK {
+			t.Errorf("GET %s (gzip): got %d, want %d", path, w.Code, http.StatusOK)
+		}
+	}
+
+	r := httptest.NewRequest("GET", "/good.html", nil)
+	w := httptest.NewRecorder()
+	fsys.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || w.Body.String() != html {
+		t.Errorf("GET /good.html: got %d, %d bytes", w.Code, w.Body.Len())
+	}
+}
@@ -0,0 +1,52 @@
+package memfs
+
+import (
+	"io/fs"
+	"net/http"
+	"strings"
+)
+
+// HTTP returns an http.FileSystem for fsys, for routers and libraries that still take one.
+// Like http.FS, compressed files are decompressed on-the-fly.
+func (fsys *FileSystem) HTTP() http.FileSystem {
+	return http.FS(fsys)
+}
+
+// HTTPRaw returns an http.FileSystem that opens files as stored in memory:
+// compressed files are read as gzip streams, and not decompressed.
+// Their FileInfo reports the compressed size, and Sys returns "gzip",
+// so the caller can set Content-Encoding.
+func (fsys *FileSystem) HTTPRaw() http.FileSystem {
+	return http.FS(rawFS{fsys})
+}
+
+type rawFS struct {
+	fsys *FileSystem
+}
+
+func (r rawFS) Open(name string) (fs.File, error) {
+	if fs.ValidPath(name) {
+		if o, ok := r.fsys.object(name); ok && len(o.data) != o.size {
+			o.size = len(o.data)
+			return rawFile{file{o, strings.NewReader(o.data)}}, nil
+		}
+	}
+	return r.fsys.Open(name)
+}
+
+// A compressed file, read as stored.
+type rawFile struct {
+	file
+}
+
+func (f rawFile) Sys() interface{} {
+	return "gzip"
+}
+
+func (f rawFile) Stat() (fs.FileInfo, error) {
+	return f, nil
+}
+
+// Check interface implementations
+var _ fs.FS = rawFS{}
+var _ http.File = rawFile{}
@@ -0,0 +1,84 @@
+package memfs
+
+import (
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// SetImageFormats enables image format negotiation for ServeHTTP and ServeFile.
+// A request for an image, e.g. photo.jpg, is served an alternative with one of exts,
+// e.g. photo.avif, if it exists and the Accept header explicitly allows its MIME type.
+// Alternatives are tried in order, and responses Vary on Accept.
+//
+// Usage:
+//
+//	assets.SetImageFormats(".avif", ".webp")
+func (fsys *FileSystem) SetImageFormats(exts ...string) error {
+	if err := fsys.checkSealed(); err != nil {
+		return err
+	}
+	fsys.imageFormats = exts
+	return nil
+}
+
+// negotiateImage returns the name of the image format alternative to serve for name.
+func (fsys *FileSystem) negotiateImage(w http.ResponseWriter, r *http.Request, name string, visible visibility) string {
+	if len(fsys.imageFormats) == 0 {
+		return name
+	}
+	ext := path.Ext(name)
+	for _, e := range fsys.imageFormats {
+		if strings.EqualFold(e, ext) {
+			return name
+		}
+	}
+	if o, ok := fsys.object(name); !ok || !strings.HasPrefix(o.mime, "image/") || strings.HasPrefix(o.mime, "image/svg") {
+		return name
+	}
+
+	vary := false
+	base := strings.TrimSuffix(name, ext)
+	for _, ext := range fsys.imageFormats {
+		alt := base + ext
+		if s, err := fsys.statVisible(alt, visible); err != nil || s.IsDir() {
+			continue
+		}
+		vary = true
+		if accepts(r, mime.TypeByExtension(ext)) {
+			name = alt
+			break
+		}
+	}
+	if vary {
+		w.Header().Add("Vary", "Accept")
+	}
+	return name
+}
+
+// accepts reports if the Accept header of r explicitly allows mimetype (with a non-zero quality).
+func accepts(r *http.Request, mimetype string) bool {
+	mimetype, _, _ = strings.Cut(mimetype, ";")
+	if mimetype == "" {
+		return false
+	}
+	for _, header := range r.Header.Values("Accept") {
+		for _, accept := range strings.Split(header, ",") {
+			typ, params, _ := strings.Cut(accept, ";")
+			if !strings.EqualFold(strings.TrimSpace(typ), mimetype) {
+				continue
+			}
+			for _, param := range strings.Split(params, ";") {
+				if q, ok := strings.CutPrefix(strings.TrimSpace(param), "q="); ok {
+					if f, err := strconv.ParseFloat(q, 64); err == nil && f == 0 {
+						return false
+					}
+				}
+			}
+			return true
+		}
+	}
+	return false
+}
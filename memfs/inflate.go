@@ -0,0 +1,66 @@
+package memfs
+
+import (
+	"io"
+	"strings"
+	"sync"
+	"unsafe"
+)
+
+// Decompressions in progress, by content.
+var inflating = struct {
+	sync.Mutex
+	calls map[*byte]*inflateCall
+	count int64
+}{calls: map[*byte]*inflateCall{}}
+
+type inflateCall struct {
+	wg   sync.WaitGroup
+	data string
+	err  error
+}
+
+// inflate returns the decompressed content of a compressed object.
+// Concurrent decompressions of the same content are coalesced,
+// so many clients that don't accept gzip can be served the same large file
+// while decompressing it only once.
+func (o object) inflate() (string, error) {
+	if o.raw != "" {
+		return o.raw, nil
+	}
+	key := unsafe.StringData(o.data)
+
+	inflating.Lock()
+	if call, ok := inflating.calls[key]; ok {
+		inflating.Unlock()
+		call.wg.Wait()
+		return call.data, call.err
+	}
+	call := new(inflateCall)
+	call.wg.Add(1)
+	inflating.calls[key] = call
+	inflating.count++
+	inflating.Unlock()
+
+	var buf strings.Builder
+	buf.Grow(o.size)
+	z := &zfile{object: o}
+	_, call.err = io.Copy(&buf, z)
+	z.Close()
+	call.data = buf.String()
+	call.wg.Done()
+
+	inflating.Lock()
+	delete(inflating.calls, key)
+	inflating.Unlock()
+	return call.data, call.err
+}
+
+// Decompressions returns the number of times compressed content was decompressed,
+// to serve HTTP clients that don't accept gzip, by all file systems.
+// Coalesced decompressions count once.
+func Decompressions() int64 {
+	inflating.Lock()
+	defer inflating.Unlock()
+	return inflating.count
+}
@@ -0,0 +1,186 @@
+package memfs
+
+import (
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"sort"
+	"unsafe"
+)
+
+// Hash returns the stored hash of the named file's content (a CRC-32),
+// the hash its ETag is derived from.
+// It reports false if name isn't a file, or has no stored hash.
+func (fsys *FileSystem) Hash(name string) (uint32, bool) {
+	o, ok := fsys.object(name)
+	return o.hash, ok && o.hash != 0
+}
+
+// FindByHash returns the names of the files with the given content hash (as returned by Hash), sorted,
+// e.g. to check if the same content is already stored under another name.
+// Files in mounted file systems, and lazy files not yet produced, are not found.
+func (fsys *FileSystem) FindByHash(hash uint32) []string {
+	if hash == 0 {
+		return nil
+	}
+	if fsys.mtx != nil {
+		fsys.mtx.RLock()
+		defer fsys.mtx.RUnlock()
+	}
+	index := fsys.hashes.Load()
+	if index == nil {
+		// build under the read lock, changes reset the index under the write lock
+		m := map[uint32][]string{}
+		for name, n := range fsys.files {
+			o := n.obj
+			if n.lazy != nil {
+				o, _ = n.lazy.produced()
+			}
+			if o.hash != 0 {
+				m[o.hash] = append(m[o.hash], name)
+			}
+		}
+		for _, names := range m {
+			sort.Strings(names)
+		}
+		index = &m
+		fsys.hashes.Store(index)
+	}
+	return append([]string(nil), (*index)[hash]...)
+}
+
+// Integrity returns the Subresource Integrity checksum (sha384-…) of the named file,
+// for the integrity attribute of script and link elements.
+//
+// Usage:
+//
+//	tmpl := template.New("").Funcs(template.FuncMap{"integrity": assets.Integrity})
+//	…
+//	<script src="/js/app.js" integrity="{{integrity "js/app.js"}}" crossorigin="anonymous"></script>
+func (fsys *FileSystem) Integrity(name string) (string, error) {
+	n, rel, err := fsys.lookup(name)
+	if err == nil && n.mount != nil {
+		if m, ok := n.mount.(*FileSystem); ok {
+			return m.Integrity(rel)
+		}
+	}
+	if err != nil || n.dir || n.mount != nil {
+		// directories, or files in other mounted file systems
+		data, err := fsys.ReadFile(name)
+		if err != nil {
+			return "", err
+		}
+		return integrity(data), nil
+	}
+
+	// cached on the node, which is replaced if content changes
+	if n.sri != nil {
+		if sri := n.sri.Load(); sri != nil {
+			return *sri, nil
+		}
+	}
+
+	o := n.obj
+	h := sha512.New384()
+	if len(o.data) == o.size {
+		io.WriteString(h, o.data)
+	} else if o.raw != "" {
+		io.WriteString(h, o.raw)
+	} else {
+		f := &zfile{object: o}
+		defer f.Close()
+		if _, err := io.Copy(h, f); err != nil {
+			return "", err
+		}
+	}
+	sri := "sha384-" + base64.StdEncoding.EncodeToString(h.Sum(nil))
+	if n.sri != nil {
+		n.sri.Store(&sri)
+	}
+	return sri, nil
+}
+
+func integrity(data []byte) string {
+	sum := sha512.Sum384(data)
+	return "sha384-" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+var errSize = errors.New("size mismatch")
+var errChecksum = errors.New("checksum mismatch")
+
+// Verify decompresses every compressed file, and checks the content of every file
+// against its stored hash (as returned by Hash) and size,
+// e.g. to validate generated or loaded files at startup.
+// Reports every mismatch, as a joined error of *fs.PathError.
+// Files in mounted file systems, and lazy files not yet produced, are not verified.
+func (fsys *FileSystem) Verify() error {
+	var names []string
+	var objs []object
+	func() {
+		if fsys.mtx != nil {
+			fsys.mtx.RLock()
+			defer fsys.mtx.RUnlock()
+		}
+		fsys.root.walk(".", func(name string, f *node) {
+			o := f.obj
+			if f.lazy != nil {
+				var ok bool
+				if o, ok = f.lazy.produced(); !ok {
+					return
+				}
+			}
+			if o.hash != 0 || len(o.data) != o.size {
+				names = append(names, name)
+				objs = append(objs, o)
+			}
+		})
+	}()
+
+	var errs []error
+	for i, o := range objs {
+		if err := o.verify(); err != nil {
+			errs = append(errs, &fs.PathError{Op: "verify", Path: names[i], Err: err})
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// verify checks the content of an object against its size and hash.
+// The hash is either the CRC-32 (IEEE) in the gzip trailer of compressed content,
+// which decompressing checks, or the CRC-32C (Castagnoli) of the content.
+func (o object) verify() error {
+	crc := crc32.New(crc32.MakeTable(crc32.Castagnoli))
+	trailer := uint32(0)
+	if len(o.data) == o.size {
+		io.WriteString(crc, o.data)
+	} else {
+		gzip, err := newGzipReader(o.data)
+		if err != nil {
+			return err
+		}
+		defer gzip.release()
+		n, err := io.Copy(crc, gzip)
+		if err != nil {
+			return err
+		}
+		if n != int64(o.size) {
+			return errSize
+		}
+		trailer = getHash(unsafe.Slice(unsafe.StringData(o.data), len(o.data)), int64(o.size))
+	}
+	if o.raw != "" {
+		if len(o.raw) != o.size {
+			return errSize
+		}
+		if crc32.Checksum([]byte(o.raw), crc32.MakeTable(crc32.Castagnoli)) != crc.Sum32() {
+			return errChecksum
+		}
+	}
+	if o.hash != 0 && o.hash != crc.Sum32() && o.hash != trailer {
+		return errChecksum
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package memfs
+
+import (
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SetLanguages enables language negotiation for ServeHTTP and ServeFile.
+// A request for a page, e.g. page.html, is served the alternative for the language
+// the Accept-Language header prefers, e.g. page.pt.html, among those that exist for langs.
+// If none is acceptable, page.html is served if it exists, or else the first alternative in langs order.
+// Responses Vary on Accept-Language, and alternatives have a Content-Language.
+//
+// Usage:
+//
+//	assets.SetLanguages("en", "pt", "pt-BR")
+func (fsys *FileSystem) SetLanguages(langs ...string) error {
+	if err := fsys.checkSealed(); err != nil {
+		return err
+	}
+	fsys.languages = langs
+	return nil
+}
+
+// negotiateLanguage returns the name of the language alternative to serve for name.
+func (fsys *FileSystem) negotiateLanguage(w http.ResponseWriter, r *http.Request, name string, visible visibility) string {
+	if len(fsys.languages) == 0 {
+		return name
+	}
+
+	ext := path.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	var alts []string
+	for _, lang := range fsys.languages {
+		if s, err := fsys.statVisible(base+"."+lang+ext, visible); err == nil && !s.IsDir() {
+			alts = append(alts, lang)
+		}
+	}
+	if len(alts) == 0 {
+		return name
+	}
+	w.Header().Add("Vary", "Accept-Language")
+
+	lang := matchLanguage(r, alts)
+	if lang == "" {
+		if s, err := fsys.statVisible(name, visible); err == nil && !s.IsDir() {
+			return name
+		}
+		lang = alts[0]
+	}
+	w.Header().Set("Content-Language", lang)
+	return base + "." + lang + ext
+}
+
+// matchLanguage returns the language in langs the Accept-Language header of r prefers, if any.
+// A tag like pt-BR matches pt-BR, or pt if pt-BR is not in langs.
+func matchLanguage(r *http.Request, langs []string) string {
+	type accept struct {
+		tag string
+		q   float64
+	}
+	var accepts []accept
+	for _, header := range r.Header.Values("Accept-Language") {
+		for _, a := range strings.Split(header, ",") {
+			tag, params, _ := strings.Cut(a, ";")
+			q := 1.0
+			if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+				if f, err := strconv.ParseFloat(v, 64); err == nil {
+					q = f
+				}
+			}
+			if tag = strings.TrimSpace(tag); tag != "" && tag != "*" && q > 0 {
+				accepts = append(accepts, accept{tag, q})
+			}
+		}
+	}
+	sort.SliceStable(accepts, func(i, j int) bool { return accepts[i].q > accepts[j].q })
+
+	for _, a := range accepts {
+		for tag := a.tag; tag != ""; {
+			for _, lang := range langs {
+				if strings.EqualFold(lang, tag) {
+					return lang
+				}
+			}
+			i := strings.LastIndexByte(tag, '-')
+			if i < 0 {
+				break
+			}
+			tag = tag[:i]
+		}
+	}
+	return ""
+}
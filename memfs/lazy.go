@@ -0,0 +1,80 @@
+package memfs
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/fs"
+	"sync"
+	"sync/atomic"
+)
+
+// CreateFunc creates a file with content produced by fn on first access,
+// e.g. a computed version.json, or a bundle of other files in the file system.
+// The content is then kept, compressed as configured by opts,
+// and fn is only called again if it fails.
+// Transforms apply to the produced content.
+// Overwrites an existing file (but not a directory).
+// Sniffs the MIME type if none is provided.
+//
+// Until its content is produced, the file has size zero in directory listings,
+// and it isn't included in Stats.
+func (fsys *FileSystem) CreateFunc(name, mimetype string, fn func() ([]byte, error), opts ...FileOption) error {
+	o := fileOptions{mime: mimetype, level: gzip.NoCompression}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if !fs.ValidPath(name) {
+		return fs.ErrInvalid
+	}
+	if err := fsys.canCreate(name); err != nil {
+		return err
+	}
+
+	lazy := &lazyFile{fsys: fsys, name: name, fn: fn, opts: o}
+	return fsys.insert(name, &node{obj: object{head: o.header}, lazy: lazy}, false)
+}
+
+// A file with content produced on first access.
+type lazyFile struct {
+	fsys *FileSystem
+	name string
+	fn   func() ([]byte, error)
+	opts fileOptions
+
+	mtx sync.Mutex
+	obj atomic.Pointer[object]
+}
+
+// get returns the file contents, producing them if needed.
+// Concurrent calls wait for the same fn call.
+func (l *lazyFile) get() (object, error) {
+	if obj := l.obj.Load(); obj != nil {
+		return *obj, nil
+	}
+
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	if obj := l.obj.Load(); obj != nil {
+		return *obj, nil
+	}
+	data, err := l.fn()
+	if err != nil {
+		return object{}, err
+	}
+	obj, err := l.fsys.create(l.name, bytes.NewReader(data), &l.opts)
+	if err != nil {
+		return object{}, err
+	}
+	l.obj.Store(&obj)
+	l.fsys.hashes.Store(nil)
+	return obj, nil
+}
+
+// produced returns the file contents, if they were produced.
+func (l *lazyFile) produced() (object, bool) {
+	if obj := l.obj.Load(); obj != nil {
+		return *obj, true
+	}
+	return object{}, false
+}
@@ -0,0 +1,112 @@
+package memfs
+
+import (
+	"archive/zip"
+	"io"
+	"io/fs"
+	"strconv"
+	"sync/atomic"
+)
+
+// A SizeError reports content over a size limit.
+type SizeError struct {
+	Name  string // file name, empty if the limit is for all files
+	Limit int64  // limit, in bytes
+}
+
+func (e *SizeError) Error() string {
+	if e.Name == "" {
+		return "memfs: total size over limit of " + strconv.FormatInt(e.Limit, 10) + " bytes"
+	}
+	return "memfs: " + e.Name + ": size over limit of " + strconv.FormatInt(e.Limit, 10) + " bytes"
+}
+
+// SetMaxFileSize sets the maximum size of the content of files created
+// with Create, CreateCompressed and CreateFile, before compression.
+// Creating a larger file fails with a *SizeError.
+// Compressed files created with CreateString (e.g. by untrusted generators)
+// declaring a larger size fail to open and read with a *SizeError,
+// instead of being decompressed.
+// Zero means no limit.
+func (fsys *FileSystem) SetMaxFileSize(max int64) error {
+	if err := fsys.checkSealed(); err != nil {
+		return err
+	}
+	fsys.maxSize = max
+	return nil
+}
+
+// checkSize checks the declared size of compressed content, before decompressing it.
+func (fsys *FileSystem) checkSize(name string, o object) error {
+	if max := fsys.maxSize; max > 0 && int64(o.size) > max {
+		return &SizeError{Name: name, Limit: max}
+	}
+	return nil
+}
+
+// sizeLimiter fails reading past size limits:
+// for a file, and, for a Load, for all files.
+type sizeLimiter struct {
+	r        io.Reader
+	name     string
+	read     int64 // bytes read from this file
+	maxFile  int64
+	maxTotal int64
+	ratio    int64         // maximum expansion of compressed archive members
+	total    *atomic.Int64 // bytes read from all files
+}
+
+// expansion limits the size of a compressed archive member, by its compressed size.
+func (l *sizeLimiter) expansion(info fs.FileInfo) {
+	if l.ratio <= 0 {
+		return
+	}
+	if h, ok := info.Sys().(*zip.FileHeader); ok && h.Method != zip.Store {
+		max := l.ratio * int64(h.CompressedSize64)
+		if max <= 0 {
+			max = l.ratio
+		}
+		if l.maxFile <= 0 || max < l.maxFile {
+			l.maxFile = max
+		}
+	}
+}
+
+func (l *sizeLimiter) limited() bool {
+	return l.maxFile > 0 || l.maxTotal > 0
+}
+
+// check fails early, if a file is known to be over the limits.
+func (l *sizeLimiter) check(size int64) error {
+	if max := l.maxFile; max > 0 && size > max {
+		return &SizeError{Name: l.name, Limit: max}
+	}
+	if max := l.maxTotal; max > 0 && l.total.Load()+size > max {
+		return &SizeError{Limit: max}
+	}
+	return nil
+}
+
+func (l *sizeLimiter) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.read += int64(n)
+	if max := l.maxFile; max > 0 && l.read > max {
+		return n, &SizeError{Name: l.name, Limit: max}
+	}
+	if max := l.maxTotal; max > 0 && l.total.Add(int64(n)) > max {
+		return n, &SizeError{Limit: max}
+	}
+	return n, err
+}
+
+// Stat lets readAll preallocate, unless the file is over the limits.
+func (l *sizeLimiter) Stat() (fs.FileInfo, error) {
+	if s, ok := l.r.(interface{ Stat() (fs.FileInfo, error) }); ok {
+		info, err := s.Stat()
+		if err == nil {
+			err = l.check(info.Size())
+		}
+		return info, err
+	}
+	return nil, fs.ErrInvalid
+}
@@ -0,0 +1,40 @@
+package memfs
+
+import (
+	"bytes"
+	"sort"
+	"time"
+)
+
+// File describes a file for FromMap.
+type File struct {
+	Data    []byte    // file content
+	MIME    string    // MIME type, sniffed if empty
+	ModTime time.Time // modification time
+}
+
+// FromMap creates a new FileSystem instance from a map of file names to files,
+// like fstest.MapFS.
+//
+// Usage:
+//
+//	site, err := memfs.FromMap(map[string]memfs.File{
+//		"index.html":    {Data: []byte("<h1>Hello, world!</h1>")},
+//		"api/data.json": {Data: []byte(`{"hello":"world"}`), MIME: "application/json"},
+//	})
+func FromMap(files map[string]File) (*FileSystem, error) {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fsys := Create()
+	for _, name := range names {
+		f := files[name]
+		if err := fsys.Create(name, f.MIME, f.ModTime, bytes.NewReader(f.Data)); err != nil {
+			return nil, err
+		}
+	}
+	return fsys, nil
+}
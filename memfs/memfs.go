@@ -7,6 +7,10 @@
 // Directories are implicit. Files can be gzip-compressed in memory.
 // Methods are provided to serve gziped content directly to accepting HTTP clients.
 //
+// Small builds, for TinyGo and wasm, are selected by the tinygo or memfs_small build tags.
+// They drop the golang.org/x and fsnotify dependencies (and Watch, and NormalizeNames),
+// and determine MIME types from file extensions only.
+//
 // Usage:
 //	assets, err = memfs.LoadCompressed(http.Dir("static"), gzip.BestCompression)
 //	if err != nil {
@@ -18,6 +22,7 @@ package memfs
 import (
 	"bytes"
 	"compress/gzip"
+	"context"
 	"encoding/binary"
 	"hash/crc32"
 	"io"
@@ -25,125 +30,455 @@ import (
 	"mime"
 	"net/http"
 	"path"
+	"runtime"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
+	"unsafe"
+
+	"github.com/ncruces/go-fs/internal/walkorder"
 )
 
 // FileSystem is the in memory fs.FS implementation.
 type FileSystem struct {
-	objs map[string]object
-	dirs map[string][]string
+	root  node
+	files map[string]*node   // files in the tree, by name
+	folds foldMap            // set by CaseInsensitive, names ignoring case
+	nfc   bool               // set by NormalizeNames
+	blobs map[blobKey]string // file contents, shared by identical files
+	mtx   *sync.RWMutex      // set by Watch, guards root, files and folds
+	mmap  int                // size threshold to move files off heap
+
+	transforms      []Transform
+	hooks           []Hooks
+	notFoundHandler http.Handler
+	indexes         map[string][]string
+	orders          map[string][]string
+	hashes          atomic.Pointer[map[uint32][]string]
+	history         int
+	versions        map[string][]object
+	tags            map[string][]string
+	preload         string
+	pages           map[string]*templatePage
+	imageFormats    []string
+	languages       []string
+	maxSize         int64
+	versionParam    string
+	buildID         string
+	buildTime       time.Time
+	immutable       func(name string) bool
+	lastModified    time.Duration
+	etagFunc        func(name string, info fs.FileInfo) string
+	created         string // the last file created by CreateString (set with -tags memfs_debug)
+	sealed          bool
 }
 
+// Transform transforms file contents before they're stored.
+// It receives the file name, and returns the new content.
+// The returned slice is retained, and must not be modified afterwards.
+// LoadCompressed may call a Transform concurrently for different files.
+type Transform func(name string, data []byte) ([]byte, error)
+
 // Create creates an empty FileSystem instance.
 func Create() *FileSystem {
 	return &FileSystem{
-		objs: map[string]object{},
-		dirs: map[string][]string{".": nil},
+		root:  node{obj: object{name: "."}, dir: true},
+		files: map[string]*node{},
 	}
 }
 
 // Load loads the contents of an fs.FS into a new FileSystem instance.
-func Load(in fs.FS) (*FileSystem, error) {
-	return LoadCompressed(in, gzip.NoCompression)
+// Files are transformed by transforms, in order
+// (use FileSystem.Transform to also transform files created later).
+func Load(in fs.FS, transforms ...Transform) (*FileSystem, error) {
+	return LoadCompressed(in, gzip.NoCompression, transforms...)
 }
 
 // LoadCompressed loads the contents of an fs.FS into a new FileSystem instance.
-// Files are gzip-compressed with the specified compression level.
-func LoadCompressed(in fs.FS, level int) (*FileSystem, error) {
+// Files are transformed by transforms, in order,
+// then gzip-compressed with the specified compression level.
+// Files are read and compressed concurrently.
+// Modification times are clamped to SOURCE_DATE_EPOCH, if set.
+func LoadCompressed(in fs.FS, level int, transforms ...Transform) (*FileSystem, error) {
+	return LoadCompressedContext(context.Background(), in, level, transforms...)
+}
+
+// LoadCompressedContext is like LoadCompressed, but stops loading when ctx is done.
+// Returns ctx.Err() as soon as ctx is done, even if reading from in is blocked:
+// loading then stops in the background, after pending reads (and transforms) return,
+// so in must remain usable until they do, and transforms may still be called.
+func LoadCompressedContext(ctx context.Context, in fs.FS, level int, transforms ...Transform) (*FileSystem, error) {
+	return LoadWithOptions(ctx, in, LoadOptions{Level: level, Transforms: transforms})
+}
+
+// LoadOptions configures LoadWithOptions.
+type LoadOptions struct {
+	Level      int         // gzip compression level, defaults to gzip.NoCompression
+	Transforms []Transform // transforms applied to loaded files, in order (not to files created later)
+
+	// Size limits on the content read from in, zero means no limit.
+	// Loading fails with a *SizeError as soon as a limit is exceeded.
+	MaxFileBytes  int64 // limit for any one file
+	MaxTotalBytes int64 // limit for all files
+
+	// Use files with a .gz sibling, e.g. app.js.gz next to app.js (the output of many frontend pipelines),
+	// as the compressed content of the file, instead of compressing it.
+	// The sibling is not loaded as a file.
+	// Siblings that don't match the content of the file (after transforms) are ignored.
+	Precompressed bool
+
+	// Limit on the expansion ratio of compressed archive members
+	// (e.g. from a zip.Reader), zero means no limit.
+	// Loading fails with a *SizeError as soon as a member
+	// decompresses to more than MaxExpansion times its compressed size.
+	MaxExpansion int64
+
+	// Modification times (stored, and in gzip headers) later than this are clamped to it,
+	// so builds are reproducible.
+	// Zero means the time set by the SOURCE_DATE_EPOCH environment variable, if any.
+	MaxModTime time.Time
+
+	// Create empty directories found in in, which are otherwise dropped,
+	// as directories are implicit.
+	EmptyDirs bool
+}
+
+// LoadWithOptions is like LoadCompressedContext, configured by opts.
+// Size limits protect against loading directories too large to fit in memory.
+//
+// Usage:
+//
+//	uploads, err := memfs.LoadWithOptions(ctx, os.DirFS("uploads"), memfs.LoadOptions{
+//		Level:         gzip.BestCompression,
+//		MaxFileBytes:  10 << 20,
+//		MaxTotalBytes: 100 << 20,
+//	})
+func LoadWithOptions(ctx context.Context, in fs.FS, opts LoadOptions) (*FileSystem, error) {
+	type result struct {
+		fsys *FileSystem
+		err  error
+	}
+	c := make(chan result, 1)
+	go func() {
+		fsys, err := load(ctx, in, &opts)
+		c <- result{fsys, err}
+	}()
+	select {
+	case r := <-c:
+		return r.fsys, r.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func load(ctx context.Context, in fs.FS, opts *LoadOptions) (*FileSystem, error) {
 	fsys := Create()
+	if err := fsys.Transform(opts.Transforms...); err != nil {
+		return nil, err
+	}
+
+	maxTime := opts.MaxModTime
+	if maxTime.IsZero() {
+		maxTime = sourceDateEpoch()
+	}
+
+	var names []string
+	var entries []fs.DirEntry
+	var dirs []string
 	err := fs.WalkDir(in, ".", func(path string, d fs.DirEntry, err error) error {
-		if err != nil || d.IsDir() {
-			return err
+		if err == nil {
+			err = ctx.Err()
 		}
-		if file, err := in.Open(path); err != nil {
-			return err
-		} else if info, err := d.Info(); err != nil {
-			return err
-		} else {
-			return fsys.CreateCompressed(path, "", info.ModTime(), file, level)
+		if err == nil && !d.IsDir() {
+			names = append(names, path)
+			entries = append(entries, d)
 		}
+		if err == nil && d.IsDir() && opts.EmptyDirs {
+			dirs = append(dirs, path)
+		}
+		return err
 	})
 	if err != nil {
 		return nil, err
 	}
+
+	// drop .gz siblings, and remember them
+	var siblings map[string]string
+	if opts.Precompressed {
+		siblings = gzipSiblings(names)
+		n := 0
+		for i, name := range names {
+			if _, ok := siblings[strings.TrimSuffix(name, ".gz")]; ok && strings.HasSuffix(name, ".gz") {
+				continue
+			}
+			names[n], entries[n] = names[i], entries[i]
+			n++
+		}
+		names, entries = names[:n], entries[:n]
+	}
+
+	objs := make([]object, len(names))
+	errs := make([]error, len(names))
+
+	var wg sync.WaitGroup
+	var next atomic.Int64
+	var total atomic.Int64
+	var failed atomic.Bool
+	for n := runtime.GOMAXPROCS(0); n > 0; n-- {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for !failed.Load() {
+				i := int(next.Add(1) - 1)
+				if i >= len(names) {
+					return
+				}
+				limiter := &sizeLimiter{name: names[i], maxFile: opts.MaxFileBytes, maxTotal: opts.MaxTotalBytes, ratio: opts.MaxExpansion, total: &total}
+				if gz, ok := siblings[names[i]]; ok {
+					objs[i], errs[i] = fsys.load(ctx, in, names[i], entries[i], gzip.NoCompression, limiter, maxTime)
+					if errs[i] == nil {
+						objs[i], errs[i] = precompressed(in, names[i], gz, objs[i], opts.Level)
+					}
+				} else {
+					objs[i], errs[i] = fsys.load(ctx, in, names[i], entries[i], opts.Level, limiter, maxTime)
+				}
+				if errs[i] != nil {
+					failed.Store(true)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	fsys.transforms = nil // only applied while loading
+
+	// put in fs.WalkDir order, which makes appending cheap
+	for i, name := range names {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		if err := fsys.put(name, objs[i], true); err != nil {
+			return nil, err
+		}
+	}
+	for _, dir := range dirs {
+		if err := fsys.CreateDir(dir); err != nil {
+			return nil, err
+		}
+	}
 	return fsys, nil
 }
 
+func (fsys *FileSystem) load(ctx context.Context, in fs.FS, name string, d fs.DirEntry, level int, limiter *sizeLimiter, maxTime time.Time) (object, error) {
+	if err := ctx.Err(); err != nil {
+		return object{}, err
+	}
+	info, err := d.Info()
+	if err != nil {
+		return object{}, err
+	}
+	limiter.expansion(info)
+	if err := limiter.check(info.Size()); err != nil {
+		return object{}, err
+	}
+	file, err := in.Open(name)
+	if err != nil {
+		return object{}, err
+	}
+	defer file.Close()
+	var r io.Reader = file
+	if ctx.Done() != nil {
+		r = ctxReader{ctx, file}
+	}
+	if limiter.limited() {
+		limiter.r = r
+		r = limiter
+	}
+	data, err := fsys.read(name, r)
+	if err != nil {
+		return object{}, err
+	}
+	return compress(name, "", clampTime(info.ModTime(), maxTime), data, level)
+}
+
 // Open implements fs.FS, opening files for reading.
 // Compressed files are decompressed on-the-fly.
 // Seeking compressed files is emulated and can be extremely slow.
 func (fsys *FileSystem) Open(name string) (fs.File, error) {
-	if o, ok := fsys.objs[name]; ok {
-		if len(o.data) == o.size {
-			return file{o, strings.NewReader(o.data)}, nil
-		}
-		return &zfile{object: o}, nil
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
 	}
-	if d, ok := fsys.dirs[name]; ok {
-		return &dir{name: name, list: d, fsys: fsys}, nil
+	n, rel, err := fsys.lookup(name)
+	switch {
+	case err == fs.ErrNotExist:
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	case err != nil:
+		return nil, err
+	case n.mount != nil:
+		return n.openMount(rel)
+	case n.dir:
+		return &dir{name: n.name(), list: fsys.order(name, n.kids)}, nil
 	}
-	return nil, fs.ErrNotExist
+	return fsys.open(name, n.obj)
+}
+
+// open opens the contents of a file for reading.
+func (fsys *FileSystem) open(name string, o object) (fs.File, error) {
+	if len(o.data) == o.size {
+		return file{o, strings.NewReader(o.data)}, nil
+	} else if o.raw != "" {
+		return file{o, strings.NewReader(o.raw)}, nil
+	}
+	if err := fsys.checkSize(name, o); err != nil {
+		return nil, err
+	}
+	return &zfile{object: o}, nil
 }
 
 // ReadFile implements fs.ReadFileFS, reading the named file and returning its contents.
 // Compressed files are decompressed on-the-fly.
 func (fsys *FileSystem) ReadFile(name string) ([]byte, error) {
-	if o, ok := fsys.objs[name]; ok {
-		if len(o.data) == o.size {
-			return []byte(o.data), nil
-		}
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readfile", Path: name, Err: fs.ErrInvalid}
+	}
+	n, rel, err := fsys.lookup(name)
+	switch {
+	case err == fs.ErrNotExist:
+		return nil, &fs.PathError{Op: "readfile", Path: name, Err: err}
+	case err != nil:
+		return nil, err
+	case n.mount != nil:
+		return fs.ReadFile(n.mount, rel)
+	case n.dir:
+		return nil, fs.ErrInvalid
+	}
 
-		gzip, err := gzip.NewReader(strings.NewReader(o.data))
-		if err != nil {
-			return nil, err
-		}
-		defer gzip.Close()
-		return io.ReadAll(gzip)
+	o := n.obj
+	if len(o.data) == o.size {
+		return []byte(o.data), nil
+	} else if o.raw != "" {
+		return []byte(o.raw), nil
+	}
+	if err := fsys.checkSize(name, o); err != nil {
+		return nil, err
+	}
+	gzip, err := newGzipReader(o.data)
+	if err != nil {
+		return nil, err
+	}
+	defer gzip.release()
+	// DEFLATE expands at most 1032:1, don't trust a larger size
+	if o.size > 1032*len(o.data) {
+		return nil, errSize
+	}
+	data := make([]byte, o.size)
+	if _, err := io.ReadFull(gzip, data); err == io.ErrUnexpectedEOF {
+		return nil, errSize
+	} else if err != nil {
+		return nil, err
+	}
+	// read to EOF, so the gzip trailer (CRC-32 and size) is checked
+	var extra [1]byte
+	if n, err := io.ReadFull(gzip, extra[:]); n != 0 {
+		return nil, errSize
+	} else if err != io.EOF {
+		return nil, err
+	}
+	return data, nil
+}
+
+// ReadDir implements fs.ReadDirFS, reading the named directory
+// and returning a list of directory entries sorted by filename
+// (or in the order set by SetOrder).
+func (fsys *FileSystem) ReadDir(name string) ([]fs.DirEntry, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
 	}
-	if _, ok := fsys.dirs[name]; ok {
+	defer f.Close()
+	switch d := f.(type) {
+	case *dir:
+		return d.ReadDir(-1)
+	case fs.ReadDirFile:
+		// mounted file systems may not sort entries
+		list, err := d.ReadDir(-1)
+		sort.Slice(list, func(i, j int) bool {
+			return list[i].Name() < list[j].Name()
+		})
+		return list, err
+	default:
 		return nil, fs.ErrInvalid
 	}
-	return nil, fs.ErrNotExist
 }
 
 // Stat implements fs.StatFS, returning a fs.FileInfo that describes the file.
 func (fsys *FileSystem) Stat(name string) (fs.FileInfo, error) {
-	return fsys.stat(name)
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+	info, err := fsys.stat(name)
+	if err == fs.ErrNotExist {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+	}
+	return info, err
 }
 
 func (fsys *FileSystem) stat(name string) (entryInfo, error) {
-	if o, ok := fsys.objs[name]; ok {
-		return o, nil
+	n, rel, err := fsys.lookup(name)
+	switch {
+	case err != nil:
+		return nil, err
+	case n.mount != nil:
+		return n.statMount(rel)
+	case n.dir:
+		return dirInfo(n.name()), nil
 	}
-	if _, ok := fsys.dirs[name]; ok {
-		return newDirInfo(name), nil
+	return n.obj, nil
+}
+
+// Transform adds transforms to the pipeline applied to files
+// created by Create and CreateCompressed (but not CreateString).
+// Transforms run in order, before the MIME type is sniffed and content is compressed.
+func (fsys *FileSystem) Transform(transforms ...Transform) error {
+	if err := fsys.checkSealed(); err != nil {
+		return err
 	}
-	return nil, fs.ErrNotExist
+	fsys.transforms = append(fsys.transforms, transforms...)
+	return nil
 }
 
 // Create creates a file.
 // Overwrites an existing file (but not a directory).
 // Sniffs the MIME type if none is provided.
 func (fsys *FileSystem) Create(name, mimetype string, modtime time.Time, r io.Reader) error {
-	if !fs.ValidPath(name) {
-		return fs.ErrInvalid
-	}
-	if _, ok := fsys.dirs[name]; ok {
-		return fs.ErrExist
+	return fsys.CreateFile(name, r, WithMIME(mimetype), WithModTime(modtime))
+}
+
+func newObject(name, mimetype string, modtime time.Time, data []byte) object {
+	return object{
+		data: toString(data),
+		size: len(data),
+		time: modtime,
+		mime: getType(mimetype, name, data),
+		hash: checksum(data),
 	}
+}
 
-	data, err := io.ReadAll(r)
-	if err == nil {
-		fsys.put(name, object{
-			data: string(data),
-			size: len(data),
-			time: modtime,
-			mime: getType(mimetype, name, data),
-			hash: crc32.Checksum(data, crc32.MakeTable(crc32.Castagnoli)),
-		}, false)
+// read reads the content for file name, and applies transforms.
+func (fsys *FileSystem) read(name string, r io.Reader) ([]byte, error) {
+	if max := fsys.maxSize; max > 0 {
+		r = &sizeLimiter{r: r, name: name, maxFile: max}
+	}
+	data, err := readAll(r)
+	for _, t := range fsys.transforms {
+		if err != nil {
+			break
+		}
+		data, err = t(name, data)
 	}
-	return err
+	return data, err
 }
 
 // CreateCompressed creates a compressed file.
@@ -151,49 +486,67 @@ func (fsys *FileSystem) Create(name, mimetype string, modtime time.Time, r io.Re
 // Files are gzip-compressed with the specified compression level.
 // Sniffs the MIME type if none is provided.
 func (fsys *FileSystem) CreateCompressed(name, mimetype string, modtime time.Time, r io.Reader, level int) error {
-	if level == gzip.NoCompression {
-		return fsys.Create(name, mimetype, modtime, r)
-	}
-	if !fs.ValidPath(name) {
-		return fs.ErrInvalid
-	}
-	if _, ok := fsys.dirs[name]; ok {
-		return fs.ErrExist
-	}
+	return fsys.CreateFile(name, r, WithMIME(mimetype), WithModTime(modtime), WithCompression(level))
+}
 
-	data, err := io.ReadAll(r)
-	if err != nil {
-		return err
-	}
-	if len(data) >= 1024 {
+// compress creates an object from data, gzip-compressed with the specified compression level.
+// Data is stored uncompressed if it's small, or doesn't compress well.
+func compress(name, mimetype string, modtime time.Time, data []byte, level int) (object, error) {
+	if level != gzip.NoCompression && len(data) >= 1024 {
 		var buf bytes.Buffer
-		buf.Grow(len(data))
+		buf.Grow(len(data) / 2)
 
 		gzip, err := gzip.NewWriterLevel(&buf, level)
 		if err != nil {
-			return err
+			return object{}, err
 		}
 		defer gzip.Close()
 		gzip.ModTime = modtime
-		_, gzip.Name = path.Split(name)
+		gzip.Name = gzipName(name)
 
 		n, err := io.Copy(gzip, bytes.NewReader(data))
 		if err == nil {
 			err = gzip.Close()
 		}
 		if err == nil && 4*n >= 5*int64(buf.Len()) {
-			fsys.put(name, object{
-				data: buf.String(),
+			hash := getHash(buf.Bytes(), n)
+			if hash == 0 {
+				hash = checksum(data)
+			}
+			return object{
+				data: toString(buf.Bytes()),
 				size: len(data),
 				time: modtime,
 				mime: getType(mimetype, name, data),
-				hash: getHash(buf.Bytes(), n),
-			}, false)
-			return nil
+				hash: hash,
+			}, nil
 		}
 	}
+	return newObject(name, mimetype, modtime, data), nil
+}
+
+// checkOrder panics if name is not after the previous file passed to CreateString,
+// in fs.WalkDir order.
+func (fsys *FileSystem) checkOrder(name string) {
+	if fsys.created != "" && !walkorder.Less(fsys.created, name) {
+		if fsys.created == name {
+			panic("memfs: CreateString: duplicate file " + strconv.Quote(name))
+		}
+		panic("memfs: CreateString: file " + strconv.Quote(name) + " after " + strconv.Quote(fsys.created) + ", not in fs.WalkDir order")
+	}
+	fsys.created = name
+}
 
-	return fsys.Create(name, mimetype, modtime, bytes.NewReader(data))
+// gzipName returns the base of name for a gzip header,
+// or empty if it can't be encoded in one (as NUL terminated Latin-1).
+func gzipName(name string) string {
+	_, base := path.Split(name)
+	for _, r := range base {
+		if r == 0 || r > 0xff {
+			return ""
+		}
+	}
+	return base
 }
 
 // CreateString creates a file from a string.
@@ -202,55 +555,383 @@ func (fsys *FileSystem) CreateCompressed(name, mimetype string, modtime time.Tim
 //
 // Overwrites an existing file.
 // Files are expected to be passed in fs.WalkDir order.
+// Built with -tags memfs_debug, it panics if name is not after the previous file passed to CreateString,
+// so generated files that are out of order, or have duplicates, fail loudly.
 // MIME type will NOT be sniffed and content will NOT be compressed.
 // If size != len(content), content is assumed to be gzip-compressed, and size its uncompressed size.
-func (fsys *FileSystem) CreateString(name, mimetype string, modtime time.Time, hash uint32, size int, content string) {
-	fsys.put(name, object{
+// If hash is zero, it's computed from content.
+func (fsys *FileSystem) CreateString(name, mimetype string, modtime time.Time, hash uint32, size int, content string) error {
+	if debug {
+		fsys.checkOrder(name)
+	}
+	if hash == 0 && content != "" {
+		data := unsafe.Slice(unsafe.StringData(content), len(content))
+		if size == len(content) {
+			hash = checksum(data)
+		} else {
+			hash = getHash(data, int64(size))
+		}
+	}
+	// skip dedup, identical literals are already shared
+	return fsys.insert(name, &node{obj: object{
 		size: size,
 		time: modtime,
-		mime: mimetype,
+		mime: intern(mimetype),
 		data: content,
 		hash: hash,
-	}, true)
+	}}, true)
+}
+
+func (fsys *FileSystem) put(name string, obj object, ordered bool) error {
+	if fsys.mtx != nil {
+		fsys.mtx.Lock()
+		defer fsys.mtx.Unlock()
+	}
+	if err := fsys.checkSealed(); err != nil {
+		return err
+	}
+	fsys.dedup(&obj)
+	return fsys.link(name, &node{obj: obj}, ordered)
+}
+
+// canCreate fails if the file system is sealed,
+// or if creating a file at name would conflict with a directory or mount point,
+// so callers fail before reading content.
+func (fsys *FileSystem) canCreate(name string) error {
+	if fsys.mtx != nil {
+		fsys.mtx.RLock()
+		defer fsys.mtx.RUnlock()
+	}
+	if err := fsys.checkSealed(); err != nil {
+		return err
+	}
+	name = fsys.normal(name)
+	if fsys.root.conflicts(name, true) || fsys.collides(name) {
+		return fs.ErrExist
+	}
+	return nil
 }
 
-func (fsys *FileSystem) put(name string, obj object, ordered bool) {
-	dir, file := path.Split(name)
-	obj.name = file
-	fsys.objs[name] = obj
+// insert links n into the tree at name, creating parent directories as needed.
+// Replaces an existing file, if n is a file.
+// If ordered, names are expected in fs.WalkDir order, which makes appending cheap.
+func (fsys *FileSystem) insert(name string, n *node, ordered bool) error {
+	if fsys.mtx != nil {
+		fsys.mtx.Lock()
+		defer fsys.mtx.Unlock()
+	}
+	if err := fsys.checkSealed(); err != nil {
+		return err
+	}
+	return fsys.link(name, n, ordered)
+}
 
-	hasFile := func(dir []string, name string) bool {
-		if ordered {
-			return len(dir) > 0 && dir[len(dir)-1] == name
+// link is insert, without locking.
+func (fsys *FileSystem) link(name string, n *node, ordered bool) error {
+	name = fsys.normal(name)
+	_, n.obj.name = path.Split(name)
+	if fsys.root.conflicts(name, n.mount == nil) || fsys.collides(name) {
+		return fs.ErrExist
+	}
+	if n.mount == nil {
+		n.sri = new(atomic.Pointer[string])
+		fsys.files[name] = n
+	}
+	fsys.hashes.Store(nil)
+	parent, full := &fsys.root, name
+	for {
+		elem, rest, more := strings.Cut(name, "/")
+		i, found := parent.search(elem, ordered)
+		if !found && fsys.folds != nil {
+			fsys.folds[fold(parent, elem)] = elem
 		}
-		for _, s := range dir {
-			if s == name {
-				return true
+		if !more {
+			if found && !sameData(parent.kids[i].obj.data, n.obj.data) {
+				fsys.undedup(parent.kids[i])
+				fsys.keep(full, parent.kids[i])
 			}
+			parent.set(i, found, n)
+			return nil
+		}
+		if !found {
+			parent.set(i, found, &node{obj: object{name: elem}, dir: true})
+		}
+		parent, name = parent.kids[i], rest
+	}
+}
+
+// lookup finds the node for name, producing the contents of lazy files.
+// If name is under a mount point, returns the mount point and the name relative to it.
+// The returned node is a copy, safe to use without holding the lock.
+func (fsys *FileSystem) lookup(name string) (n node, rel string, err error) {
+	n, rel, ok := fsys.find(name)
+	if !ok {
+		return n, rel, fs.ErrNotExist
+	}
+	if n.lazy != nil {
+		// produce without holding the lock, fn may use fsys
+		obj, err := n.lazy.get()
+		if err != nil {
+			return node{}, "", err
 		}
-		return false
+		obj.name, obj.head = n.obj.name, n.obj.head
+		n.obj = obj
 	}
+	return n, rel, nil
+}
 
-	addFile := func(dir, name string) bool {
-		d, ok := fsys.dirs[dir]
-		if ok && hasFile(d, name) {
+// find is lookup, without producing lazy files.
+func (fsys *FileSystem) find(name string) (n node, rel string, ok bool) {
+	if fsys.mtx != nil {
+		fsys.mtx.RLock()
+		defer fsys.mtx.RUnlock()
+	}
+	name = fsys.normal(name)
+	// most lookups are for files: avoid walking the tree
+	if p, ok := fsys.files[name]; ok {
+		return *p, ".", true
+	}
+	if p, rel := fsys.root.find(name, fsys.folds); p != nil {
+		return *p, rel, true
+	}
+	return node{}, "", false
+}
+
+// Merge imports the files of another FileSystem.
+// File contents are shared, not copied or recompressed.
+//
+// Fails with fs.ErrExist if a file conflicts with a directory (or vice versa),
+// or, unless overwrite is true, with an existing file.
+// Nothing is imported if Merge fails.
+func (fsys *FileSystem) Merge(other *FileSystem, overwrite bool) error {
+	var names, dirs []string
+	var files []*node
+	func() {
+		if other.mtx != nil {
+			other.mtx.RLock()
+			defer other.mtx.RUnlock()
+		}
+		other.root.walk(".", func(name string, f *node) {
+			names = append(names, name)
+			files = append(files, f)
+		})
+		other.root.emptyDirs(".", func(name string) {
+			dirs = append(dirs, name)
+		})
+	}()
+
+	if fsys.mtx != nil {
+		fsys.mtx.Lock()
+		defer fsys.mtx.Unlock()
+	}
+	if err := fsys.checkSealed(); err != nil {
+		return err
+	}
+	// check everything before changing anything
+	if fsys.mergeConflicts(names, dirs, overwrite) {
+		return fs.ErrExist
+	}
+
+	for i, name := range names {
+		f := &node{obj: files[i].obj, lazy: files[i].lazy}
+		if f.lazy == nil {
+			fsys.dedup(&f.obj)
+		}
+		if err := fsys.link(name, f, false); err != nil {
+			return err
+		}
+	}
+	for _, dir := range dirs {
+		if err := fsys.mkdir(dir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mergeConflicts reports if merging files and empty directories would conflict
+// with existing files and directories, or with each other once normalized (or ignoring case).
+// Must be called with the lock held.
+func (fsys *FileSystem) mergeConflicts(files, dirs []string, overwrite bool) bool {
+	merged := map[string]bool{} // true for directories
+	var folded map[string]string
+	if fsys.folds != nil {
+		folded = map[string]string{}
+	}
+	add := func(name string, dir bool) bool {
+		if d, ok := merged[name]; ok && d != dir {
+			return false
+		}
+		merged[name] = dir
+		if folded != nil {
+			key := strings.ToLower(name)
+			if other, ok := folded[key]; ok && other != name {
+				return false
+			}
+			folded[key] = name
+		}
+		return true
+	}
+	addAll := func(name string, dir bool) bool {
+		for i := 0; i < len(name); i++ {
+			if name[i] == '/' && !add(name[:i], true) {
+				return false
+			}
+		}
+		return add(name, dir)
+	}
+
+	for _, name := range files {
+		name = fsys.normal(name)
+		if fsys.root.conflicts(name, overwrite) || fsys.collides(name) || !addAll(name, false) {
 			return true
 		}
-		fsys.dirs[dir] = append(d, name)
-		return false
 	}
+	for _, name := range dirs {
+		name = fsys.normal(name)
+		if fsys.root.dirConflicts(name) || fsys.collides(name) || !addAll(name, true) {
+			return true
+		}
+	}
+	return false
+}
+
+// Link creates newname as a link to the file oldname, sharing its content
+// (and headers) in memory, e.g. to serve the same file from several paths.
+// Like a hard link, later replacing or removing either file doesn't affect the other.
+// Overwrites an existing file (but not a directory).
+func (fsys *FileSystem) Link(newname, oldname string) error {
+	if !fs.ValidPath(newname) || !fs.ValidPath(oldname) {
+		return fs.ErrInvalid
+	}
+	n, _, ok := fsys.find(oldname)
+	switch {
+	case !ok:
+		return fs.ErrNotExist
+	case n.dir || n.mount != nil:
+		return fs.ErrInvalid
+	}
+	if err := fsys.canCreate(newname); err != nil {
+		return err
+	}
+	return fsys.insert(newname, &node{obj: n.obj, lazy: n.lazy}, false)
+}
 
-	for len(dir) > 0 {
-		// remove trailing slash
-		dir = dir[:len(dir)-1]
-		if addFile(dir, name) {
-			return
+// CreateDir creates a directory, and any missing parents,
+// so it exists even if it's empty (directories are otherwise implicit),
+// e.g. for consumers that extract files to disk, and need the directory skeleton.
+// Creating a directory that exists is not an error.
+func (fsys *FileSystem) CreateDir(name string) error {
+	if fsys.mtx != nil {
+		fsys.mtx.Lock()
+		defer fsys.mtx.Unlock()
+	}
+	if err := fsys.checkSealed(); err != nil {
+		return err
+	}
+	if !fs.ValidPath(name) {
+		return fs.ErrInvalid
+	}
+	return fsys.mkdir(name)
+}
+
+// mkdir is CreateDir, without locking.
+func (fsys *FileSystem) mkdir(name string) error {
+	if name == "." {
+		return nil
+	}
+	name = fsys.normal(name)
+	if fsys.collides(name) {
+		return fs.ErrExist
+	}
+
+	parent := &fsys.root
+	for {
+		elem, rest, more := strings.Cut(name, "/")
+		i, found := parent.search(elem, false)
+		if found && !parent.kids[i].dir {
+			return fs.ErrExist
+		}
+		if !found {
+			if fsys.folds != nil {
+				fsys.folds[fold(parent, elem)] = elem
+			}
+			parent.set(i, found, &node{obj: object{name: elem}, dir: true})
 		}
-		// continue with parent
-		name = dir
-		dir, _ = path.Split(dir)
+		if !more {
+			return nil
+		}
+		parent, name = parent.kids[i], rest
+	}
+}
+
+// Remove removes a file, or an empty directory.
+// Directories left empty are also removed.
+func (fsys *FileSystem) Remove(name string) error {
+	return fsys.remove(name, false)
+}
+
+// removeAll removes a file, or a directory and everything it contains.
+func (fsys *FileSystem) removeAll(name string) {
+	fsys.remove(name, true)
+}
+
+// remove unlinks a file (or, if all is true, a directory) from the tree,
+// and removes directories left empty.
+func (fsys *FileSystem) remove(name string, all bool) error {
+	if fsys.mtx != nil {
+		fsys.mtx.Lock()
+		defer fsys.mtx.Unlock()
+	}
+	if err := fsys.checkSealed(); err != nil {
+		return err
+	}
+	if name == "." {
+		return fs.ErrInvalid
+	}
+	if !fs.ValidPath(name) {
+		return fs.ErrNotExist
+	}
+	name = fsys.normal(name)
+
+	var parents []*node
+	n := &fsys.root
+	for rest, more := name, true; more; {
+		var elem string
+		elem, rest, more = strings.Cut(rest, "/")
+		parents = append(parents, n)
+		if n = n.child(elem); n == nil || more && !n.dir {
+			return fs.ErrNotExist
+		}
+	}
+	if n.mount != nil {
+		return fs.ErrNotExist
+	}
+	fsys.hashes.Store(nil)
+	if !n.dir {
+		delete(fsys.files, name)
+		fsys.undedup(n)
+	} else if all || len(n.kids) == 0 {
+		n.walk(name, func(name string, f *node) {
+			delete(fsys.files, name)
+			fsys.undedup(f)
+		})
+	} else {
+		return fs.ErrInvalid
+	}
+
+	for i := len(parents) - 1; i >= 0; i-- {
+		p := parents[i]
+		p.unset(n.name())
+		if fsys.folds != nil {
+			fsys.folds.remove(p, n)
+		}
+		if len(p.kids) > 0 {
+			break
+		}
+		n = p
 	}
-	addFile(".", name)
+	return nil
 }
 
 type object struct {
@@ -260,6 +941,8 @@ type object struct {
 	time time.Time
 	mime string
 	hash uint32
+	head http.Header
+	raw  string // uncompressed content, kept along with compressed data (optional)
 }
 
 func (o object) Name() string               { return o.name }
@@ -269,7 +952,7 @@ func (o object) Info() (fs.FileInfo, error) { return o, nil }
 func (o object) Size() int64                { return int64(o.size) }
 func (o object) Mode() fs.FileMode          { return 0444 }
 func (o object) ModTime() time.Time         { return o.time }
-func (o object) Sys() interface{}           { return nil }
+func (o object) Sys() interface{}           { return o.sys() }
 
 type file struct {
 	object
@@ -294,17 +977,22 @@ func (f file) Stat() (fs.FileInfo, error) {
 
 type zfile struct {
 	object
-	pos    int
-	reader *gzip.Reader
+	pos    int // position of the file
+	rpos   int // position of the reader
+	reader *gzipReader
 }
 
 func (f *zfile) Close() error {
 	f.pos = -1
-	if c := f.reader; c != nil {
+	f.release()
+	return nil
+}
+
+func (f *zfile) release() {
+	if r := f.reader; r != nil {
 		f.reader = nil
-		return c.Close()
+		r.release()
 	}
-	return nil
 }
 
 func (f *zfile) Read(p []byte) (n int, err error) {
@@ -315,21 +1003,23 @@ func (f *zfile) Read(p []byte) (n int, err error) {
 		return 0, io.EOF
 	}
 	if f.reader == nil {
-		f.reader, err = gzip.NewReader(strings.NewReader(f.data))
+		f.reader, err = newGzipReader(f.data)
 		if err != nil {
 			return 0, err
 		}
-		if f.pos > 0 {
-			_, err = io.CopyN(io.Discard, f.reader, int64(f.pos))
-			if err != nil {
-				f.reader.Close()
-				f.reader = nil
-				return 0, err
-			}
+		f.rpos = 0
+	}
+	if f.pos > f.rpos {
+		_, err = io.CopyN(io.Discard, f.reader, int64(f.pos-f.rpos))
+		if err != nil {
+			f.release()
+			return 0, err
 		}
+		f.rpos = f.pos
 	}
 	n, err = f.reader.Read(p)
 	f.pos += n
+	f.rpos += n
 	return
 }
 
@@ -352,8 +1042,11 @@ func (f *zfile) Seek(offset int64, whence int) (int64, error) {
 	if ipos < 0 || npos != int64(ipos) {
 		return 0, fs.ErrInvalid
 	}
+	// only rewind when seeking backward
+	if ipos < f.rpos {
+		f.release()
+	}
 	f.pos = ipos
-	f.reader = nil
 	return npos, nil
 }
 
@@ -361,11 +1054,36 @@ func (f *zfile) Stat() (fs.FileInfo, error) {
 	return f, nil
 }
 
+// Decompressing allocates a lot of state: pool and reuse it.
+var gzipReaders sync.Pool
+
+type gzipReader struct {
+	gzip.Reader
+	src strings.Reader
+}
+
+func newGzipReader(data string) (*gzipReader, error) {
+	r, _ := gzipReaders.Get().(*gzipReader)
+	if r == nil {
+		r = new(gzipReader)
+	}
+	r.src.Reset(data)
+	if err := r.Reset(&r.src); err != nil {
+		r.release()
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *gzipReader) release() {
+	r.src.Reset("")
+	gzipReaders.Put(r)
+}
+
 type dir struct {
 	name string
 	pos  int
-	list []string
-	fsys *FileSystem
+	list []*node
 }
 
 func (d *dir) Close() error {
@@ -388,13 +1106,12 @@ func (d *dir) ReadDir(count int) ([]fs.DirEntry, error) {
 		return nil, io.EOF
 	}
 
-	var ret []fs.DirEntry
+	if n := len(d.list) - d.pos; count > n {
+		count = n
+	}
+	ret := make([]fs.DirEntry, 0, count)
 	for d.pos < len(d.list) && count > 0 {
-		s, err := d.fsys.stat(d.list[d.pos])
-		if err != nil {
-			return ret, err
-		}
-		ret = append(ret, s)
+		ret = append(ret, d.list[d.pos].entry())
 		d.pos++
 		count--
 	}
@@ -402,12 +1119,11 @@ func (d *dir) ReadDir(count int) ([]fs.DirEntry, error) {
 }
 
 func (d *dir) Stat() (fs.FileInfo, error) {
-	return newDirInfo(d.name), nil
+	return dirInfo(d.name), nil
 }
 
 type dirInfo string
 
-func newDirInfo(dir string) dirInfo          { return dirInfo(path.Base(dir)) }
 func (d dirInfo) Name() string               { return string(d) }
 func (d dirInfo) IsDir() bool                { return true }
 func (d dirInfo) Type() fs.FileMode          { return fs.ModeDir }
@@ -417,14 +1133,98 @@ func (d dirInfo) Mode() fs.FileMode          { return fs.ModeDir | 0555 }
 func (d dirInfo) ModTime() time.Time         { return time.Time{} }
 func (d dirInfo) Sys() interface{}           { return nil }
 
+// ctxReader stops reading when ctx is done.
+type ctxReader struct {
+	ctx context.Context
+	fs.File
+}
+
+func (r ctxReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.File.Read(p)
+}
+
+// readAll is like io.ReadAll, but avoids reallocations if the size of r is known.
+func readAll(r io.Reader) ([]byte, error) {
+	size := -1
+	switch r := r.(type) {
+	case interface{ Len() int }:
+		size = r.Len()
+	case interface{ Stat() (fs.FileInfo, error) }:
+		if info, err := r.Stat(); err == nil && info.Mode().IsRegular() && int64(int(info.Size())) == info.Size() {
+			size = int(info.Size())
+		}
+	}
+	if size < 0 {
+		return io.ReadAll(r)
+	}
+
+	// same as os.ReadFile: one extra byte detects EOF without growing
+	data := make([]byte, 0, size+1)
+	for {
+		n, err := r.Read(data[len(data):cap(data)])
+		data = data[:len(data)+n]
+		if err != nil {
+			if err == io.EOF {
+				err = nil
+			}
+			return data, err
+		}
+		if len(data) >= cap(data) {
+			d := append(data[:cap(data)], 0)
+			data = d[:len(data)]
+		}
+	}
+}
+
+// toString converts a slice that won't be modified to a string.
+// Avoids copying, unless that would waste too much memory.
+func toString(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+	if cap(b)-len(b) > len(b)/16 {
+		return string(b)
+	}
+	return unsafe.String(&b[0], len(b))
+}
+
 func getType(mimetype, name string, data []byte) string {
 	if mimetype == "" {
 		mimetype = mime.TypeByExtension(path.Ext(name))
 	}
 	if mimetype == "" {
-		mimetype = http.DetectContentType(data)
+		mimetype = sniff(data)
 	}
-	return mimetype
+	return intern(mimetype)
+}
+
+// MIME types are few and repeated across many files: share them.
+var interned struct {
+	sync.Mutex
+	strs map[string]string
+}
+
+func intern(s string) string {
+	if s == "" {
+		return s
+	}
+	interned.Lock()
+	defer interned.Unlock()
+	if i, ok := interned.strs[s]; ok {
+		return i
+	}
+	if interned.strs == nil {
+		interned.strs = map[string]string{}
+	}
+	interned.strs[s] = s
+	return s
+}
+
+func checksum(data []byte) uint32 {
+	return crc32.Checksum(data, crc32.MakeTable(crc32.Castagnoli))
 }
 
 func getHash(data []byte, isize int64) uint32 {
@@ -439,6 +1239,7 @@ func getHash(data []byte, isize int64) uint32 {
 // Check interface implementations
 var _ fs.ReadFileFS = &FileSystem{}
 var _ fs.StatFS = &FileSystem{}
+var _ fs.ReadDirFS = &FileSystem{}
 var _ fs.File = file{}
 var _ fs.File = &zfile{}
 var _ fs.ReadDirFile = &dir{}
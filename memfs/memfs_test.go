@@ -1,11 +1,24 @@
 package memfs_test
 
 import (
+	"archive/zip"
+	"bytes"
 	"compress/gzip"
+	"context"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"net/http/httptest"
 	"os"
+	"path"
 	"strings"
 	"testing"
 	"testing/fstest"
+	"testing/iotest"
 	"time"
 
 	"github.com/ncruces/go-fs/memfs"
@@ -29,6 +42,391 @@ func TestLoad(t *testing.T) {
 	}
 }
 
+func TestLoadCompressed(t *testing.T) {
+	in := fstest.MapFS{}
+	for i := 0; i < 100; i++ {
+		name := fmt.Sprintf("dir%d/file%02d.txt", i%3, i)
+		in[name] = &fstest.MapFile{Data: []byte(strings.Repeat(name, i*10)), Mode: 0444}
+	}
+
+	fsys, err := memfs.LoadCompressed(in, gzip.BestCompression)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, f := range in {
+		if data, err := fsys.ReadFile(name); err != nil || string(data) != string(f.Data) {
+			t.Errorf("%s: got %d bytes, %v", name, len(data), err)
+		}
+	}
+	if err := fstest.TestFS(fsys, "dir0/file00.txt", "dir2/file98.txt"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadWithOptions(t *testing.T) {
+	in := fstest.MapFS{
+		"a.txt":     {Data: []byte(strings.Repeat("a", 100))},
+		"b/b.txt":   {Data: []byte(strings.Repeat("b", 200))},
+		"b/c/c.txt": {Data: []byte(strings.Repeat("c", 300))},
+	}
+
+	tests := []struct {
+		name  string
+		opts  memfs.LoadOptions
+		fails bool
+		file  string // file name in the error
+	}{
+		{name: "unlimited"},
+		{name: "within limits", opts: memfs.LoadOptions{MaxFileBytes: 300, MaxTotalBytes: 600}},
+		{name: "file limit", opts: memfs.LoadOptions{MaxFileBytes: 250}, fails: true, file: "b/c/c.txt"},
+		{name: "total limit", opts: memfs.LoadOptions{MaxTotalBytes: 500}, fails: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.Level = gzip.BestCompression
+			fsys, err := memfs.LoadWithOptions(context.Background(), in, tt.opts)
+
+			var serr *memfs.SizeError
+			switch {
+			case !tt.fails:
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := fstest.TestFS(fsys, "a.txt", "b/b.txt", "b/c/c.txt"); err != nil {
+					t.Fatal(err)
+				}
+			case !errors.As(err, &serr):
+				t.Fatalf("got %v, want *SizeError", err)
+			case serr.Name != tt.file:
+				t.Errorf("got %v", serr)
+			}
+		})
+	}
+}
+
+func TestLoadWithOptions_sourceDateEpoch(t *testing.T) {
+	epoch := time.Unix(1700000000, 0)
+	t.Setenv("SOURCE_DATE_EPOCH", "1700000000")
+
+	text := strings.Repeat("Hello, world!\n", 1000)
+	in := fstest.MapFS{
+		"new.txt": {Data: []byte("new\n" + text), ModTime: epoch.Add(time.Hour)},
+		"old.txt": {Data: []byte("old\n" + text), ModTime: epoch.Add(-time.Hour)},
+	}
+
+	tests := []struct {
+		opts memfs.LoadOptions
+		max  time.Time
+	}{
+		{memfs.LoadOptions{Level: gzip.BestCompression}, epoch},
+		{memfs.LoadOptions{Level: gzip.BestCompression, MaxModTime: epoch.Add(-time.Minute)}, epoch.Add(-time.Minute)},
+	}
+	for _, tt := range tests {
+		fsys, err := memfs.LoadWithOptions(context.Background(), in, tt.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for name, file := range in {
+			want := file.ModTime
+			if want.After(tt.max) {
+				want = tt.max
+			}
+			info, err := fsys.Stat(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !info.ModTime().Equal(want) {
+				t.Errorf("%s: got %v, want %v", name, info.ModTime(), want)
+			}
+
+			// the gzip header is clamped too
+			r := httptest.NewRequest("GET", "/"+name, nil)
+			r.Header.Set("Accept-Encoding", "gzip")
+			w := httptest.NewRecorder()
+			fsys.ServeHTTP(w, r)
+			z, err := gzip.NewReader(w.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !z.ModTime.Equal(want) {
+				t.Errorf("%s: gzip header: got %v, want %v", name, z.ModTime, want)
+			}
+		}
+	}
+}
+
+func TestFileSystem_SetMaxFileSize(t *testing.T) {
+	fsys := memfs.Create()
+	fsys.SetMaxFileSize(10)
+
+	if err := fsys.Create("ok.txt", "", time.Time{}, strings.NewReader("0123456789")); err != nil {
+		t.Fatal(err)
+	}
+	err := fsys.CreateCompressed("large.txt", "", time.Time{}, iotest.HalfReader(strings.NewReader("0123456789+")), gzip.BestCompression)
+	var serr *memfs.SizeError
+	if !errors.As(err, &serr) || serr.Name != "large.txt" || serr.Limit != 10 {
+		t.Errorf("got %v", err)
+	}
+	if _, err := fsys.Stat("large.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestLoadWithOptions_precompressed(t *testing.T) {
+	content := strings.Repeat("console.log('hello');\n", 100)
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write([]byte(content))
+	gz.Close()
+
+	in := fstest.MapFS{
+		"app.js":       {Data: []byte(content)},
+		"app.js.gz":    {Data: buf.Bytes()},
+		"stale.js":     {Data: []byte(content + "// changed\n")},
+		"stale.js.gz":  {Data: buf.Bytes()},
+		"orphan.js.gz": {Data: buf.Bytes()},
+	}
+	fsys, err := memfs.LoadWithOptions(context.Background(), in, memfs.LoadOptions{Precompressed: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, want := range map[string]string{
+		"app.js":       content,
+		"stale.js":     content + "// changed\n",
+		"orphan.js.gz": buf.String(),
+	} {
+		if data, err := fsys.ReadFile(name); err != nil || string(data) != want {
+			t.Errorf("%s: got %d bytes, %v", name, len(data), err)
+		}
+	}
+	for _, name := range []string{"app.js.gz", "stale.js.gz"} {
+		if _, err := fsys.Stat(name); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("%s: got %v", name, err)
+		}
+	}
+
+	// the sibling is stored as is
+	f, err := fsys.HTTPRaw().Open("/app.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if data, err := io.ReadAll(f); err != nil || !bytes.Equal(data, buf.Bytes()) {
+		t.Errorf("got %d bytes, %v", len(data), err)
+	}
+}
+
+func TestLoadWithOptions_expansion(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, data := range map[string]string{
+		"bomb.txt": strings.Repeat("\x00", 1<<20),
+		"text.txt": "Hello, world!",
+	} {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(w, data)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := memfs.LoadWithOptions(context.Background(), zr, memfs.LoadOptions{MaxExpansion: 1000}); err != nil {
+		t.Fatal(err)
+	}
+	_, err = memfs.LoadWithOptions(context.Background(), zr, memfs.LoadOptions{MaxExpansion: 100})
+	var serr *memfs.SizeError
+	if !errors.As(err, &serr) || serr.Name != "bomb.txt" {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestFileSystem_SetMaxFileSize_compressed(t *testing.T) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	io.WriteString(zw, "Hello, world!")
+	zw.Close()
+
+	fsys := memfs.Create()
+	fsys.SetMaxFileSize(1 << 20)
+	fsys.CreateString("bomb.txt", "text/plain", time.Time{}, 0, 1<<40, buf.String())
+	fsys.CreateString("ok.txt", "text/plain", time.Time{}, 0, 13, buf.String())
+
+	var serr *memfs.SizeError
+	if _, err := fsys.ReadFile("bomb.txt"); !errors.As(err, &serr) {
+		t.Errorf("got %v", err)
+	}
+	if _, err := fsys.Open("bomb.txt"); !errors.As(err, &serr) {
+		t.Errorf("got %v", err)
+	}
+	if data, err := fsys.ReadFile("ok.txt"); err != nil || string(data) != "Hello, world!" {
+		t.Errorf("got %q, %v", data, err)
+	}
+}
+
+func TestFileSystem_Seal(t *testing.T) {
+	fsys := memfs.Create()
+	fsys.CreateString("a.txt", "text/plain", time.Time{}, 0, 1, "a")
+	fsys.Seal()
+
+	errs := []error{
+		fsys.Create("b.txt", "", time.Time{}, strings.NewReader("b")),
+		fsys.CreateFile("a.txt", strings.NewReader("b")),
+		fsys.Remove("a.txt"),
+		fsys.Mount("sub", fstest.MapFS{}),
+		fsys.Merge(memfs.Create(), true),
+		fsys.SetHeaders("a.txt", nil),
+		fsys.Mmap(1),
+		fsys.CaseInsensitive(),
+		fsys.CreateString("b.txt", "text/plain", time.Time{}, 0, 1, "b"),
+		fsys.CreateFunc("b.txt", "", func() ([]byte, error) { return nil, nil }),
+		fsys.Link("b.txt", "a.txt"),
+		fsys.CreateDir("dir"),
+		fsys.Tag("a.txt", "tag"),
+		fsys.SetPage("page.html", nil, nil),
+		fsys.Transform(),
+		fsys.SetLanguages("en"),
+		fsys.SetImageFormats(".webp"),
+		fsys.SetMaxFileSize(1),
+		fsys.SetHistory(1),
+		fsys.SetIndex(".", "index.html"),
+		fsys.SetNotFound(nil),
+		fsys.SetETagFunc(nil),
+		fsys.SetOrder("."),
+		fsys.SetPreload("preload"),
+		fsys.SetVersionParam("v"),
+		fsys.SetBuildID("id", time.Time{}),
+		fsys.SetImmutable(nil),
+		fsys.SetLastModified(0),
+		fsys.Hook(memfs.Hooks{}),
+	}
+	for i, err := range errs {
+		if !errors.Is(err, fs.ErrPermission) {
+			t.Errorf("%d: got %v", i, err)
+		}
+	}
+	if data, err := fsys.ReadFile("a.txt"); err != nil || string(data) != "a" {
+		t.Errorf("got %q, %v", data, err)
+	}
+
+	// content is not read
+	r := strings.NewReader("b")
+	fsys.CreateFile("b.txt", r)
+	if r.Len() != 1 {
+		t.Error("read content of sealed file system")
+	}
+}
+
+func TestLoadCompressedContext(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	in := blockingFS{fstest.MapFS{"hi.txt": {Data: []byte("Hello, world!")}}, release}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := memfs.LoadCompressedContext(ctx, in, gzip.BestCompression); err != context.DeadlineExceeded {
+		t.Errorf("got %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+// blockingFS blocks opening files until release is closed.
+type blockingFS struct {
+	fstest.MapFS
+	release chan struct{}
+}
+
+func (b blockingFS) Open(name string) (fs.File, error) {
+	if name != "." {
+		<-b.release
+	}
+	return b.MapFS.Open(name)
+}
+
+func TestFileSystem_invalid(t *testing.T) {
+	fsys := memfs.Create()
+	fsys.CreateString("a/b.txt", "", time.Time{}, 0, 0, "")
+
+	for _, name := range []string{"..", "/a", "a/", "a/../a/b.txt", "a//b.txt", ""} {
+		var perr *fs.PathError
+		if _, err := fsys.Open(name); !errors.As(err, &perr) || perr.Err != fs.ErrInvalid {
+			t.Errorf("Open(%q): got %v", name, err)
+		}
+		if _, err := fsys.Stat(name); !errors.As(err, &perr) || perr.Err != fs.ErrInvalid {
+			t.Errorf("Stat(%q): got %v", name, err)
+		}
+		if _, err := fsys.ReadFile(name); !errors.As(err, &perr) || perr.Err != fs.ErrInvalid {
+			t.Errorf("ReadFile(%q): got %v", name, err)
+		}
+	}
+}
+
+func TestFromMap(t *testing.T) {
+	fsys, err := memfs.FromMap(map[string]memfs.File{
+		"index.html":    {Data: []byte("<h1>Hello, world!</h1>")},
+		"api/data.json": {Data: []byte(`{"hello":"world"}`), MIME: "application/json"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fstest.TestFS(fsys, "index.html", "api/data.json"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := memfs.FromMap(map[string]memfs.File{"a": {}, "a/b": {}}); err != fs.ErrExist {
+		t.Errorf("got %v, want %v", err, fs.ErrExist)
+	}
+}
+
+func TestBuilder(t *testing.T) {
+	modtime := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	fsys, err := memfs.NewBuilder().
+		Add("index.html", "<h1>Hello, world!</h1>").
+		Add("app.js", strings.Repeat("console.log('Hello, world!');\n", 100),
+			memfs.WithCompression(gzip.BestCompression), memfs.WithModTime(modtime)).
+		Add("data", "{}", memfs.WithMIME("application/json")).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fstest.TestFS(fsys, "index.html", "app.js", "data"); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := fsys.Stat("app.js"); err != nil || !info.ModTime().Equal(modtime) {
+		t.Errorf("got %v, %v", info, err)
+	}
+
+	_, err = memfs.NewBuilder().
+		Add("a", "").
+		Add("a/b", "").
+		Add("c", "").
+		Build()
+	if err != fs.ErrExist {
+		t.Errorf("got %v, want %v", err, fs.ErrExist)
+	}
+}
+
+func TestMustLoad(t *testing.T) {
+	in := fstest.MapFS{"static/hi.txt": {Data: []byte("Hello, world!"), Mode: 0444}}
+	fsys := memfs.MustLoad(memfs.MustSub(in, "static"))
+	if err := fstest.TestFS(fsys, "hi.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("want panic")
+		}
+	}()
+	memfs.MustSub(in, "..")
+}
+
 func TestFileSystem_Create(t *testing.T) {
 	fsys := memfs.Create()
 
@@ -43,3 +441,972 @@ func TestFileSystem_Create(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestFileSystem_notExist(t *testing.T) {
+	fsys := memfs.Create()
+	_, open := fsys.Open("missing.txt")
+	_, stat := fsys.Stat("missing.txt")
+	_, read := fsys.ReadFile("missing.txt")
+
+	for op, err := range map[string]error{"open": open, "stat": stat, "readfile": read} {
+		var perr *fs.PathError
+		if !errors.As(err, &perr) || perr.Op != op || perr.Path != "missing.txt" || perr.Err != fs.ErrNotExist {
+			t.Errorf("%s: got %v", op, err)
+		}
+	}
+}
+
+func TestFileSystem_Remove(t *testing.T) {
+	fsys := memfs.Create()
+
+	for _, name := range []string{"a.txt", "dir/b.txt", "dir/sub/c.txt"} {
+		if err := fsys.Create(name, "", time.Now(), &strings.Reader{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := fsys.Remove("dir"); err != fs.ErrInvalid {
+		t.Errorf("Remove(dir): got %v, want %v", err, fs.ErrInvalid)
+	}
+	if err := fsys.Remove("missing.txt"); err != fs.ErrNotExist {
+		t.Errorf("Remove(missing.txt): got %v, want %v", err, fs.ErrNotExist)
+	}
+	if err := fsys.Remove("dir/sub/c.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fsys.Stat("dir/sub"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Stat(dir/sub): got %v, want %v", err, fs.ErrNotExist)
+	}
+
+	if err := fstest.TestFS(fsys, "a.txt", "dir/b.txt"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFileSystem_Merge(t *testing.T) {
+	fsys := memfs.Create()
+	other := memfs.Create()
+
+	for _, name := range []string{"a.txt", "dir/b.txt"} {
+		if err := fsys.Create(name, "", time.Now(), strings.NewReader("fsys")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"dir/b.txt", "dir/c.txt", "sub/d.txt"} {
+		if err := other.Create(name, "", time.Now(), strings.NewReader("other")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := fsys.Merge(other, false); err != fs.ErrExist {
+		t.Errorf("Merge: got %v, want %v", err, fs.ErrExist)
+	}
+	if _, err := fsys.Stat("sub/d.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("failed Merge imported files")
+	}
+
+	if err := fsys.Merge(other, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := fstest.TestFS(fsys, "a.txt", "dir/b.txt", "dir/c.txt", "sub/d.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := fsys.ReadFile("dir/b.txt"); err != nil || string(data) != "other" {
+		t.Errorf("dir/b.txt: got %q, %v", data, err)
+	}
+
+	clash := memfs.Create()
+	if err := clash.Create("a.txt/e.txt", "", time.Now(), &strings.Reader{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := fsys.Merge(clash, true); err != fs.ErrExist {
+		t.Errorf("Merge: got %v, want %v", err, fs.ErrExist)
+	}
+}
+
+func TestFileSystem_Merge_atomic(t *testing.T) {
+	fsys := memfs.Create()
+	if err := fsys.Create("x", "", time.Now(), &strings.Reader{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// an empty directory conflicts with an existing file
+	other := memfs.Create()
+	if err := other.Create("a.txt", "", time.Now(), &strings.Reader{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := other.CreateDir("x/y"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fsys.Merge(other, true); err != fs.ErrExist {
+		t.Errorf("Merge: got %v, want %v", err, fs.ErrExist)
+	}
+	if _, err := fsys.Stat("a.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("failed Merge imported files")
+	}
+
+	// merged files collide with each other, ignoring case
+	if err := fsys.CaseInsensitive(); err != nil {
+		t.Fatal(err)
+	}
+	other = memfs.Create()
+	for _, name := range []string{"B.txt", "b.txt"} {
+		if err := other.Create(name, "", time.Now(), &strings.Reader{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := fsys.Merge(other, true); err != fs.ErrExist {
+		t.Errorf("Merge: got %v, want %v", err, fs.ErrExist)
+	}
+	if _, err := fsys.Stat("B.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("failed Merge imported files")
+	}
+
+	// merged directories collide with each other, ignoring case
+	other = memfs.Create()
+	if err := other.Create("D/c.txt", "", time.Now(), &strings.Reader{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := other.CreateDir("d/e"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fsys.Merge(other, true); err != fs.ErrExist {
+		t.Errorf("Merge: got %v, want %v", err, fs.ErrExist)
+	}
+	if _, err := fsys.Stat("D/c.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("failed Merge imported files")
+	}
+}
+
+func TestFileSystem_Transform(t *testing.T) {
+	upper := func(name string, data []byte) ([]byte, error) {
+		return []byte(strings.ToUpper(string(data))), nil
+	}
+	exclaim := func(name string, data []byte) ([]byte, error) {
+		return append(data, '!'), nil
+	}
+
+	fsys := memfs.Create()
+	fsys.Transform(upper, exclaim)
+
+	if err := fsys.CreateCompressed("hi.txt", "", time.Now(), strings.NewReader("Hello, world"), gzip.BestCompression); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := fsys.ReadFile("hi.txt"); err != nil || string(data) != "HELLO, WORLD!" {
+		t.Errorf("got %q, %v", data, err)
+	}
+
+	fail := func(name string, data []byte) ([]byte, error) {
+		return nil, fs.ErrPermission
+	}
+	if _, err := memfs.Load(fstest.MapFS{"hi.txt": {}}, fail); err != fs.ErrPermission {
+		t.Errorf("got %v, want %v", err, fs.ErrPermission)
+	}
+
+	// transforms passed to Load only apply while loading
+	fsys, err := memfs.Load(fstest.MapFS{"hi.txt": {Data: []byte("Hello, world")}}, upper)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fsys.Create("new.txt", "", time.Now(), strings.NewReader("Hello, world")); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]string{"hi.txt": "HELLO, WORLD", "new.txt": "Hello, world"} {
+		if data, err := fsys.ReadFile(name); err != nil || string(data) != want {
+			t.Errorf("%s: got %q, %v", name, data, err)
+		}
+	}
+}
+
+func TestFileSystem_Stats(t *testing.T) {
+	fsys := memfs.Create()
+	fsys.CreateString("a.txt", "", time.Time{}, 0, 5, "hello")
+	fsys.CreateString("b/c.txt", "", time.Time{}, 0, 100, "0123456789")
+	fsys.CreateString("d", "", time.Time{}, 0, 0, "")
+
+	stats := fsys.Stats()
+	if stats.Files != 3 || stats.Size != 105 || stats.Stored != 15 || stats.Ratio() != 7 {
+		t.Errorf("got %+v", stats)
+	}
+	if txt := stats.ByExt[".txt"]; txt.Files != 2 || txt.Size != 105 {
+		t.Errorf("got %+v", txt)
+	}
+	if none := stats.ByExt[""]; none.Files != 1 || none.Ratio() != 1 {
+		t.Errorf("got %+v", none)
+	}
+}
+
+func TestFileSystem_dedup(t *testing.T) {
+	content := strings.Repeat("0123456789", 200)
+
+	fsys := memfs.Create()
+	for i, name := range []string{"a.txt", "vendor/a.txt", "vendor/b.txt"} {
+		modtime := time.Unix(int64(i), 0)
+		if err := fsys.CreateCompressed(name, "", modtime, strings.NewReader(content), gzip.BestCompression); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stats := fsys.Stats()
+	if stats.Files != 3 || stats.Size != 6000 || stats.Stored >= 100 {
+		t.Errorf("got %+v", stats)
+	}
+	stored := stats.Stored
+
+	if err := fsys.Remove("a.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fsys.Create("vendor/b.txt", "", time.Time{}, strings.NewReader("changed")); err != nil {
+		t.Fatal(err)
+	}
+	if stats := fsys.Stats(); stats.Stored != stored+7 {
+		t.Errorf("got %+v, want %d stored", stats, stored+7)
+	}
+	if data, err := fsys.ReadFile("vendor/a.txt"); err != nil || string(data) != content {
+		t.Errorf("got %q, %v", data, err)
+	}
+	if data, err := fsys.ReadFile("vendor/b.txt"); err != nil || string(data) != "changed" {
+		t.Errorf("got %q, %v", data, err)
+	}
+}
+
+func TestFileSystem_Mmap(t *testing.T) {
+	large := strings.Repeat("0123456789", 1000)
+
+	fsys := memfs.Create()
+	if err := fsys.Create("large.txt", "", time.Now(), strings.NewReader(large)); err != nil {
+		t.Fatal(err)
+	}
+	if err := fsys.Mmap(4096); err != nil {
+		t.Fatal(err)
+	}
+	if err := fsys.CreateCompressed("small.txt", "", time.Now(), strings.NewReader(large), gzip.BestCompression); err != nil {
+		t.Fatal(err)
+	}
+	if err := fsys.Create("dir/large.txt", "", time.Now(), strings.NewReader(large)); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"large.txt", "small.txt", "dir/large.txt"} {
+		if data, err := fsys.ReadFile(name); err != nil || string(data) != large {
+			t.Errorf("%s: got %d bytes, %v", name, len(data), err)
+		}
+	}
+	if err := fstest.TestFS(fsys, "large.txt", "small.txt", "dir/large.txt"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFileSystem_Open_seek(t *testing.T) {
+	var content strings.Builder
+	for i := 0; content.Len() < 10000; i++ {
+		fmt.Fprintln(&content, i)
+	}
+
+	fsys := memfs.Create()
+	if err := fsys.CreateCompressed("seek.txt", "", time.Now(), strings.NewReader(content.String()), gzip.BestCompression); err != nil {
+		t.Fatal(err)
+	}
+	f, err := fsys.Open("seek.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	seeker := f.(io.ReadSeeker)
+	want := strings.NewReader(content.String())
+	for _, off := range []int64{100, 5000, 5010, 9000, 200, 0, 9990} {
+		seeker.Seek(off, io.SeekStart)
+		want.Seek(off, io.SeekStart)
+		got := make([]byte, 20)
+		exp := make([]byte, 20)
+		n, _ := io.ReadFull(seeker, got)
+		m, _ := io.ReadFull(want, exp)
+		if string(got[:n]) != string(exp[:m]) {
+			t.Errorf("at %d: got %q, want %q", off, got[:n], exp[:m])
+		}
+	}
+}
+
+func TestFileSystem_CaseInsensitive(t *testing.T) {
+	fsys := memfs.Create()
+	fsys.CreateString("Assets/Logo.PNG", "image/png", time.Time{}, 0, 4, "logo")
+	fsys.CreateString("index.html", "text/html", time.Time{}, 0, 5, "index")
+	if err := fsys.CaseInsensitive(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"assets/logo.png", "ASSETS/LOGO.PNG", "Assets/Logo.PNG", "INDEX.html"} {
+		if _, err := fsys.Stat(name); err != nil {
+			t.Errorf("Stat(%q): %v", name, err)
+		}
+	}
+	if err := fsys.Create("assets/logo.png", "", time.Now(), &strings.Reader{}); err != fs.ErrExist {
+		t.Errorf("got %v, want %v", err, fs.ErrExist)
+	}
+	if err := fsys.Create("ASSETS/other.png", "", time.Now(), &strings.Reader{}); err != fs.ErrExist {
+		t.Errorf("got %v, want %v", err, fs.ErrExist)
+	}
+	if err := fsys.Create("Assets/other.png", "", time.Now(), &strings.Reader{}); err != nil {
+		t.Error(err)
+	}
+	if err := fsys.Remove("Assets/Logo.PNG"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fsys.Create("Assets/logo.png", "", time.Now(), &strings.Reader{}); err != nil {
+		t.Error(err)
+	}
+	if err := fstest.TestFS(fsys, "Assets/logo.png", "Assets/other.png", "index.html"); err != nil {
+		t.Fatal(err)
+	}
+
+	collide := memfs.Create()
+	collide.CreateString("a.txt", "", time.Time{}, 0, 0, "")
+	collide.CreateString("A.txt", "", time.Time{}, 0, 0, "")
+	if err := collide.CaseInsensitive(); err != fs.ErrExist {
+		t.Errorf("got %v, want %v", err, fs.ErrExist)
+	}
+}
+
+func TestFileSystem_ReadDir(t *testing.T) {
+	fsys := memfs.Create()
+
+	for _, name := range []string{"c.txt", "a/z.txt", "b.txt", "a/y.txt", "a.txt", "a/x/w.txt"} {
+		if err := fsys.Create(name, "", time.Now(), &strings.Reader{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fsys.CreateString("d/b.txt", "", time.Now(), 0, 0, "")
+	fsys.CreateString("d/a.txt", "", time.Now(), 0, 0, "")
+
+	for dir, want := range map[string]string{
+		".": "a,a.txt,b.txt,c.txt,d",
+		"a": "x,y.txt,z.txt",
+		"d": "a.txt,b.txt",
+	} {
+		entries, err := fsys.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		if got := strings.Join(names, ","); got != want {
+			t.Errorf("ReadDir(%s): got %s, want %s", dir, got, want)
+		}
+	}
+
+	if err := fstest.TestFS(fsys, "a.txt", "a/x/w.txt", "d/a.txt"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFileSystem_CreateCompressed(t *testing.T) {
+	fsys := memfs.Create()
+
+	text := strings.Repeat("Hello, world!\n", 1000)
+	readers := map[string]func() io.Reader{
+		"sized.txt":   func() io.Reader { return strings.NewReader(text) },
+		"unsized.txt": func() io.Reader { return iotest.OneByteReader(strings.NewReader(text)) },
+		"short.txt":   func() io.Reader { return &lenReader{strings.NewReader(text), 10} },
+		"long.txt":    func() io.Reader { return &lenReader{strings.NewReader(text), 100000} },
+	}
+	for name, reader := range readers {
+		for _, level := range []int{gzip.NoCompression, gzip.BestCompression} {
+			if err := fsys.CreateCompressed(name, "", time.Now(), reader(), level); err != nil {
+				t.Fatal(err)
+			}
+			if data, err := fsys.ReadFile(name); err != nil {
+				t.Fatal(err)
+			} else if string(data) != text {
+				t.Errorf("%s: content mismatch", name)
+			}
+		}
+	}
+}
+
+func TestFileSystem_CreateCompressed_names(t *testing.T) {
+	fsys := memfs.Create()
+
+	text := strings.Repeat("Hello, world!\n", 1000)
+	for _, name := range []string{"café.txt", "日本語.txt", "emoji 🎉.txt", "nul\x00.txt"} {
+		if err := fsys.CreateCompressed(name, "", time.Now(), strings.NewReader(text), gzip.BestCompression); err != nil {
+			t.Fatal(err)
+		}
+		info, err := fsys.Stat(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sys := info.Sys().(*memfs.SysInfo); sys.Encoding != "gzip" {
+			t.Errorf("%q: not compressed", name)
+		}
+		if data, err := fsys.ReadFile(name); err != nil {
+			t.Fatal(err)
+		} else if string(data) != text {
+			t.Errorf("%q: content mismatch", name)
+		}
+	}
+}
+
+// lenReader misreports its length.
+type lenReader struct {
+	io.Reader
+	len int
+}
+
+func (r *lenReader) Len() int { return r.len }
+
+func benchmarkNames() []string {
+	var names []string
+	for i := 0; i < 20; i++ {
+		for j := 0; j < 20; j++ {
+			for k := 0; k < 25; k++ {
+				names = append(names, fmt.Sprintf("assets/dir%02d/sub%02d/file%02d.txt", i, j, k))
+			}
+		}
+	}
+	return names
+}
+
+func BenchmarkFileSystem_Stat(b *testing.B) {
+	fsys := memfs.Create()
+	names := benchmarkNames()
+	for _, name := range names {
+		fsys.CreateString(name, "text/plain", time.Time{}, 0, 0, "")
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := fsys.Stat(names[i%len(names)]); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkFileSystem_CreateString(b *testing.B) {
+	names := benchmarkNames()
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		fsys := memfs.Create()
+		for _, name := range names {
+			fsys.CreateString(name, "text/plain", time.Time{}, 0, 0, "")
+		}
+	}
+}
+
+func TestFileSystem_Integrity(t *testing.T) {
+	content := strings.Repeat("console.log('hello');\n", 100)
+	sum := sha512.Sum384([]byte(content))
+	want := "sha384-" + base64.StdEncoding.EncodeToString(sum[:])
+
+	fsys := memfs.Create()
+	if err := fsys.Create("app.js", "", time.Time{}, strings.NewReader(content)); err != nil {
+		t.Fatal(err)
+	}
+	if err := fsys.Mount("ext", fstest.MapFS{"app.js": {Data: []byte(content)}}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"app.js", "app.js", "ext/app.js"} {
+		if got, err := fsys.Integrity(name); err != nil || got != want {
+			t.Errorf("%s: got %q, %v", name, got, err)
+		}
+	}
+	if _, err := fsys.Integrity("missing.js"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("got %v", err)
+	}
+
+	// overwritten content gets a new checksum
+	content = strings.ToUpper(content)
+	sum = sha512.Sum384([]byte(content))
+	want = "sha384-" + base64.StdEncoding.EncodeToString(sum[:])
+	if err := fsys.Create("app.js", "", time.Time{}, strings.NewReader(content)); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := fsys.Integrity("app.js"); err != nil || got != want {
+		t.Errorf("overwritten: got %q, %v", got, err)
+	}
+	if _, err := fsys.Integrity("ext"); err == nil {
+		t.Error("want error")
+	}
+}
+
+func TestFileSystem_Hash(t *testing.T) {
+	fsys := memfs.Create()
+	fsys.CreateString("a.txt", "text/plain", time.Time{}, 0x1234, 1, "a")
+	fsys.CreateString("b.txt", "text/plain", time.Time{}, 0, 0, "")
+	if err := fsys.Create("c.txt", "", time.Time{}, strings.NewReader("c")); err != nil {
+		t.Fatal(err)
+	}
+
+	if hash, ok := fsys.Hash("a.txt"); !ok || hash != 0x1234 {
+		t.Errorf("got %#x, %v", hash, ok)
+	}
+	if hash, ok := fsys.Hash("c.txt"); !ok || hash == 0 {
+		t.Errorf("got %#x, %v", hash, ok)
+	}
+	for _, name := range []string{"b.txt", "missing.txt", "."} {
+		if _, ok := fsys.Hash(name); ok {
+			t.Errorf("%s: want false", name)
+		}
+	}
+}
+
+func TestFileSystem_CreateFunc(t *testing.T) {
+	fsys := memfs.Create()
+	if err := fsys.Create("a.css", "", time.Time{}, strings.NewReader("a{}")); err != nil {
+		t.Fatal(err)
+	}
+
+	calls := 0
+	fail := true
+	err := fsys.CreateFunc("bundle.css", "text/css", func() ([]byte, error) {
+		calls++
+		if fail {
+			return nil, fs.ErrPermission
+		}
+		a, err := fsys.ReadFile("a.css")
+		return append(a, "b{}"...), err
+	}, memfs.WithCompression(gzip.BestCompression))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := fsys.ReadFile("bundle.css"); !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("got %v", err)
+	}
+	if s := fsys.Stats(); s.Files != 1 {
+		t.Errorf("got %+v", s)
+	}
+
+	fail = false
+	for i := 0; i < 2; i++ {
+		if data, err := fsys.ReadFile("bundle.css"); err != nil || string(data) != "a{}b{}" {
+			t.Errorf("got %q, %v", data, err)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("got %d calls", calls)
+	}
+	if info, err := fsys.Stat("bundle.css"); err != nil || info.Name() != "bundle.css" || info.Size() != 6 {
+		t.Errorf("got %v, %v", info, err)
+	}
+	if s := fsys.Stats(); s.Files != 2 || s.ByExt[".css"].Size != 9 {
+		t.Errorf("got %+v", s)
+	}
+
+	r := httptest.NewRequest("GET", "/bundle.css", nil)
+	w := httptest.NewRecorder()
+	fsys.ServeHTTP(w, r)
+	if w.Code != 200 || w.Header().Get("Content-Type") != "text/css" || w.Body.String() != "a{}b{}" {
+		t.Errorf("got %d, %v, %q", w.Code, w.Header(), w.Body.String())
+	}
+}
+
+func TestFileSystem_Link(t *testing.T) {
+	fsys := memfs.Create()
+	html := strings.Repeat("<p>Hello, world!</p>", 100)
+	if err := fsys.CreateCompressed("static/favicon.ico", "", time.Time{}, strings.NewReader(html), gzip.BestCompression); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := fsys.Link("favicon.ico", "static/favicon.ico"); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := fsys.ReadFile("favicon.ico"); err != nil || string(data) != html {
+		t.Errorf("got %q, %v", data, err)
+	}
+	if info, err := fsys.Stat("favicon.ico"); err != nil || info.Name() != "favicon.ico" {
+		t.Errorf("got %v, %v", info, err)
+	}
+	if s := fsys.Stats(); s.Files != 2 || s.Size != 2*int64(len(html)) || s.Stored >= int64(len(html)) {
+		t.Errorf("content not shared: %+v", s)
+	}
+
+	if err := fsys.Remove("static/favicon.ico"); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := fsys.ReadFile("favicon.ico"); err != nil || string(data) != html {
+		t.Errorf("got %q, %v", data, err)
+	}
+
+	if err := fsys.Link("missing", "static/favicon.ico"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("got %v", err)
+	}
+	if err := fsys.Link("static", "favicon.ico"); err != nil {
+		t.Error(err)
+	}
+	if err := fsys.Link("static/a", "favicon.ico"); !errors.Is(err, fs.ErrExist) {
+		t.Errorf("got %v", err)
+	}
+	if err := fsys.Link("a", "."); !errors.Is(err, fs.ErrInvalid) {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestFileSystem_SetOrder(t *testing.T) {
+	fsys, err := memfs.NewBuilder().
+		Add("guide/install.html", "").
+		Add("guide/introduction.html", "").
+		Add("guide/appendix.html", "").
+		Add("guide/reference/api.html", "").
+		Add("guide/faq.html", "").
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fsys.SetOrder("guide", "introduction.html", "install.html", "missing", "reference", "install.html")
+
+	want := []string{"introduction.html", "install.html", "reference", "appendix.html", "faq.html"}
+
+	list, err := fsys.ReadDir("guide")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, d := range list {
+		got = append(got, d.Name())
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("ReadDir: got %v, want %v", got, want)
+	}
+
+	got = nil
+	fsys.WalkDir("guide", func(name string, d fs.DirEntry, err error) error {
+		if name != "guide" && path.Dir(name) == "guide" {
+			got = append(got, d.Name())
+		}
+		return err
+	})
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("WalkDir: got %v, want %v", got, want)
+	}
+}
+
+func TestFileSystem_Stat_sys(t *testing.T) {
+	fsys := memfs.Create()
+	html := strings.Repeat("<p>Hello, world!</p>", 100)
+	if err := fsys.CreateCompressed("index.html", "", time.Time{}, strings.NewReader(html), gzip.BestCompression); err != nil {
+		t.Fatal(err)
+	}
+	if err := fsys.Create("hi.txt", "text/plain", time.Time{}, strings.NewReader("Hello, world!")); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := fsys.Stat("index.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sys, ok := info.Sys().(*memfs.SysInfo)
+	if !ok || sys.Encoding != "gzip" || sys.Stored >= info.Size() || sys.Hash == 0 || sys.MIME != "text/html; charset=utf-8" {
+		t.Errorf("got %+v", info.Sys())
+	}
+
+	info, err = fsys.Stat("hi.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sys, ok = info.Sys().(*memfs.SysInfo)
+	if !ok || sys.Encoding != "" || sys.Stored != info.Size() || sys.MIME != "text/plain" {
+		t.Errorf("got %+v", info.Sys())
+	}
+
+	if info, err := fsys.Stat("."); err != nil || info.Sys() != nil {
+		t.Errorf("got %v, %v", info, err)
+	}
+}
+
+func TestFileSystem_ReadFile_corrupt(t *testing.T) {
+	html := strings.Repeat("<p>Hello, world!</p>", 100)
+	var buf bytes.Buffer
+	z := gzip.NewWriter(&buf)
+	z.Write([]byte(html))
+	z.Close()
+	gz := buf.String()
+	corrupt := func(i int) string {
+		b := []byte(gz)
+		b[len(b)+i] ^= 1
+		return string(b)
+	}
+
+	fsys := memfs.Create()
+	fsys.CreateString("bad/crc.html", "text/html", time.Time{}, 0, len(html), corrupt(-8))
+	fsys.CreateString("bad/huge.html", "text/html", time.Time{}, 0, 1<<40, gz)
+	fsys.CreateString("bad/isize.html", "text/html", time.Time{}, 0, len(html), corrupt(-4))
+	fsys.CreateString("bad/long.html", "text/html", time.Time{}, 0, len(html)-1, gz)
+	fsys.CreateString("bad/short.html", "text/html", time.Time{}, 0, len(html)+1, gz)
+	fsys.CreateString("bad/truncated.html", "text/html", time.Time{}, 0, len(html), gz[:len(gz)-4])
+	fsys.CreateString("good.html", "text/html", time.Time{}, 0, len(html), gz)
+
+	for _, name := range []string{"bad/crc.html", "bad/huge.html", "bad/isize.html", "bad/long.html", "bad/short.html", "bad/truncated.html"} {
+		if data, err := fsys.ReadFile(name); err == nil {
+			t.Errorf("%s: got %d bytes, want error", name, len(data))
+		}
+	}
+	if data, err := fsys.ReadFile("good.html"); err != nil || string(data) != html {
+		t.Errorf("good.html: got %d bytes, %v", len(data), err)
+	}
+}
+
+func TestFileSystem_Verify(t *testing.T) {
+	html := strings.Repeat("<p>Hello, world!</p>", 100)
+	var buf bytes.Buffer
+	z := gzip.NewWriter(&buf)
+	z.Write([]byte(html))
+	z.Close()
+	gz := buf.String()
+	bad := []byte(gz)
+	bad[len(bad)-8] ^= 1 // CRC-32
+
+	fsys := memfs.Create()
+	fsys.CreateString("good.html", "text/html", time.Time{}, 0, len(html), gz)
+	fsys.CreateString("plain.html", "text/html", time.Time{}, 0, len(html), html)
+	if err := fsys.Verify(); err != nil {
+		t.Fatal(err)
+	}
+
+	fsys.CreateString("crc.html", "text/html", time.Time{}, 0, len(html), string(bad))
+	fsys.CreateString("size.html", "text/html", time.Time{}, 0, len(html)+1, gz)
+	err := fsys.Verify()
+	if !errors.Is(err, gzip.ErrChecksum) {
+		t.Errorf("got %v", err)
+	}
+	for _, name := range []string{"crc.html", "size.html"} {
+		if !strings.Contains(fmt.Sprint(err), name) {
+			t.Errorf("%s: not reported in %v", name, err)
+		}
+	}
+	if strings.Contains(fmt.Sprint(err), "good.html") {
+		t.Errorf("good.html: reported in %v", err)
+	}
+}
+
+func TestFileSystem_Verify_hash(t *testing.T) {
+	html := strings.Repeat("<p>Hello, world!</p>", 100)
+	var buf bytes.Buffer
+	z := gzip.NewWriter(&buf)
+	z.Write([]byte(html))
+	z.Close()
+	gz := buf.String()
+	crc := crc32.Checksum([]byte(html), crc32.MakeTable(crc32.Castagnoli))
+
+	fsys := memfs.Create()
+	fsys.CreateString("bad/compressed.html", "text/html", time.Time{}, 12345, len(html), gz)
+	fsys.CreateString("bad/corrupt.html", "text/html", time.Time{}, crc, len(html), strings.ToUpper(html))
+	fsys.CreateString("bad/plain.html", "text/html", time.Time{}, 12345, len(html), html)
+	fsys.CreateString("good/compressed.html", "text/html", time.Time{}, crc, len(html), gz)
+	fsys.CreateString("good/plain.html", "text/html", time.Time{}, crc, len(html), html)
+	if err := fsys.Create("good/created.html", "", time.Time{}, strings.NewReader(html)); err != nil {
+		t.Fatal(err)
+	}
+	if err := fsys.CreateCompressed("good/gzipped.html", "", time.Time{}, strings.NewReader(html), gzip.BestCompression); err != nil {
+		t.Fatal(err)
+	}
+
+	err := fsys.Verify()
+	for _, name := range []string{"bad/compressed.html", "bad/corrupt.html", "bad/plain.html"} {
+		if !strings.Contains(fmt.Sprint(err), name) {
+			t.Errorf("%s: not reported in %v", name, err)
+		}
+	}
+	if strings.Contains(fmt.Sprint(err), "good/") {
+		t.Errorf("good files reported in %v", err)
+	}
+}
+
+func TestFileSystem_FindByHash(t *testing.T) {
+	fsys := memfs.Create()
+	for _, name := range []string{"b.txt", "a.txt", "c.txt"} {
+		content := "hello"
+		if name == "c.txt" {
+			content = "world"
+		}
+		if err := fsys.Create(name, "", time.Time{}, strings.NewReader(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	hash, _ := fsys.Hash("a.txt")
+	if got := fsys.FindByHash(hash); fmt.Sprint(got) != "[a.txt b.txt]" {
+		t.Errorf("got %v", got)
+	}
+
+	fsys.Remove("a.txt")
+	if err := fsys.Create("d.txt", "", time.Time{}, strings.NewReader("hello")); err != nil {
+		t.Fatal(err)
+	}
+	if got := fsys.FindByHash(hash); fmt.Sprint(got) != "[b.txt d.txt]" {
+		t.Errorf("got %v", got)
+	}
+	if got := fsys.FindByHash(hash + 1); got != nil {
+		t.Errorf("got %v", got)
+	}
+}
+
+func TestFileSystem_SetHistory(t *testing.T) {
+	fsys := memfs.Create()
+	fsys.SetHistory(2)
+
+	for _, content := range []string{"v1", "v2", "v3", "v4"} {
+		if err := fsys.Create("app.js", "", time.Time{}, strings.NewReader(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for n, want := range []string{"v4", "v3", "v2"} {
+		f, err := fsys.OpenVersion("app.js", n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(f)
+		f.Close()
+		if err != nil || string(data) != want {
+			t.Errorf("version %d: got %q, %v", n, data, err)
+		}
+	}
+	if _, err := fsys.OpenVersion("app.js", 3); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("got %v", err)
+	}
+	if _, err := fsys.OpenVersion("missing.js", 1); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("got %v", err)
+	}
+
+	for _, content := range []string{"v1", "v2"} {
+		if err := fsys.Create("js/app.js", "", time.Time{}, strings.NewReader(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if f, err := fsys.OpenVersion("js/app.js", 1); err != nil {
+		t.Error(err)
+	} else {
+		data, err := io.ReadAll(f)
+		f.Close()
+		if err != nil || string(data) != "v1" {
+			t.Errorf("js/app.js version 1: got %q, %v", data, err)
+		}
+	}
+
+	fsys.SetHistory(0)
+	if _, err := fsys.OpenVersion("app.js", 1); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestFileSystem_Tag(t *testing.T) {
+	fsys, err := memfs.NewBuilder().
+		Add("index.html", "<p>Hello</p>").
+		Add("app.css", "p{}").
+		Add("secret.txt", "secret").
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"index.html", "app.css"} {
+		if err := fsys.Tag(name, "public"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := fsys.Tag("app.css", "critical", "public"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fsys.Tag("missing.txt", "public"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("got %v", err)
+	}
+
+	if got := fsys.Tags("app.css"); fmt.Sprint(got) != "[critical public]" {
+		t.Errorf("got %v", got)
+	}
+	if got := fsys.Tagged("public"); fmt.Sprint(got) != "[app.css index.html]" {
+		t.Errorf("got %v", got)
+	}
+	if !fsys.HasTag("app.css", "critical") || fsys.HasTag("index.html", "critical") {
+		t.Error("HasTag")
+	}
+
+	public := fsys.Filter(func(name string, info fs.FileInfo) bool {
+		return info.IsDir() || fsys.HasTag(name, "public")
+	})
+	if err := fstest.TestFS(public, "index.html", "app.css"); err != nil {
+		t.Error(err)
+	}
+	if _, err := fs.Stat(public, "secret.txt"); err == nil {
+		t.Error("want error")
+	}
+
+	fsys.Remove("app.css")
+	if got := fsys.Tagged("public"); fmt.Sprint(got) != "[index.html]" {
+		t.Errorf("got %v", got)
+	}
+}
+
+func TestFileSystem_SetBuildID(t *testing.T) {
+	fsys := memfs.Create()
+	if id := fsys.BuildID(); id != "" {
+		t.Errorf("got %q", id)
+	}
+
+	built := time.Unix(1700000000, 0)
+	fsys.SetBuildID("1a2b3c4d", built)
+	if id := fsys.BuildID(); id != "1a2b3c4d" {
+		t.Errorf("got %q", id)
+	}
+	if got := fsys.BuildTime(); !got.Equal(built) {
+		t.Errorf("got %v", got)
+	}
+}
+
+func TestFileSystem_CreateDir(t *testing.T) {
+	fsys := memfs.Create()
+	fsys.CreateString("f.txt", "text/plain", time.Time{}, 0, 1, "f")
+	for _, name := range []string{"a/b/c", "a/b", ".", "e"} {
+		if err := fsys.CreateDir(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := fsys.CreateDir("f.txt"); !errors.Is(err, fs.ErrExist) {
+		t.Errorf("got %v", err)
+	}
+	if err := fsys.CreateDir("f.txt/g"); !errors.Is(err, fs.ErrExist) {
+		t.Errorf("got %v", err)
+	}
+	if err := fsys.CreateDir("../a"); !errors.Is(err, fs.ErrInvalid) {
+		t.Errorf("got %v", err)
+	}
+	if err := fstest.TestFS(fsys, "f.txt", "a/b/c", "e"); err != nil {
+		t.Fatal(err)
+	}
+	if got := fsys.Dirs(); fmt.Sprint(got) != "[a a/b a/b/c e]" {
+		t.Errorf("got %v", got)
+	}
+
+	merged := memfs.Create()
+	if err := merged.Merge(fsys, false); err != nil {
+		t.Fatal(err)
+	}
+	if got := merged.Dirs(); fmt.Sprint(got) != "[a a/b a/b/c e]" {
+		t.Errorf("got %v", got)
+	}
+
+	// removing an empty directory removes parents left empty
+	if err := fsys.Remove("a/b/c"); err != nil {
+		t.Fatal(err)
+	}
+	if got := fsys.Dirs(); fmt.Sprint(got) != "[e]" {
+		t.Errorf("got %v", got)
+	}
+
+	loaded, err := memfs.LoadWithOptions(context.Background(), fstest.MapFS{
+		"f.txt":     {Data: []byte("f")},
+		"d/g.txt":   {Data: []byte("g")},
+		"empty/sub": {Mode: fs.ModeDir},
+	}, memfs.LoadOptions{EmptyDirs: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := loaded.Dirs(); fmt.Sprint(got) != "[d empty empty/sub]" {
+		t.Errorf("got %v", got)
+	}
+}
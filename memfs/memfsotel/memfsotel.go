@@ -0,0 +1,81 @@
+// Package memfsotel traces serving a memfs.FileSystem with OpenTelemetry.
+//
+// This is a separate module, so OpenTelemetry isn't a dependency of memfs.
+package memfsotel
+
+import (
+	"net/http"
+
+	"github.com/ncruces/go-fs/memfs"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const scope = "github.com/ncruces/go-fs/memfs/memfsotel"
+
+// Hooks returns hooks that trace each request with a span,
+// using the tracer provider tp (or the global provider, if nil).
+//
+// If the request context already has a span (e.g. from otelhttp), the span is its child.
+// Otherwise, the parent is extracted from the request headers with the global propagator.
+//
+// Spans have the request path, the name of the file served,
+// the content encoding of the response, if the file is stored compressed,
+// if it was served from memory (for a memfs.Cache, if it didn't have to be loaded),
+// and the number of body bytes written.
+//
+// Add the hooks after any hooks that can stop processing requests.
+//
+// Usage:
+//
+//	assets.Hook(memfsotel.Hooks(nil))
+func Hooks(tp trace.TracerProvider) memfs.Hooks {
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+	tracer := tp.Tracer(scope)
+
+	return memfs.Hooks{
+		OnRequest: func(w http.ResponseWriter, r *http.Request) bool {
+			ctx := r.Context()
+			kind := trace.SpanKindInternal
+			if !trace.SpanContextFromContext(ctx).IsValid() {
+				ctx = otel.GetTextMapPropagator().Extract(ctx, propagation.HeaderCarrier(r.Header))
+				kind = trace.SpanKindServer
+			}
+			ctx, _ = tracer.Start(ctx, "memfs.serve", trace.WithSpanKind(kind),
+				trace.WithAttributes(
+					attribute.String("http.request.method", r.Method),
+					attribute.String("url.path", r.URL.Path)))
+			*r = *r.WithContext(ctx)
+			return true
+		},
+		OnServe: func(r *http.Request, info memfs.ServeInfo) {
+			trace.SpanFromContext(r.Context()).SetAttributes(
+				attribute.String("memfs.file", info.Name),
+				attribute.Bool("memfs.compressed", info.Compressed),
+				attribute.Bool("memfs.cache_hit", !info.Loaded))
+		},
+		OnWrite: func(w http.ResponseWriter, r *http.Request, status int) {
+			encoding := w.Header().Get("Content-Encoding")
+			if encoding == "" {
+				encoding = "identity"
+			}
+			trace.SpanFromContext(r.Context()).SetAttributes(
+				attribute.String("memfs.encoding", encoding))
+		},
+		OnDone: func(r *http.Request, status int, written int64) {
+			span := trace.SpanFromContext(r.Context())
+			span.SetAttributes(
+				attribute.Int("http.response.status_code", status),
+				attribute.Int64("http.response.body.size", written))
+			if status >= 500 {
+				span.SetStatus(codes.Error, http.StatusText(status))
+			}
+			span.End()
+		},
+	}
+}
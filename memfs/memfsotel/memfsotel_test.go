@@ -0,0 +1,60 @@
+package memfsotel_test
+
+import (
+	"compress/gzip"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/ncruces/go-fs/memfs"
+	"github.com/ncruces/go-fs/memfs/memfsotel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestHooks(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	html := strings.Repeat("<p>Hello, world!</p>", 100)
+	cache := memfs.NewCache(fstest.MapFS{
+		"index.html": {Data: []byte(html)},
+	}, gzip.BestCompression)
+	cache.Hook(memfsotel.Hooks(tp))
+
+	for _, encoding := range []string{"gzip", ""} {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Accept-Encoding", encoding)
+		cache.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans", len(spans))
+	}
+	for i, want := range []map[attribute.Key]attribute.Value{{
+		"url.path":                  attribute.StringValue("/"),
+		"memfs.file":                attribute.StringValue("index.html"),
+		"memfs.encoding":            attribute.StringValue("gzip"),
+		"memfs.compressed":          attribute.BoolValue(true),
+		"memfs.cache_hit":           attribute.BoolValue(false),
+		"http.response.status_code": attribute.IntValue(200),
+	}, {
+		"memfs.encoding":            attribute.StringValue("identity"),
+		"memfs.cache_hit":           attribute.BoolValue(true),
+		"http.response.body.size":   attribute.Int64Value(int64(len(html))),
+		"http.response.status_code": attribute.IntValue(200),
+	}} {
+		got := map[attribute.Key]attribute.Value{}
+		for _, kv := range spans[i].Attributes() {
+			got[kv.Key] = kv.Value
+		}
+		for k, v := range want {
+			if got[k] != v {
+				t.Errorf("span %d: %s: got %v, want %v", i, k, got[k].Emit(), v.Emit())
+			}
+		}
+	}
+}
@@ -0,0 +1,109 @@
+// Package memfsprom exports Prometheus metrics about serving a memfs.FileSystem.
+//
+// This is a separate module, so Prometheus isn't a dependency of memfs.
+package memfsprom
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/ncruces/go-fs/memfs"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Collector is a prometheus.Collector of metrics about serving a memfs.FileSystem:
+// requests by status code and content encoding, bytes served,
+// decompressions for clients that don't accept gzip,
+// and (optionally) cache hits and misses.
+//
+// Usage:
+//
+//	collector := memfsprom.NewCollector(nil)
+//	assets.Hook(collector.Hooks())
+//	prometheus.MustRegister(collector)
+type Collector struct {
+	requests *prometheus.CounterVec
+	bytes    prometheus.Counter
+	inflated prometheus.CounterFunc
+
+	cache  *memfs.Cache
+	hits   *prometheus.Desc
+	misses *prometheus.Desc
+	ratio  *prometheus.Desc
+}
+
+// NewCollector creates a Collector.
+// If cache is not nil, its hits and misses are also collected.
+func NewCollector(cache *memfs.Cache) *Collector {
+	return &Collector{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "memfs_http_requests_total",
+			Help: "Number of HTTP requests served, by status code and content encoding.",
+		}, []string{"code", "encoding"}),
+		bytes: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "memfs_http_response_bytes_total",
+			Help: "Number of response body bytes served.",
+		}),
+		inflated: prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "memfs_decompressions_total",
+			Help: "Number of times compressed content was decompressed, for clients that don't accept gzip.",
+		}, func() float64 { return float64(memfs.Decompressions()) }),
+
+		cache: cache,
+		hits: prometheus.NewDesc("memfs_cache_hits_total",
+			"Number of lookups served from the cache.", nil, nil),
+		misses: prometheus.NewDesc("memfs_cache_misses_total",
+			"Number of lookups that loaded a file into the cache.", nil, nil),
+		ratio: prometheus.NewDesc("memfs_cache_hit_ratio",
+			"Ratio of lookups served from the cache.", nil, nil),
+	}
+}
+
+// Hooks returns the hooks that count requests and bytes served.
+// Add them to a memfs.FileSystem (or memfs.Cache) with Hook.
+func (c *Collector) Hooks() memfs.Hooks {
+	return memfs.Hooks{
+		OnWrite: func(w http.ResponseWriter, r *http.Request, status int) {
+			encoding := w.Header().Get("Content-Encoding")
+			if encoding == "" {
+				encoding = "identity"
+			}
+			c.requests.WithLabelValues(strconv.Itoa(status), encoding).Inc()
+		},
+		OnDone: func(r *http.Request, status int, written int64) {
+			c.bytes.Add(float64(written))
+		},
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	c.requests.Describe(ch)
+	c.bytes.Describe(ch)
+	c.inflated.Describe(ch)
+	if c.cache != nil {
+		ch <- c.hits
+		ch <- c.misses
+		ch <- c.ratio
+	}
+}
+
+// Collect implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.requests.Collect(ch)
+	c.bytes.Collect(ch)
+	c.inflated.Collect(ch)
+	if c.cache != nil {
+		stats := c.cache.Stats()
+		ratio := 0.0
+		if n := stats.Hits + stats.Misses; n != 0 {
+			ratio = float64(stats.Hits) / float64(n)
+		}
+		ch <- prometheus.MustNewConstMetric(c.hits, prometheus.CounterValue, float64(stats.Hits))
+		ch <- prometheus.MustNewConstMetric(c.misses, prometheus.CounterValue, float64(stats.Misses))
+		ch <- prometheus.MustNewConstMetric(c.ratio, prometheus.GaugeValue, ratio)
+	}
+}
+
+// Check interface implementations
+var _ prometheus.Collector = &Collector{}
@@ -0,0 +1,59 @@
+package memfsprom_test
+
+import (
+	"compress/gzip"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/ncruces/go-fs/memfs"
+	"github.com/ncruces/go-fs/memfs/memfsprom"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCollector(t *testing.T) {
+	html := strings.Repeat("<p>Hello, world!</p>", 100)
+	cache := memfs.NewCache(fstest.MapFS{
+		"index.html": {Data: []byte(html)},
+	}, gzip.BestCompression)
+
+	collector := memfsprom.NewCollector(cache)
+	cache.Hook(collector.Hooks())
+
+	get := func(path string, gzip bool) {
+		r := httptest.NewRequest("GET", path, nil)
+		if gzip {
+			r.Header.Set("Accept-Encoding", "gzip")
+		}
+		cache.ServeHTTP(httptest.NewRecorder(), r)
+	}
+	get("/", true)
+	get("/", false)
+	get("/missing", false)
+
+	want := `
+# HELP memfs_cache_hits_total Number of lookups served from the cache.
+# TYPE memfs_cache_hits_total counter
+memfs_cache_hits_total 1
+# HELP memfs_cache_misses_total Number of lookups that loaded a file into the cache.
+# TYPE memfs_cache_misses_total counter
+memfs_cache_misses_total 1
+# HELP memfs_http_requests_total Number of HTTP requests served, by status code and content encoding.
+# TYPE memfs_http_requests_total counter
+memfs_http_requests_total{code="200",encoding="gzip"} 1
+memfs_http_requests_total{code="200",encoding="identity"} 1
+memfs_http_requests_total{code="404",encoding="identity"} 1
+`
+	err := testutil.CollectAndCompare(collector, strings.NewReader(want),
+		"memfs_cache_hits_total", "memfs_cache_misses_total", "memfs_http_requests_total")
+	if err != nil {
+		t.Error(err)
+	}
+
+	for _, name := range []string{"memfs_http_response_bytes_total", "memfs_decompressions_total", "memfs_cache_hit_ratio"} {
+		if n := testutil.CollectAndCount(collector, name); n != 1 {
+			t.Errorf("%s: got %d metrics", name, n)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+// Package memfstest implements support for testing memfs file systems,
+// and the assets they embed.
+package memfstest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+// AssertServes serves a GET request for path with h (usually a *memfs.FileSystem),
+// and reports an error if the response doesn't have wantStatus, wantEncoding (Content-Encoding) and wantBody.
+// If wantEncoding is "gzip", the request accepts gzip, and the body is decompressed before comparing.
+// An empty wantBody isn't compared.
+//
+// Usage:
+//
+//	memfstest.AssertServes(t, assets, "/", http.StatusOK, "gzip", "<h1>Hello, world!</h1>")
+func AssertServes(t testing.TB, h http.Handler, path string, wantStatus int, wantEncoding, wantBody string) {
+	t.Helper()
+
+	r := httptest.NewRequest("GET", path, nil)
+	if wantEncoding != "" {
+		r.Header.Set("Accept-Encoding", wantEncoding)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	if w.Code != wantStatus {
+		t.Errorf("%s: status: got %d, want %d", path, w.Code, wantStatus)
+	}
+	encoding := w.Header().Get("Content-Encoding")
+	if encoding != wantEncoding {
+		t.Errorf("%s: Content-Encoding: got %q, want %q", path, encoding, wantEncoding)
+	}
+	if wantBody == "" {
+		return
+	}
+
+	body := w.Body.Bytes()
+	if encoding == "gzip" {
+		var err error
+		if body, err = gunzip(body); err != nil {
+			t.Errorf("%s: %v", path, err)
+			return
+		}
+	}
+	if string(body) != wantBody {
+		t.Errorf("%s: body: got %q, want %q", path, body, wantBody)
+	}
+}
+
+// AssertMatchesDir reports an error for each file in fsys that's missing from directory dir,
+// each file in dir that's missing from fsys, and each file that has different contents.
+// This checks embedded (or generated) assets against their source directory.
+func AssertMatchesDir(t testing.TB, fsys fs.FS, dir string) {
+	t.Helper()
+
+	want, err := files(os.DirFS(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := files(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name := range got {
+		if _, ok := want[name]; !ok {
+			t.Errorf("%s: not in %s", name, dir)
+		}
+	}
+	for name, want := range want {
+		got, ok := got[name]
+		switch {
+		case !ok:
+			t.Errorf("%s: missing", name)
+		case !bytes.Equal(got, want):
+			t.Errorf("%s: contents differ from %s", name, dir)
+		}
+	}
+}
+
+// files reads all files in fsys.
+func files(fsys fs.FS) (map[string][]byte, error) {
+	files := map[string][]byte{}
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		files[name], err = fs.ReadFile(fsys, name)
+		return err
+	})
+	return files, err
+}
+
+func gunzip(data []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
@@ -0,0 +1,69 @@
+package memfstest_test
+
+import (
+	"compress/gzip"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ncruces/go-fs/memfs"
+	"github.com/ncruces/go-fs/memfs/memfstest"
+)
+
+func TestAssertServes(t *testing.T) {
+	html := strings.Repeat("<p>Hello, world!</p>", 100)
+	fsys, err := memfs.NewBuilder().
+		Add("index.html", html, memfs.WithCompression(gzip.BestCompression)).
+		Add("hi.txt", "Hello, world!").
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	memfstest.AssertServes(t, fsys, "/", http.StatusOK, "gzip", html)
+	memfstest.AssertServes(t, fsys, "/", http.StatusOK, "", html)
+	memfstest.AssertServes(t, fsys, "/hi.txt", http.StatusOK, "", "Hello, world!")
+	memfstest.AssertServes(t, fsys, "/missing", http.StatusNotFound, "", "")
+}
+
+func TestAssertMatchesDir(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "css"), 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "index.html"), []byte("<h1>Hello</h1>"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "css", "site.css"), []byte("h1{}"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	fsys, err := memfs.LoadCompressed(os.DirFS(dir), gzip.BestCompression)
+	if err != nil {
+		t.Fatal(err)
+	}
+	memfstest.AssertMatchesDir(t, fsys, dir)
+
+	fsys.Remove("css/site.css")
+	fsys.CreateString("index.html", "", time.Time{}, 0, 3, "<p>")
+	fsys.CreateString("extra.txt", "", time.Time{}, 0, 0, "")
+	rec := &recorder{T: t}
+	memfstest.AssertMatchesDir(rec, fsys, dir)
+	if len(rec.errors) != 3 {
+		t.Errorf("got %q", rec.errors)
+	}
+}
+
+// recorder records errors, instead of failing the test.
+type recorder struct {
+	*testing.T
+	errors []string
+}
+
+func (r *recorder) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
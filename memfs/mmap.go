@@ -0,0 +1,113 @@
+package memfs
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+)
+
+// Mmap moves the contents of files at least threshold bytes long (as stored in memory)
+// out of the Go heap, into memory mapped temporary files.
+// Applies to existing files, and files later created by Create and CreateCompressed.
+// Zero or negative stops moving newly created files.
+//
+// Mapped memory is never unmapped (open files and served content may still point into it):
+// the contents of files that are removed or overwritten stay mapped until the process exits.
+// Use it for file systems that are loaded once, and mostly left alone;
+// it fails for file systems that reload files as they change (returned by Watch).
+// Where memory mapping is unsupported, files stay in the heap.
+func (fsys *FileSystem) Mmap(threshold int) error {
+	if fsys.mtx != nil {
+		return errMmapWatch
+	}
+	if err := fsys.checkSealed(); err != nil {
+		return err
+	}
+	fsys.mmap = threshold
+	return fsys.mmapDir(&fsys.root, ".")
+}
+
+func (fsys *FileSystem) mmapDir(n *node, name string) error {
+	for i, kid := range n.kids {
+		p := kid.name()
+		if name != "." {
+			p = name + "/" + p
+		}
+		switch {
+		case kid.dir:
+			if err := fsys.mmapDir(kid, p); err != nil {
+				return err
+			}
+		case kid.mount == nil:
+			obj, err := mmapObject(kid.obj, fsys.mmap)
+			if err != nil {
+				return err
+			}
+			if obj.data != kid.obj.data {
+				// replace, open files may be sharing the node
+				kid = &node{obj: obj, sri: new(atomic.Pointer[string])}
+				n.set(i, true, kid)
+				fsys.files[p] = kid
+			}
+		}
+	}
+	return nil
+}
+
+// offHeap moves the contents of a new file off heap, if it's large enough.
+func (fsys *FileSystem) offHeap(obj object) (object, error) {
+	if fsys.mtx != nil {
+		fsys.mtx.RLock()
+		defer fsys.mtx.RUnlock()
+	}
+	return mmapObject(obj, fsys.mmap)
+}
+
+// mmapObject moves the contents of obj to a memory mapped temporary file,
+// if it's at least threshold bytes long and not already there.
+func mmapObject(obj object, threshold int) (object, error) {
+	if threshold <= 0 || len(obj.data) < threshold || isMapped(obj.data) {
+		return obj, nil
+	}
+
+	f, err := os.CreateTemp("", "memfs-*")
+	if err != nil {
+		return obj, err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	if _, err := f.WriteString(obj.data); err != nil {
+		return obj, err
+	}
+	data, err := mmap(f, len(obj.data))
+	if err != nil || data == nil {
+		return obj, err
+	}
+
+	mapped.Lock()
+	defer mapped.Unlock()
+	if mapped.ptrs == nil {
+		mapped.ptrs = map[*byte]struct{}{}
+	}
+	mapped.ptrs[&data[0]] = struct{}{}
+	obj.data = unsafe.String(&data[0], len(data))
+	return obj, nil
+}
+
+var errMmapWatch = errors.New("memfs: Mmap is not supported by file systems that reload files")
+
+// Memory mapped file contents, never unmapped.
+var mapped struct {
+	sync.Mutex
+	ptrs map[*byte]struct{}
+}
+
+func isMapped(data string) bool {
+	mapped.Lock()
+	defer mapped.Unlock()
+	_, ok := mapped.ptrs[unsafe.StringData(data)]
+	return ok
+}
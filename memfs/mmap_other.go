@@ -0,0 +1,9 @@
+//go:build !unix || tinygo
+
+package memfs
+
+import "os"
+
+func mmap(f *os.File, size int) ([]byte, error) {
+	return nil, nil
+}
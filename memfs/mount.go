@@ -0,0 +1,81 @@
+package memfs
+
+import "io/fs"
+
+// Mount attaches sub at prefix.
+// Names under prefix are resolved by sub, and prefix is listed as a directory in its parent.
+// If sub is a *FileSystem, its compressed files are served directly to accepting HTTP clients.
+//
+// Fails if prefix is invalid, or conflicts with an existing file, directory or mount point.
+func (fsys *FileSystem) Mount(prefix string, sub fs.FS) error {
+	if !fs.ValidPath(prefix) || prefix == "." {
+		return fs.ErrInvalid
+	}
+	return fsys.insert(prefix, &node{mount: sub}, false)
+}
+
+// object finds the object for name, following mount points into other *FileSystem instances.
+func (fsys *FileSystem) object(name string) (object, bool) {
+	n, rel, err := fsys.lookup(name)
+	switch {
+	case err != nil || n.dir:
+		return object{}, false
+	case n.mount != nil:
+		if m, ok := n.mount.(*FileSystem); ok {
+			return m.object(rel)
+		}
+		return object{}, false
+	}
+	return n.obj, true
+}
+
+func (n *node) openMount(rel string) (fs.File, error) {
+	f, err := n.mount.Open(rel)
+	if err != nil || rel != "." {
+		return f, err
+	}
+	return mountDir{f, n.name()}, nil
+}
+
+func (n *node) statMount(rel string) (entryInfo, error) {
+	if rel == "." {
+		return dirInfo(n.name()), nil
+	}
+	if m, ok := n.mount.(*FileSystem); ok {
+		return m.stat(rel)
+	}
+	info, err := fs.Stat(n.mount, rel)
+	if err != nil {
+		return nil, err
+	}
+	return infoEntry{info}, nil
+}
+
+// The root directory of a mounted file system, named after its mount point.
+type mountDir struct {
+	fs.File
+	name string
+}
+
+func (d mountDir) ReadDir(count int) ([]fs.DirEntry, error) {
+	if r, ok := d.File.(fs.ReadDirFile); ok {
+		return r.ReadDir(count)
+	}
+	return nil, fs.ErrInvalid
+}
+
+func (d mountDir) Stat() (fs.FileInfo, error) {
+	return dirInfo(d.name), nil
+}
+
+// Adapts a fs.FileInfo from a mounted file system to entryInfo.
+type infoEntry struct {
+	fs.FileInfo
+}
+
+func (i infoEntry) Type() fs.FileMode          { return i.Mode().Type() }
+func (i infoEntry) Info() (fs.FileInfo, error) { return i.FileInfo, nil }
+
+// Check interface implementations
+var _ fs.ReadDirFile = mountDir{}
+var _ entryInfo = infoEntry{}
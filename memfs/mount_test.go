@@ -0,0 +1,60 @@
+package memfs_test
+
+import (
+	"compress/gzip"
+	"io/fs"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/ncruces/go-fs/memfs"
+)
+
+func TestFileSystem_Mount(t *testing.T) {
+	docs := memfs.Create()
+	if err := docs.CreateCompressed("guide/index.html", "", time.Now(), strings.NewReader(strings.Repeat("<p>Hello, world!</p>", 100)), gzip.BestCompression); err != nil {
+		t.Fatal(err)
+	}
+
+	fsys := memfs.Create()
+	if err := fsys.Create("index.html", "", time.Now(), strings.NewReader("<p>Home</p>")); err != nil {
+		t.Fatal(err)
+	}
+	if err := fsys.Mount("docs", docs); err != nil {
+		t.Fatal(err)
+	}
+	if err := fsys.Mount("static/img", fstest.MapFS{"logo.svg": {Data: []byte("<svg/>")}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := fstest.TestFS(fsys, "index.html", "docs/guide/index.html", "static/img/logo.svg"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := fsys.Mount("docs", docs); err != fs.ErrExist {
+		t.Errorf("Mount(docs): got %v, want %v", err, fs.ErrExist)
+	}
+	if err := fsys.Mount("index.html/x", docs); err != fs.ErrExist {
+		t.Errorf("Mount(index.html/x): got %v, want %v", err, fs.ErrExist)
+	}
+	if err := fsys.Create("docs/new.txt", "", time.Now(), &strings.Reader{}); err != fs.ErrExist {
+		t.Errorf("Create(docs/new.txt): got %v, want %v", err, fs.ErrExist)
+	}
+
+	r := httptest.NewRequest("GET", "/docs/guide/", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	fsys.ServeHTTP(w, r)
+	if w.Code != 200 || w.Header().Get("Content-Encoding") != "gzip" {
+		t.Errorf("GET /docs/guide/: got %d, %q", w.Code, w.Header().Get("Content-Encoding"))
+	}
+
+	r = httptest.NewRequest("GET", "/static/img/logo.svg", nil)
+	w = httptest.NewRecorder()
+	fsys.ServeHTTP(w, r)
+	if w.Code != 200 || w.Body.String() != "<svg/>" {
+		t.Errorf("GET /static/img/logo.svg: got %d, %q", w.Code, w.Body.String())
+	}
+}
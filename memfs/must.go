@@ -0,0 +1,33 @@
+package memfs
+
+import "io/fs"
+
+// MustLoad is like Load, but panics on error.
+// It simplifies safe initialization of global variables.
+//
+// Usage:
+//
+//	//go:embed static
+//	var static embed.FS
+//
+//	var assets = memfs.MustLoad(memfs.MustSub(static, "static"))
+func MustLoad(in fs.FS, transforms ...Transform) *FileSystem {
+	return must(Load(in, transforms...))
+}
+
+// MustLoadCompressed is like LoadCompressed, but panics on error.
+func MustLoadCompressed(in fs.FS, level int, transforms ...Transform) *FileSystem {
+	return must(LoadCompressed(in, level, transforms...))
+}
+
+// MustSub is like fs.Sub, but panics on error.
+func MustSub(fsys fs.FS, dir string) fs.FS {
+	return must(fs.Sub(fsys, dir))
+}
+
+func must[T any](v T, err error) T {
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
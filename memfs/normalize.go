@@ -0,0 +1,113 @@
+package memfs
+
+import (
+	"errors"
+	"io/fs"
+)
+
+// NormalizeNames normalizes names to Unicode Normalization Form C (NFC).
+// Existing files are renamed, names of created files are normalized,
+// and names are normalized before they're looked up.
+// This way, files created on macOS (which uses NFD) can be found using NFC names,
+// as commonly found in URLs.
+//
+// Names passed to SetIndex, SetOrder, Tag and SetPage are also normalized.
+//
+// Fails with fs.ErrExist if existing names collide once normalized.
+// Not supported in small builds (see the package documentation).
+func (fsys *FileSystem) NormalizeNames() error {
+	if small {
+		return errors.New("memfs: NormalizeNames is not supported in small builds")
+	}
+	if fsys.mtx != nil {
+		fsys.mtx.Lock()
+		defer fsys.mtx.Unlock()
+	}
+	if err := fsys.checkSealed(); err != nil {
+		return err
+	}
+	if fsys.nfc {
+		return nil
+	}
+
+	root, files, folds := fsys.root, fsys.files, fsys.folds
+	fsys.root = node{obj: object{name: "."}, dir: true}
+	fsys.files = map[string]*node{}
+	if folds != nil {
+		fsys.folds = foldMap{}
+	}
+	fsys.nfc = true
+
+	var relink func(dir *node, name string) error
+	relink = func(dir *node, name string) error {
+		for _, kid := range dir.kids {
+			p := kid.name()
+			if name != "." {
+				p = name + "/" + p
+			}
+			if kid.dir {
+				if len(kid.kids) == 0 {
+					if err := fsys.mkdir(p); err != nil {
+						return err
+					}
+				}
+				if err := relink(kid, p); err != nil {
+					return err
+				}
+				continue
+			}
+			if fsys.root.conflicts(fsys.normal(p), false) {
+				return fs.ErrExist
+			}
+			n := *kid
+			if err := fsys.link(p, &n, false); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	err := relink(&root, ".")
+
+	// rekey everything else that's keyed by name
+	indexes, ok1 := rekey(fsys.indexes)
+	orders, ok2 := rekey(fsys.orders)
+	tags, ok3 := rekey(fsys.tags)
+	pages, ok4 := rekey(fsys.pages)
+	versions, ok5 := rekey(fsys.versions)
+	if err == nil && !(ok1 && ok2 && ok3 && ok4 && ok5) {
+		err = fs.ErrExist
+	}
+
+	if err != nil {
+		fsys.root, fsys.files, fsys.folds = root, files, folds
+		fsys.nfc = false
+		return err
+	}
+	fsys.indexes, fsys.orders, fsys.tags, fsys.pages, fsys.versions = indexes, orders, tags, pages, versions
+	return nil
+}
+
+// rekey returns a copy of m with its keys normalized,
+// or false if keys collide once normalized.
+func rekey[V any](m map[string]V) (map[string]V, bool) {
+	if m == nil {
+		return nil, true
+	}
+	r := make(map[string]V, len(m))
+	for k, v := range m {
+		k = nfc(k)
+		if _, ok := r[k]; ok {
+			return nil, false
+		}
+		r[k] = v
+	}
+	return r, true
+}
+
+// normal returns the normalized form of name.
+func (fsys *FileSystem) normal(name string) string {
+	if fsys.nfc {
+		return nfc(name)
+	}
+	return name
+}
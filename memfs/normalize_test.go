@@ -0,0 +1,124 @@
+//go:build !tinygo && !memfs_small
+
+package memfs_test
+
+import (
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/ncruces/go-fs/memfs"
+)
+
+func TestFileSystem_NormalizeNames(t *testing.T) {
+	const nfd = "cafe\u0301.txt"
+	const nfc = "caf\u00e9.txt"
+
+	fsys := memfs.Create()
+	fsys.CreateString("dir/"+nfd, "text/plain", time.Time{}, 0, 4, "cafe")
+	if err := fsys.NormalizeNames(); err != nil {
+		t.Fatal(err)
+	}
+	if err := fsys.Create(nfd, "", time.Now(), strings.NewReader("cafe")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{nfc, nfd, "dir/" + nfc, "dir/" + nfd} {
+		if data, err := fsys.ReadFile(name); err != nil || string(data) != "cafe" {
+			t.Errorf("ReadFile(%q): got %q, %v", name, data, err)
+		}
+	}
+	if list, err := fsys.ReadDir("dir"); err != nil || len(list) != 1 || list[0].Name() != nfc {
+		t.Errorf("got %v, %v", list, err)
+	}
+	if err := fstest.TestFS(fsys, nfc, "dir/"+nfc); err != nil {
+		t.Fatal(err)
+	}
+
+	collide := memfs.Create()
+	collide.CreateString(nfc, "", time.Time{}, 0, 0, "")
+	collide.CreateString(nfd, "", time.Time{}, 0, 0, "")
+	if err := collide.NormalizeNames(); err != fs.ErrExist {
+		t.Errorf("got %v, want %v", err, fs.ErrExist)
+	}
+	if _, err := collide.Stat(nfd); err != nil {
+		t.Error(err)
+	}
+}
+
+
+func TestFileSystem_NormalizeNames_emptyDirs(t *testing.T) {
+	fsys := memfs.Create()
+	fsys.CreateString("f.txt", "text/plain", time.Time{}, 0, 1, "f")
+	for _, name := range []string{"a/b/c", "e"} {
+		if err := fsys.CreateDir(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := fsys.NormalizeNames(); err != nil {
+		t.Fatal(err)
+	}
+	if got := fsys.Dirs(); fmt.Sprint(got) != "[a a/b a/b/c e]" {
+		t.Errorf("got %v", got)
+	}
+}
+
+func TestFileSystem_NormalizeNames_rekey(t *testing.T) {
+	const nfd = "café"
+	const nfc = "café"
+
+	fsys := memfs.Create()
+	fsys.SetHistory(1)
+	for _, name := range []string{"a.txt", "b.txt", "b.txt"} {
+		content := "old " + name
+		if _, err := fsys.Stat(nfd + "/" + name); err == nil {
+			content = name
+		}
+		if err := fsys.Create(nfd+"/"+name, "text/plain", time.Time{}, strings.NewReader(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fsys.SetIndex(nfd, "b.txt")
+	fsys.SetOrder(nfd, "b.txt", "a.txt")
+	if err := fsys.Tag(nfd+"/a.txt", "tag"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fsys.NormalizeNames(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := fsys.Tags(nfc + "/a.txt"); len(got) != 1 || got[0] != "tag" {
+		t.Errorf("Tags: got %q", got)
+	}
+	if list, err := fsys.ReadDir(nfc); err != nil || len(list) != 2 || list[0].Name() != "b.txt" {
+		t.Errorf("ReadDir: got %v, %v", list, err)
+	}
+	if f, err := fsys.OpenVersion(nfc+"/b.txt", 1); err != nil {
+		t.Errorf("OpenVersion: %v", err)
+	} else {
+		f.Close()
+	}
+	r := httptest.NewRequest("GET", "/"+nfc+"/", nil)
+	w := httptest.NewRecorder()
+	fsys.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || w.Body.String() != "b.txt" {
+		t.Errorf("ServeHTTP: got %d, %q", w.Code, w.Body)
+	}
+
+	collide := memfs.Create()
+	collide.CreateString(nfd+"/a.txt", "", time.Time{}, 0, 0, "")
+	collide.CreateString(nfc+"/b.txt", "", time.Time{}, 0, 0, "")
+	collide.SetIndex(nfd, "a.txt")
+	collide.SetIndex(nfc, "b.txt")
+	if err := collide.NormalizeNames(); err != fs.ErrExist {
+		t.Errorf("got %v, want %v", err, fs.ErrExist)
+	}
+	if _, err := collide.Stat(nfd + "/a.txt"); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,136 @@
+package memfs
+
+import (
+	"compress/gzip"
+	"io"
+	"io/fs"
+	"net/http"
+	"time"
+)
+
+// FileOption configures a file created by CreateFile, or added to a Builder.
+type FileOption func(*fileOptions)
+
+type fileOptions struct {
+	mime    string
+	modtime time.Time
+	level   int
+	hash    uint32
+	header  http.Header
+	keepRaw bool
+}
+
+// WithMIME sets the MIME type of the file.
+// By default, it's sniffed.
+func WithMIME(mimetype string) FileOption {
+	return func(o *fileOptions) { o.mime = mimetype }
+}
+
+// WithModTime sets the modification time of the file.
+func WithModTime(modtime time.Time) FileOption {
+	return func(o *fileOptions) { o.modtime = modtime }
+}
+
+// WithCompression sets the compression level of the file.
+// The default is gzip.NoCompression.
+func WithCompression(level int) FileOption {
+	return func(o *fileOptions) { o.level = level }
+}
+
+// WithUncompressed keeps the uncompressed content of a compressed file in memory,
+// along with the compressed content, trading memory for not decompressing
+// on every request from clients that don't accept gzip, or request ranges.
+// Use it for the hottest files.
+func WithUncompressed() FileOption {
+	return func(o *fileOptions) { o.keepRaw = true }
+}
+
+// WithHash sets the hash of the file, used for its ETag.
+// By default, it's the CRC-32 of the content.
+func WithHash(hash uint32) FileOption {
+	return func(o *fileOptions) { o.hash = hash }
+}
+
+// WithHeaders adds headers to HTTP responses serving the file.
+func WithHeaders(header http.Header) FileOption {
+	return func(o *fileOptions) {
+		if o.header == nil {
+			o.header = http.Header{}
+		}
+		for k, v := range header {
+			for _, v := range v {
+				o.header.Add(k, v)
+			}
+		}
+	}
+}
+
+// SetHeaders sets headers added to HTTP responses serving a file,
+// replacing any previously set (with SetHeaders or WithHeaders).
+func (fsys *FileSystem) SetHeaders(name string, header http.Header) error {
+	if fsys.mtx != nil {
+		fsys.mtx.Lock()
+		defer fsys.mtx.Unlock()
+	}
+	if err := fsys.checkSealed(); err != nil {
+		return err
+	}
+	if !fs.ValidPath(name) {
+		return fs.ErrInvalid
+	}
+	n, ok := fsys.files[fsys.normal(name)]
+	if !ok {
+		return fs.ErrNotExist
+	}
+
+	var o fileOptions
+	WithHeaders(header)(&o)
+
+	// copy, nodes in the tree are immutable
+	file := *n
+	file.obj.head = o.header
+	return fsys.link(name, &file, false)
+}
+
+// CreateFile creates a file, configured by opts.
+// Overwrites an existing file (but not a directory).
+// By default, sniffs the MIME type, and doesn't compress content.
+func (fsys *FileSystem) CreateFile(name string, r io.Reader, opts ...FileOption) error {
+	o := fileOptions{level: gzip.NoCompression}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if !fs.ValidPath(name) {
+		return fs.ErrInvalid
+	}
+	if err := fsys.canCreate(name); err != nil {
+		return err
+	}
+
+	obj, err := fsys.create(name, r, &o)
+	if err != nil {
+		return err
+	}
+	return fsys.put(name, obj, false)
+}
+
+// create creates the object for file name, configured by o.
+func (fsys *FileSystem) create(name string, r io.Reader, o *fileOptions) (object, error) {
+	data, err := fsys.read(name, r)
+	if err != nil {
+		return object{}, err
+	}
+	obj, err := compress(name, o.mime, o.modtime, data, o.level)
+	if err != nil {
+		return object{}, err
+	}
+	if o.hash != 0 {
+		obj.hash = o.hash
+	}
+	obj.head = o.header
+	if o.keepRaw && len(obj.data) != obj.size {
+		obj.raw = string(data)
+	}
+	return fsys.offHeap(obj)
+}
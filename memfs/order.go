@@ -0,0 +1,49 @@
+package memfs
+
+import "sort"
+
+// SetOrder sets the order of the entries of a directory, as listed by ReadDir and WalkDir
+// (e.g. chapters in logical, rather than lexical, order).
+// The named entries are listed first, in the given order, followed by other entries sorted by name.
+// Names that don't exist are ignored.
+//
+// Usage:
+//
+//	docs.SetOrder("guide", "introduction.html", "install.html", "reference")
+func (fsys *FileSystem) SetOrder(dir string, names ...string) error {
+	if err := fsys.checkSealed(); err != nil {
+		return err
+	}
+	if fsys.orders == nil {
+		fsys.orders = map[string][]string{}
+	}
+	fsys.orders[fsys.normal(dir)] = names
+	return nil
+}
+
+// order returns the entries of a directory, in the order set by SetOrder.
+func (fsys *FileSystem) order(dir string, kids []*node) []*node {
+	if len(fsys.orders) == 0 {
+		return kids
+	}
+	names, ok := fsys.orders[fsys.normal(dir)]
+	if !ok {
+		return kids
+	}
+
+	list := make([]*node, 0, len(kids))
+	listed := make([]bool, len(kids))
+	for _, name := range names {
+		i := sort.Search(len(kids), func(i int) bool { return kids[i].name() >= name })
+		if i < len(kids) && kids[i].name() == name && !listed[i] {
+			list = append(list, kids[i])
+			listed[i] = true
+		}
+	}
+	for i, kid := range kids {
+		if !listed[i] {
+			list = append(list, kid)
+		}
+	}
+	return list
+}
@@ -0,0 +1,207 @@
+package memfs
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"sort"
+)
+
+// Overlay combines several file systems into a single read-only fs.FS.
+//
+// Layers are searched in order: files in earlier layers shadow those in later ones.
+// Directories are merged: listing a directory returns the union of its entries in all layers.
+// Layers that are *FileSystem instances are accessed directly, bypassing fs.FS.
+//
+// Usage:
+//
+//	// files on disk override embedded assets
+//	fsys := memfs.Overlay(os.DirFS("static"), assets)
+func Overlay(layers ...fs.FS) fs.FS {
+	return overlay(layers)
+}
+
+type overlay []fs.FS
+
+// Open implements fs.FS.
+func (o overlay) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, fs.ErrInvalid
+	}
+	for i, fsys := range o {
+		info, err := layerStat(fsys, name)
+		if err != nil {
+			if isNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		if !info.IsDir() {
+			return fsys.Open(name)
+		}
+		list, err := o[i:].readDir(name)
+		if err != nil {
+			return nil, err
+		}
+		return &listDir{info: info, list: list}, nil
+	}
+	return nil, fs.ErrNotExist
+}
+
+// ReadFile implements fs.ReadFileFS.
+func (o overlay) ReadFile(name string) ([]byte, error) {
+	if !fs.ValidPath(name) {
+		return nil, fs.ErrInvalid
+	}
+	for _, fsys := range o {
+		info, err := layerStat(fsys, name)
+		if err != nil {
+			if isNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		if info.IsDir() {
+			return nil, fs.ErrInvalid
+		}
+		return fs.ReadFile(fsys, name)
+	}
+	return nil, fs.ErrNotExist
+}
+
+// Stat implements fs.StatFS.
+func (o overlay) Stat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, fs.ErrInvalid
+	}
+	for _, fsys := range o {
+		info, err := layerStat(fsys, name)
+		if err != nil {
+			if isNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		return info, nil
+	}
+	return nil, fs.ErrNotExist
+}
+
+// ReadDir implements fs.ReadDirFS.
+func (o overlay) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, fs.ErrInvalid
+	}
+	for i, fsys := range o {
+		info, err := layerStat(fsys, name)
+		if err != nil {
+			if isNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		if !info.IsDir() {
+			return nil, fs.ErrInvalid
+		}
+		return o[i:].readDir(name)
+	}
+	return nil, fs.ErrNotExist
+}
+
+// readDir merges the entries of directory name in all layers,
+// skipping layers where name is not a directory.
+func (o overlay) readDir(name string) ([]fs.DirEntry, error) {
+	var list []fs.DirEntry
+	seen := map[string]struct{}{}
+	for _, fsys := range o {
+		entries, err := layerReadDir(fsys, name)
+		if err != nil {
+			if isNotExist(err) || err == fs.ErrInvalid {
+				continue
+			}
+			return nil, err
+		}
+		for _, e := range entries {
+			if _, ok := seen[e.Name()]; !ok {
+				seen[e.Name()] = struct{}{}
+				list = append(list, e)
+			}
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name() < list[j].Name()
+	})
+	return list, nil
+}
+
+func layerStat(fsys fs.FS, name string) (fs.FileInfo, error) {
+	if m, ok := fsys.(*FileSystem); ok {
+		s, err := m.stat(name)
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	}
+	return fs.Stat(fsys, name)
+}
+
+func layerReadDir(fsys fs.FS, name string) ([]fs.DirEntry, error) {
+	if m, ok := fsys.(*FileSystem); ok {
+		return m.ReadDir(name)
+	}
+	if info, err := fs.Stat(fsys, name); err != nil {
+		return nil, err
+	} else if !info.IsDir() {
+		return nil, fs.ErrInvalid
+	}
+	return fs.ReadDir(fsys, name)
+}
+
+func isNotExist(err error) bool {
+	return errors.Is(err, fs.ErrNotExist)
+}
+
+type listDir struct {
+	info fs.FileInfo
+	pos  int
+	list []fs.DirEntry
+}
+
+func (d *listDir) Close() error {
+	d.pos = -1
+	return nil
+}
+
+func (d *listDir) Read(p []byte) (n int, err error) {
+	return 0, fs.ErrInvalid
+}
+
+func (d *listDir) ReadDir(count int) ([]fs.DirEntry, error) {
+	if d.pos < 0 {
+		return nil, fs.ErrClosed
+	}
+
+	if count <= 0 {
+		count = len(d.list) - d.pos
+	} else if d.pos >= len(d.list) {
+		return nil, io.EOF
+	}
+
+	end := d.pos + count
+	if end > len(d.list) {
+		end = len(d.list)
+	}
+	ret := d.list[d.pos:end:end]
+	d.pos = end
+	return ret, nil
+}
+
+func (d *listDir) Stat() (fs.FileInfo, error) {
+	return d.info, nil
+}
+
+// Check interface implementations
+var _ fs.ReadFileFS = overlay{}
+var _ fs.ReadDirFS = overlay{}
+var _ fs.StatFS = overlay{}
+var _ fs.ReadDirFile = &listDir{}
@@ -0,0 +1,53 @@
+package memfs_test
+
+import (
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/ncruces/go-fs/memfs"
+)
+
+func TestOverlay(t *testing.T) {
+	lower := memfs.Create()
+	for _, name := range []string{"a.txt", "b.txt", "dir/c.txt", "dir/d.txt", "file"} {
+		if err := lower.Create(name, "", time.Now(), strings.NewReader("lower")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	upper := fstest.MapFS{
+		"b.txt":       {Data: []byte("upper")},
+		"dir/e.txt":   {Data: []byte("upper")},
+		"file/f.txt":  {Data: []byte("upper")},
+		"other/g.txt": {Data: []byte("upper")},
+	}
+
+	fsys := memfs.Overlay(upper, lower)
+
+	if err := fstest.TestFS(fsys, "a.txt", "b.txt", "dir/c.txt", "dir/d.txt", "dir/e.txt", "file/f.txt", "other/g.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, want := range map[string]string{"a.txt": "lower", "b.txt": "upper", "dir/c.txt": "lower", "dir/e.txt": "upper"} {
+		if data, err := fs.ReadFile(fsys, name); err != nil {
+			t.Error(err)
+		} else if string(data) != want {
+			t.Errorf("%s: got %q, want %q", name, data, want)
+		}
+	}
+
+	entries, err := fs.ReadDir(fsys, "dir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if got := strings.Join(names, ","); got != "c.txt,d.txt,e.txt" {
+		t.Errorf("ReadDir(dir): got %s", got)
+	}
+}
@@ -0,0 +1,47 @@
+package memfs
+
+import (
+	"bytes"
+	"compress/gzip"
+	"hash/crc32"
+	"io/fs"
+	"strings"
+	"unsafe"
+)
+
+// gzipSiblings maps the names of files with a .gz sibling to the sibling.
+func gzipSiblings(names []string) map[string]string {
+	files := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		files[name] = struct{}{}
+	}
+	siblings := map[string]string{}
+	for _, name := range names {
+		if base, ok := strings.CutSuffix(name, ".gz"); ok {
+			if _, ok := files[base]; ok {
+				siblings[base] = name
+			}
+		}
+	}
+	return siblings
+}
+
+// precompressed returns the uncompressed obj with the content of its gzip sibling gz, if it matches,
+// or else compressed with the specified compression level.
+func precompressed(in fs.FS, name, gz string, obj object, level int) (object, error) {
+	data, err := fs.ReadFile(in, gz)
+	if err != nil {
+		return object{}, err
+	}
+
+	// the gzip trailer has the CRC-32 and size of the content
+	content := unsafe.Slice(unsafe.StringData(obj.data), len(obj.data))
+	if hash := getHash(data, int64(obj.size)); hash != 0 && hash == crc32.ChecksumIEEE(content) {
+		if _, err := gzip.NewReader(bytes.NewReader(data)); err == nil {
+			obj.data = toString(data)
+			obj.hash = hash
+			return obj, nil
+		}
+	}
+	return compress(name, obj.mime, obj.time, content, level)
+}
@@ -0,0 +1,25 @@
+package memfs
+
+import "io/fs"
+
+// Seal freezes the file system, making the intent to "build once, serve forever" explicit.
+// Afterwards, methods that modify files, or configure the file system, fail with fs.ErrPermission.
+//
+// A sealed file system is safe for concurrent use by multiple goroutines,
+// without locking (unless it was created by Watch or NewCache).
+func (fsys *FileSystem) Seal() {
+	if fsys.mtx != nil {
+		fsys.mtx.Lock()
+		defer fsys.mtx.Unlock()
+	}
+	fsys.sealed = true
+}
+
+// checkSealed fails if the file system is sealed.
+// Call with the lock held.
+func (fsys *FileSystem) checkSealed() error {
+	if fsys.sealed {
+		return fs.ErrPermission
+	}
+	return nil
+}
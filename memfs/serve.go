@@ -0,0 +1,336 @@
+package memfs
+
+import (
+	"io"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// serve writes the object as the response.
+// Same as http.ServeContent, but skips content sniffing and seeking,
+// and slices single ranges of stored content directly.
+func (o object) serve(w http.ResponseWriter, r *http.Request, etag string) {
+	raw := o.setHeaders(w, r, etag)
+	if !raw && o.raw != "" {
+		o.data, raw = o.raw, true
+	}
+	header := w.Header()
+	header.Set("Accept-Ranges", "bytes")
+	if !isZeroTime(o.time) {
+		header.Set("Last-Modified", o.time.UTC().Format(http.TimeFormat))
+	}
+	if checkPreconditions(w, r, o.time) {
+		return
+	}
+
+	size := o.size
+	if raw {
+		size = len(o.data)
+	}
+
+	if rng := r.Header.Get("Range"); rng != "" && checkIfRange(w, r, o.time) != condFalse {
+		start, length, ok := parseRange(rng, size)
+		if !ok || !raw {
+			// let net/http handle multiple, unsatisfiable, or compressed ranges
+			data := o.data
+			if !raw {
+				var err error
+				if data, err = o.inflate(); err != nil {
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+					return
+				}
+			}
+			http.ServeContent(w, r, o.name, o.time, strings.NewReader(data))
+			return
+		}
+		header.Set("Content-Range", "bytes "+strconv.Itoa(start)+"-"+strconv.Itoa(start+length-1)+"/"+strconv.Itoa(size))
+		header.Set("Content-Length", strconv.Itoa(length))
+		w.WriteHeader(http.StatusPartialContent)
+		if r.Method != "HEAD" {
+			io.WriteString(w, o.data[start:start+length])
+		}
+		return
+	}
+
+	if !raw && r.Method != "HEAD" {
+		// inflate before writing the header, so errors can be reported
+		data, err := o.inflate()
+		if err == nil && len(data) != size {
+			err = errSize
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		o.data = data
+	}
+	header.Set("Content-Length", strconv.Itoa(size))
+	w.WriteHeader(http.StatusOK)
+	if r.Method != "HEAD" {
+		io.WriteString(w, o.data)
+	}
+}
+
+// localRedirect is the same as in net/http.
+func localRedirect(w http.ResponseWriter, r *http.Request, newPath string) {
+	if q := r.URL.RawQuery; q != "" {
+		newPath += "?" + q
+	}
+	w.Header().Set("Location", newPath)
+	w.WriteHeader(http.StatusMovedPermanently)
+}
+
+// parseRange parses a Range header with a single satisfiable range.
+func parseRange(s string, size int) (start, length int, ok bool) {
+	const b = "bytes="
+	if !strings.HasPrefix(s, b) {
+		return 0, 0, false
+	}
+	s = textproto.TrimString(s[len(b):])
+	if strings.Contains(s, ",") {
+		return 0, 0, false
+	}
+	first, last, ok := strings.Cut(s, "-")
+	if !ok {
+		return 0, 0, false
+	}
+	first = textproto.TrimString(first)
+	last = textproto.TrimString(last)
+	if first == "" {
+		// suffix range: the last N bytes
+		n, err := strconv.ParseUint(last, 10, 63)
+		if err != nil || n == 0 || size == 0 {
+			return 0, 0, false
+		}
+		if n > uint64(size) {
+			n = uint64(size)
+		}
+		return size - int(n), int(n), true
+	}
+	i, err := strconv.ParseUint(first, 10, 63)
+	if err != nil || i >= uint64(size) {
+		return 0, 0, false
+	}
+	start = int(i)
+	if last == "" {
+		return start, size - start, true
+	}
+	j, err := strconv.ParseUint(last, 10, 63)
+	if err != nil || j < i {
+		return 0, 0, false
+	}
+	if j >= uint64(size) {
+		j = uint64(size) - 1
+	}
+	return start, int(j) - start + 1, true
+}
+
+// Conditional request handling, as in net/http.
+
+type condResult int
+
+const (
+	condNone condResult = iota
+	condTrue
+	condFalse
+)
+
+// checkPreconditions evaluates request preconditions,
+// and reports whether a response was written.
+func checkPreconditions(w http.ResponseWriter, r *http.Request, modtime time.Time) (done bool) {
+	ch := checkIfMatch(w, r)
+	if ch == condNone {
+		ch = checkIfUnmodifiedSince(r, modtime)
+	}
+	if ch == condFalse {
+		w.WriteHeader(http.StatusPreconditionFailed)
+		return true
+	}
+	switch checkIfNoneMatch(w, r) {
+	case condFalse:
+		if r.Method == "GET" || r.Method == "HEAD" {
+			writeNotModified(w)
+		} else {
+			w.WriteHeader(http.StatusPreconditionFailed)
+		}
+		return true
+	case condNone:
+		if checkIfModifiedSince(r, modtime) == condFalse {
+			writeNotModified(w)
+			return true
+		}
+	}
+	return false
+}
+
+func checkIfMatch(w http.ResponseWriter, r *http.Request) condResult {
+	im := r.Header.Get("If-Match")
+	if im == "" {
+		return condNone
+	}
+	for {
+		im = textproto.TrimString(im)
+		if len(im) == 0 {
+			break
+		}
+		if im[0] == ',' {
+			im = im[1:]
+			continue
+		}
+		if im[0] == '*' {
+			return condTrue
+		}
+		etag, remain := scanETag(im)
+		if etag == "" {
+			break
+		}
+		if etagStrongMatch(etag, w.Header().Get("Etag")) {
+			return condTrue
+		}
+		im = remain
+	}
+	return condFalse
+}
+
+func checkIfUnmodifiedSince(r *http.Request, modtime time.Time) condResult {
+	ius := r.Header.Get("If-Unmodified-Since")
+	if ius == "" || isZeroTime(modtime) {
+		return condNone
+	}
+	t, err := http.ParseTime(ius)
+	if err != nil {
+		return condNone
+	}
+	if ret := modtime.Truncate(time.Second).Compare(t); ret <= 0 {
+		return condTrue
+	}
+	return condFalse
+}
+
+func checkIfNoneMatch(w http.ResponseWriter, r *http.Request) condResult {
+	inm := r.Header.Get("If-None-Match")
+	if inm == "" {
+		return condNone
+	}
+	buf := inm
+	for {
+		buf = textproto.TrimString(buf)
+		if len(buf) == 0 {
+			break
+		}
+		if buf[0] == ',' {
+			buf = buf[1:]
+			continue
+		}
+		if buf[0] == '*' {
+			return condFalse
+		}
+		etag, remain := scanETag(buf)
+		if etag == "" {
+			break
+		}
+		if etagWeakMatch(etag, w.Header().Get("Etag")) {
+			return condFalse
+		}
+		buf = remain
+	}
+	return condTrue
+}
+
+func checkIfModifiedSince(r *http.Request, modtime time.Time) condResult {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		return condNone
+	}
+	ims := r.Header.Get("If-Modified-Since")
+	if ims == "" || isZeroTime(modtime) {
+		return condNone
+	}
+	t, err := http.ParseTime(ims)
+	if err != nil {
+		return condNone
+	}
+	if ret := modtime.Truncate(time.Second).Compare(t); ret <= 0 {
+		return condFalse
+	}
+	return condTrue
+}
+
+func checkIfRange(w http.ResponseWriter, r *http.Request, modtime time.Time) condResult {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		return condNone
+	}
+	ir := r.Header.Get("If-Range")
+	if ir == "" {
+		return condNone
+	}
+	etag, _ := scanETag(ir)
+	if etag != "" {
+		if etagStrongMatch(etag, w.Header().Get("Etag")) {
+			return condTrue
+		}
+		return condFalse
+	}
+	if isZeroTime(modtime) {
+		return condFalse
+	}
+	t, err := http.ParseTime(ir)
+	if err != nil {
+		return condFalse
+	}
+	if t.Unix() == modtime.Unix() {
+		return condTrue
+	}
+	return condFalse
+}
+
+func writeNotModified(w http.ResponseWriter) {
+	h := w.Header()
+	delete(h, "Content-Type")
+	delete(h, "Content-Length")
+	delete(h, "Content-Encoding")
+	if h.Get("Etag") != "" {
+		delete(h, "Last-Modified")
+	}
+	w.WriteHeader(http.StatusNotModified)
+}
+
+// scanETag determines if a syntactically valid ETag is present at s.
+// If so, the ETag and remaining text after consuming ETag is returned.
+func scanETag(s string) (etag string, remain string) {
+	s = textproto.TrimString(s)
+	start := 0
+	if strings.HasPrefix(s, "W/") {
+		start = 2
+	}
+	if len(s[start:]) < 2 || s[start] != '"' {
+		return "", ""
+	}
+	for i := start + 1; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == 0x21 || c >= 0x23 && c <= 0x7E || c >= 0x80:
+		case c == '"':
+			return s[:i+1], s[i+1:]
+		default:
+			return "", ""
+		}
+	}
+	return "", ""
+}
+
+func etagStrongMatch(a, b string) bool {
+	return a == b && a != "" && a[0] == '"'
+}
+
+func etagWeakMatch(a, b string) bool {
+	return strings.TrimPrefix(a, "W/") == strings.TrimPrefix(b, "W/")
+}
+
+var unixEpochTime = time.Unix(0, 0)
+
+func isZeroTime(t time.Time) bool {
+	return t.IsZero() || t.Equal(unixEpochTime)
+}
@@ -0,0 +1,31 @@
+//go:build tinygo || memfs_small
+
+package memfs
+
+import "strings"
+
+// Set by the tinygo and memfs_small build tags.
+const small = true
+
+// Names aren't normalized: NormalizeNames fails.
+func nfc(name string) string {
+	return name
+}
+
+// A trivial token scan, instead of golang.org/x/net/http/httpguts.
+func acceptsGzip(header []string) bool {
+	for _, v := range header {
+		for _, token := range strings.Split(v, ",") {
+			token, _, _ = strings.Cut(token, ";")
+			if strings.EqualFold(strings.TrimSpace(token), "gzip") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// MIME types are only typed by extension.
+func sniff(data []byte) string {
+	return ""
+}
@@ -0,0 +1,84 @@
+package memfs
+
+import (
+	"path"
+	"unsafe"
+)
+
+// Stats describes the files in a FileSystem.
+type Stats struct {
+	Files  int   // number of files
+	Size   int64 // uncompressed size of files
+	Stored int64 // size of files, as stored in memory (shared contents count once, uncompressed copies count)
+
+	// Breakdown of files by extension (including the dot, empty for no extension).
+	// Stats in the breakdown have no further breakdown.
+	ByExt map[string]Stats
+}
+
+// SysInfo describes how a file is stored in memory.
+// It's returned by the Sys method of the fs.FileInfo of files
+// (except those read as stored, with HTTPRaw).
+type SysInfo struct {
+	Stored   int64  // size of the file, as stored in memory
+	Encoding string // "gzip" if the file is stored compressed, empty otherwise
+	Hash     uint32 // hash of the content, used for the ETag (zero if none)
+	MIME     string // MIME type
+}
+
+func (o object) sys() *SysInfo {
+	info := &SysInfo{Stored: int64(len(o.data)), Hash: o.hash, MIME: o.mime}
+	if len(o.data) != o.size {
+		info.Encoding = "gzip"
+	}
+	return info
+}
+
+// Ratio returns the compression ratio: uncompressed size over stored size.
+func (s Stats) Ratio() float64 {
+	if s.Stored == 0 {
+		return 1
+	}
+	return float64(s.Size) / float64(s.Stored)
+}
+
+func (s *Stats) add(o object, shared bool) {
+	s.Files++
+	s.Size += int64(o.size)
+	if !shared {
+		s.Stored += int64(len(o.data))
+	}
+	s.Stored += int64(len(o.raw))
+}
+
+// Stats returns statistics about the files in the file system.
+// Files in mounted file systems are not included.
+func (fsys *FileSystem) Stats() Stats {
+	if fsys.mtx != nil {
+		fsys.mtx.RLock()
+		defer fsys.mtx.RUnlock()
+	}
+	stats := Stats{ByExt: map[string]Stats{}}
+	seen := map[*byte]struct{}{}
+	fsys.root.walk(".", func(name string, f *node) {
+		o := f.obj
+		if f.lazy != nil {
+			var ok bool
+			if o, ok = f.lazy.produced(); !ok {
+				return
+			}
+			o.name = f.obj.name
+		}
+		p := unsafe.StringData(o.data)
+		_, shared := seen[p]
+		if p != nil {
+			seen[p] = struct{}{}
+		}
+		ext := path.Ext(o.name)
+		s := stats.ByExt[ext]
+		s.add(o, shared)
+		stats.ByExt[ext] = s
+		stats.add(o, shared)
+	})
+	return stats
+}
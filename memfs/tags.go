@@ -0,0 +1,122 @@
+package memfs
+
+import (
+	"io/fs"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Tag adds tags to the named file (e.g. "critical", or "public"),
+// to query with Tagged and HasTag, e.g. to export only some files:
+//
+//	public := assets.Filter(func(name string, info fs.FileInfo) bool {
+//		return info.IsDir() || assets.HasTag(name, "public")
+//	})
+func (fsys *FileSystem) Tag(name string, tags ...string) error {
+	if fsys.mtx != nil {
+		fsys.mtx.Lock()
+		defer fsys.mtx.Unlock()
+	}
+	if err := fsys.checkSealed(); err != nil {
+		return err
+	}
+	if !fs.ValidPath(name) {
+		return fs.ErrInvalid
+	}
+	name = fsys.normal(name)
+	if _, ok := fsys.files[name]; !ok {
+		return fs.ErrNotExist
+	}
+
+	if fsys.tags == nil {
+		fsys.tags = map[string][]string{}
+	}
+	list := fsys.tags[name]
+	for _, tag := range tags {
+		i := sort.SearchStrings(list, tag)
+		if i == len(list) || list[i] != tag {
+			list = append(list[:i], append([]string{tag}, list[i:]...)...)
+		}
+	}
+	fsys.tags[name] = list
+	return nil
+}
+
+// Tags returns the tags of the named file, sorted.
+func (fsys *FileSystem) Tags(name string) []string {
+	if fsys.mtx != nil {
+		fsys.mtx.RLock()
+		defer fsys.mtx.RUnlock()
+	}
+	return append([]string(nil), fsys.tags[fsys.normal(name)]...)
+}
+
+// HasTag reports whether the named file has a tag.
+func (fsys *FileSystem) HasTag(name, tag string) bool {
+	if fsys.mtx != nil {
+		fsys.mtx.RLock()
+		defer fsys.mtx.RUnlock()
+	}
+	list := fsys.tags[fsys.normal(name)]
+	i := sort.SearchStrings(list, tag)
+	return i < len(list) && list[i] == tag
+}
+
+// Tagged returns the names of the files with a tag, sorted.
+func (fsys *FileSystem) Tagged(tag string) []string {
+	if fsys.mtx != nil {
+		fsys.mtx.RLock()
+		defer fsys.mtx.RUnlock()
+	}
+	var names []string
+	for name, list := range fsys.tags {
+		if _, ok := fsys.files[name]; !ok {
+			continue // removed
+		}
+		if i := sort.SearchStrings(list, tag); i < len(list) && list[i] == tag {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SetPreload sets a tag (e.g. "critical") for files preloaded by HTML pages:
+// HTML responses from ServeHTTP, ServeFile and ServeContent get a Link header
+// to preload each file with the tag (by its absolute path, from the root of the file system).
+// Empty disables preloading.
+func (fsys *FileSystem) SetPreload(tag string) error {
+	if err := fsys.checkSealed(); err != nil {
+		return err
+	}
+	fsys.preload = tag
+	return nil
+}
+
+// preloadLinks adds Link headers to preload files tagged by SetPreload.
+func (fsys *FileSystem) preloadLinks(w http.ResponseWriter, o object) {
+	if fsys.preload == "" || !strings.HasPrefix(o.mime, "text/html") {
+		return
+	}
+	for _, name := range fsys.Tagged(fsys.preload) {
+		o, ok := fsys.object(name)
+		if !ok {
+			continue
+		}
+		link := "</" + name + ">; rel=preload; as="
+		switch mime := o.mime; {
+		case strings.HasPrefix(mime, "text/css"):
+			link += "style"
+		case strings.Contains(mime, "javascript"):
+			link += "script"
+		case strings.HasPrefix(mime, "font/"):
+			link += "font; crossorigin"
+		case strings.HasPrefix(mime, "image/"):
+			link += "image"
+		default:
+			link += "fetch; crossorigin"
+		}
+		w.Header().Add("Link", link)
+	}
+}
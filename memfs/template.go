@@ -0,0 +1,185 @@
+package memfs
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"net/http"
+	"path"
+	texttemplate "text/template"
+	"time"
+)
+
+// ParseHTMLTemplates parses the files matching patterns (as in fs.Glob) into a set of templates.
+// Unlike html/template.ParseFS, templates are named by their path,
+// so files with the same base name in different directories don't clash.
+//
+// Usage:
+//
+//	base, err := memfs.ParseHTMLTemplates(assets, "layouts/*.html", "partials/*.html")
+func ParseHTMLTemplates(fsys fs.FS, patterns ...string) (*htmltemplate.Template, error) {
+	return parseTemplates(htmltemplate.New(""), fsys, patterns)
+}
+
+// ParseTextTemplates is like ParseHTMLTemplates, for text/template.
+func ParseTextTemplates(fsys fs.FS, patterns ...string) (*texttemplate.Template, error) {
+	return parseTemplates(texttemplate.New(""), fsys, patterns)
+}
+
+// ParseHTMLPages composes pages with a base set of templates (layouts and partials).
+// Each file matching patterns is parsed into its own clone of base,
+// so pages can redefine the blocks of a layout without clashing.
+// Returns the pages by path.
+//
+// Usage:
+//
+//	pages, err := memfs.ParseHTMLPages(base, assets, "pages/*.html")
+//	...
+//	err = pages["pages/home.html"].ExecuteTemplate(w, "layouts/main.html", data)
+func ParseHTMLPages(base *htmltemplate.Template, fsys fs.FS, patterns ...string) (map[string]*htmltemplate.Template, error) {
+	return parsePages(base, fsys, patterns)
+}
+
+// ParseTextPages is like ParseHTMLPages, for text/template.
+func ParseTextPages(base *texttemplate.Template, fsys fs.FS, patterns ...string) (map[string]*texttemplate.Template, error) {
+	return parsePages(base, fsys, patterns)
+}
+
+// Implemented by both *html/template.Template and *text/template.Template.
+type template[T any] interface {
+	New(name string) T
+	Parse(text string) (T, error)
+	Clone() (T, error)
+}
+
+func parseTemplates[T template[T]](t T, fsys fs.FS, patterns []string) (T, error) {
+	names, err := globTemplates(fsys, patterns)
+	if err != nil {
+		return t, err
+	}
+	for _, name := range names {
+		if err := parseTemplate(t, fsys, name); err != nil {
+			return t, err
+		}
+	}
+	return t, nil
+}
+
+func parsePages[T template[T]](base T, fsys fs.FS, patterns []string) (map[string]T, error) {
+	names, err := globTemplates(fsys, patterns)
+	if err != nil {
+		return nil, err
+	}
+	pages := make(map[string]T, len(names))
+	for _, name := range names {
+		t, err := base.Clone()
+		if err != nil {
+			return nil, err
+		}
+		if err := parseTemplate(t, fsys, name); err != nil {
+			return nil, err
+		}
+		pages[name] = t
+	}
+	return pages, nil
+}
+
+func parseTemplate[T template[T]](t T, fsys fs.FS, name string) error {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return err
+	}
+	_, err = t.New(name).Parse(string(data))
+	return err
+}
+
+// globTemplates returns the files matching patterns, without duplicates.
+func globTemplates(fsys fs.FS, patterns []string) ([]string, error) {
+	var names []string
+	seen := map[string]struct{}{}
+	for _, pattern := range patterns {
+		matches, err := fs.Glob(fsys, pattern)
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("memfs: pattern matches no files: %#q", pattern)
+		}
+		for _, name := range matches {
+			if _, ok := seen[name]; !ok {
+				seen[name] = struct{}{}
+				names = append(names, name)
+			}
+		}
+	}
+	return names, nil
+}
+
+// SetPage serves name, with ServeHTTP, ServeFile and ServeContent,
+// by executing t with the data returned for each request,
+// so lightly dynamic pages can be served along with static files.
+// Pages are served with an ETag derived from the rendered content,
+// so clients can revalidate them.
+// If data is nil, the page is rendered once, on first access, as with CreateFunc.
+//
+// An empty file is created at name, so the page can be a directory index.
+//
+// Usage:
+//
+//	pages, err := memfs.ParseHTMLPages(base, assets, "pages/*.html")
+//	...
+//	assets.SetPage("status.html", pages["pages/status.html"], func(r *http.Request) (any, error) {
+//		return status.Current(), nil
+//	})
+func (fsys *FileSystem) SetPage(name string, t *htmltemplate.Template, data func(r *http.Request) (any, error)) error {
+	const mime = "text/html; charset=utf-8"
+	if err := fsys.checkSealed(); err != nil {
+		return err
+	}
+	if data == nil {
+		return fsys.CreateFunc(name, mime, func() ([]byte, error) {
+			var buf bytes.Buffer
+			err := t.Execute(&buf, nil)
+			return buf.Bytes(), err
+		})
+	}
+	if !fs.ValidPath(name) {
+		return fs.ErrInvalid
+	}
+	if err := fsys.CreateFile(name, bytes.NewReader(nil), WithMIME(mime)); err != nil {
+		return err
+	}
+	if fsys.pages == nil {
+		fsys.pages = map[string]*templatePage{}
+	}
+	fsys.pages[fsys.normal(name)] = &templatePage{t, data}
+	return nil
+}
+
+// A page rendered from a template, for each request.
+type templatePage struct {
+	t    *htmltemplate.Template
+	data func(r *http.Request) (any, error)
+}
+
+func (p *templatePage) serve(fsys *FileSystem, w http.ResponseWriter, r *http.Request, name string) {
+	data, err := p.data(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var buf bytes.Buffer
+	if err := p.t.Execute(&buf, data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	o := newObject(name, "text/html; charset=utf-8", time.Time{}, buf.Bytes())
+	o.name = path.Base(name)
+	etag := fsys.etag(name, o)
+	o = fsys.caching(r, name, o, etag)
+	fsys.preloadLinks(w, o)
+	served(w, r, name, o)
+	o.serve(w, r, etag)
+}
@@ -0,0 +1,98 @@
+package memfs_test
+
+import (
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ncruces/go-fs/memfs"
+)
+
+func TestParseHTMLPages(t *testing.T) {
+	fsys, err := memfs.NewBuilder().
+		Add("layouts/main.html", `<title>{{block "title" .}}Site{{end}}</title>{{template "partials/nav.html"}}{{block "content" .}}{{end}}`).
+		Add("partials/nav.html", `<nav>nav</nav>`).
+		Add("pages/home.html", `{{define "content"}}<p>{{.}}</p>{{end}}`).
+		Add("pages/about.html", `{{define "title"}}About{{end}}{{define "content"}}about{{end}}`).
+		Add("blog/nav.html", `<nav>blog</nav>`).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	base, err := memfs.ParseHTMLTemplates(fsys, "layouts/*.html", "partials/*.html", "blog/*.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if base.Lookup("partials/nav.html") == nil || base.Lookup("blog/nav.html") == nil {
+		t.Errorf("got %s", base.DefinedTemplates())
+	}
+
+	pages, err := memfs.ParseHTMLPages(base, fsys, "pages/*.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]string{
+		"pages/home.html":  `<title>Site</title><nav>nav</nav><p>&lt;hi&gt;</p>`,
+		"pages/about.html": `<title>About</title><nav>nav</nav>about`,
+	}
+	for name, want := range tests {
+		var buf strings.Builder
+		if err := pages[name].ExecuteTemplate(&buf, "layouts/main.html", "<hi>"); err != nil {
+			t.Fatal(err)
+		}
+		if got := buf.String(); got != want {
+			t.Errorf("%s: got %q, want %q", name, got, want)
+		}
+	}
+
+	if _, err := memfs.ParseTextTemplates(fsys, "missing/*.txt"); err == nil {
+		t.Error("want error")
+	}
+}
+
+func TestFileSystem_SetPage(t *testing.T) {
+	fsys := memfs.Create()
+	page := template.Must(template.New("").Parse(`<p>Hello, {{.}}!</p>`))
+
+	if err := fsys.SetPage("index.html", page, func(r *http.Request) (any, error) {
+		return r.URL.Query().Get("name"), nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := fsys.SetPage("static.html", page, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(url, etag string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", url, nil)
+		if etag != "" {
+			r.Header.Set("If-None-Match", etag)
+		}
+		w := httptest.NewRecorder()
+		fsys.ServeHTTP(w, r)
+		return w
+	}
+
+	w := get("/?name=<world>", "")
+	if w.Code != 200 || w.Body.String() != "<p>Hello, &lt;world&gt;!</p>" {
+		t.Errorf("got %d, %q", w.Code, w.Body.String())
+	}
+	etag := w.Header().Get("ETag")
+	if etag == "" || w.Header().Get("Content-Type") != "text/html; charset=utf-8" {
+		t.Errorf("got %v", w.Header())
+	}
+	if w := get("/?name=<world>", etag); w.Code != http.StatusNotModified {
+		t.Errorf("got %d", w.Code)
+	}
+	if w := get("/?name=you", etag); w.Code != 200 || w.Body.String() != "<p>Hello, you!</p>" {
+		t.Errorf("got %d, %q", w.Code, w.Body.String())
+	}
+
+	if w := get("/static.html", ""); w.Code != 200 || w.Body.String() != "<p>Hello, !</p>" {
+		t.Errorf("got %d, %q", w.Code, w.Body.String())
+	}
+}
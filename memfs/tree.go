@@ -0,0 +1,182 @@
+package memfs
+
+import (
+	"io/fs"
+	"strings"
+	"sync/atomic"
+)
+
+// A node of the tree of files in a FileSystem:
+// a file, a directory, or a mount point.
+//
+// Only the kids of a directory are modified after a node is linked into the tree,
+// and only copy-on-write, so open directories can keep sharing them.
+type node struct {
+	obj   object                  // file contents; obj.name is the name of every kind of node
+	kids  []*node                 // directory entries, sorted by name
+	mount fs.FS                   // mounted file system
+	lazy  *lazyFile               // produces file contents, on first access
+	sri   *atomic.Pointer[string] // Subresource Integrity checksum, on first use
+	dir   bool
+}
+
+func (n *node) name() string {
+	return n.obj.name
+}
+
+// entry returns n as an entryInfo.
+// Points into n, which avoids allocating for nodes in the tree,
+// but not for copies.
+func (n *node) entry() entryInfo {
+	if n.dir || n.mount != nil {
+		return (*dirInfo)(&n.obj.name)
+	}
+	return &n.obj
+}
+
+// find finds the node for name, starting at n, ignoring case if folds isn't nil.
+// If name is under a mount point, returns the mount point and the name relative to it.
+func (n *node) find(name string, folds foldMap) (*node, string) {
+	// invalid names have "", "." or ".." elements, which never match a node
+	if name == "." {
+		return n, "."
+	}
+	for {
+		elem, rest, more := strings.Cut(name, "/")
+		if n = folds.child(n, elem); n == nil {
+			return nil, ""
+		}
+		if !more {
+			return n, "."
+		}
+		if n.mount != nil {
+			if rest == "." || !fs.ValidPath(rest) {
+				return nil, ""
+			}
+			return n, rest
+		}
+		name = rest
+	}
+}
+
+// child finds the named entry of a directory.
+func (n *node) child(name string) *node {
+	if i, found := n.search(name, false); found {
+		return n.kids[i]
+	}
+	return nil
+}
+
+// search finds the position of name in a directory.
+// If ordered, names are expected in fs.WalkDir order, which makes appending cheap.
+func (n *node) search(name string, ordered bool) (int, bool) {
+	kids := n.kids
+	i, j := 0, len(kids)
+	if ordered && j > 0 && kids[j-1].name() < name {
+		return j, false
+	}
+	for i < j {
+		h := int(uint(i+j) >> 1)
+		if kids[h].name() < name {
+			i = h + 1
+		} else {
+			j = h
+		}
+	}
+	return i, i < len(kids) && kids[i].name() == name
+}
+
+// set links kid into a directory at position i, replacing the existing entry if found.
+func (n *node) set(i int, found bool, kid *node) {
+	d := n.kids
+	if i == len(d) {
+		n.kids = append(d, kid)
+		return
+	}
+	// copy, open directories may be sharing the slice
+	if found {
+		d = append([]*node(nil), d...)
+	} else {
+		d = append(append(append(make([]*node, 0, len(d)+1), d[:i]...), nil), d[i:]...)
+	}
+	d[i] = kid
+	n.kids = d
+}
+
+// unset removes the named entry of a directory.
+func (n *node) unset(name string) {
+	if i, found := n.search(name, false); found {
+		d := n.kids
+		// copy, open directories may be sharing the slice
+		n.kids = append(append(make([]*node, 0, len(d)-1), d[:i]...), d[i+1:]...)
+	}
+}
+
+// conflicts reports if adding a file at name would conflict with a directory or mount point,
+// or, unless replace is true, with an existing file.
+func (n *node) conflicts(name string, replace bool) bool {
+	if name == "." {
+		return true
+	}
+	for {
+		elem, rest, more := strings.Cut(name, "/")
+		switch n = n.child(elem); {
+		case n == nil:
+			return false
+		case !more:
+			return n.dir || n.mount != nil || !replace
+		case !n.dir:
+			return true
+		}
+		name = rest
+	}
+}
+
+// dirConflicts reports if creating a directory at name would conflict with a file or mount point.
+func (n *node) dirConflicts(name string) bool {
+	for {
+		elem, rest, more := strings.Cut(name, "/")
+		switch n = n.child(elem); {
+		case n == nil:
+			return false
+		case !n.dir:
+			return true
+		case !more:
+			return false
+		}
+		name = rest
+	}
+}
+
+// walk calls fn for every file under n, in fs.WalkDir order.
+func (n *node) walk(name string, fn func(name string, f *node)) {
+	for _, kid := range n.kids {
+		p := kid.name()
+		if name != "." {
+			p = name + "/" + p
+		}
+		if kid.dir {
+			kid.walk(p, fn)
+		} else if kid.mount == nil {
+			fn(p, kid)
+		}
+	}
+}
+
+// emptyDirs calls fn for every empty directory under n.
+func (n *node) emptyDirs(name string, fn func(name string)) {
+	for _, kid := range n.kids {
+		if !kid.dir {
+			continue
+		}
+		p := kid.name()
+		if name != "." {
+			p = name + "/" + p
+		}
+		if len(kid.kids) == 0 {
+			fn(p)
+		} else {
+			kid.emptyDirs(p, fn)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package memfs
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// SetVersionParam sets the query parameter of cache-busting URLs, like "/app.js?v=1a2b3c".
+// Files are looked up ignoring the query, but if the parameter matches the version of the file,
+// ServeHTTP, ServeFile and ServeContent respond with long lived, immutable, caching.
+// An empty param disables it.
+//
+// Usage:
+//
+//	assets.SetVersionParam("v")
+//	…
+//	<script src="/app.js?v={{version "app.js"}}"></script>
+func (fsys *FileSystem) SetVersionParam(param string) error {
+	if err := fsys.checkSealed(); err != nil {
+		return err
+	}
+	fsys.versionParam = param
+	return nil
+}
+
+// Version returns the version of the named file, for cache-busting URLs:
+// the tag of its ETag, which changes with its content.
+func (fsys *FileSystem) Version(name string) (string, bool) {
+	o, ok := fsys.object(name)
+	if !ok {
+		return "", false
+	}
+	etag := fsys.etag(name, o)
+	return etag, etag != ""
+}
+
+// SetBuildID sets the build ID of the file system, and the time it was built,
+// e.g. a hash of its content, as generated by memfsgen.
+//
+// Usage:
+//
+//	http.HandleFunc("/version", func(w http.ResponseWriter, r *http.Request) {
+//		fmt.Fprintln(w, assets.BuildID(), assets.BuildTime())
+//	})
+func (fsys *FileSystem) SetBuildID(id string, built time.Time) error {
+	if err := fsys.checkSealed(); err != nil {
+		return err
+	}
+	fsys.buildID = id
+	fsys.buildTime = built
+	return nil
+}
+
+// BuildID returns the build ID of the file system, for cache-busting URLs, or version endpoints.
+// It's empty unless set by SetBuildID.
+func (fsys *FileSystem) BuildID() string {
+	return fsys.buildID
+}
+
+// BuildTime returns the time the file system was built, as set by SetBuildID.
+func (fsys *FileSystem) BuildTime() time.Time {
+	return fsys.buildTime
+}
+
+// SetImmutable sets a function that reports if a file name is fingerprinted,
+// e.g. app.3f2a9c1b.js, from a regular expression, or a build manifest.
+// ServeHTTP, ServeFile and ServeContent respond to requests for fingerprinted files
+// with long lived, immutable, caching, and to requests for HTML files
+// (which link to them) with caching that always revalidates.
+// Headers set with SetHeaders or WithHeaders take precedence.
+// Nil disables it.
+//
+// Usage:
+//
+//	assets.SetImmutable(regexp.MustCompile(`[.-][0-9a-f]{8,}\.\w+$`).MatchString)
+func (fsys *FileSystem) SetImmutable(fingerprinted func(name string) bool) error {
+	if err := fsys.checkSealed(); err != nil {
+		return err
+	}
+	fsys.immutable = fingerprinted
+	return nil
+}
+
+// caching returns o, with the Cache-Control for name:
+// immutable if the request is for its version (etag), or name is fingerprinted;
+// no-cache for HTML, if fingerprinting is enabled.
+func (fsys *FileSystem) caching(r *http.Request, name string, o object, etag string) object {
+	var cache string
+	switch {
+	case fsys.versionParam != "" && etag != "" && r.URL.Query().Get(fsys.versionParam) == etag:
+		cache = "public, max-age=31536000, immutable"
+	case fsys.immutable == nil || o.head.Get("Cache-Control") != "":
+		return o
+	case fsys.immutable(name):
+		cache = "public, max-age=31536000, immutable"
+	case strings.HasPrefix(o.mime, "text/html"):
+		cache = "no-cache"
+	default:
+		return o
+	}
+	head := o.head.Clone()
+	if head == nil {
+		head = http.Header{}
+	}
+	head.Set("Cache-Control", cache)
+	o.head = head
+	return o
+}
+
+// SetLastModified sets the precision of the Last-Modified header of files served
+// by ServeHTTP, ServeFile and ServeContent, so responses don't leak build timestamps,
+// and stay the same across builds with identical content.
+// Zero (the default) is exact, a positive precision rounds modification times down,
+// and a negative precision omits the header (and If-Modified-Since is ignored).
+func (fsys *FileSystem) SetLastModified(precision time.Duration) error {
+	if err := fsys.checkSealed(); err != nil {
+		return err
+	}
+	fsys.lastModified = precision
+	return nil
+}
+
+// modTime returns the modification time to serve o with.
+func (fsys *FileSystem) modTime(o object) object {
+	switch {
+	case fsys.lastModified < 0:
+		o.time = time.Time{}
+	case fsys.lastModified > 0:
+		o.time = o.time.Truncate(fsys.lastModified)
+	}
+	return o
+}
@@ -0,0 +1,125 @@
+package memfs
+
+import (
+	"io/fs"
+	"path"
+	"sort"
+)
+
+// WalkDir walks the file tree rooted at root, like fs.WalkDir,
+// but iterates the tree directly, instead of opening and reading each directory.
+func (fsys *FileSystem) WalkDir(root string, fn fs.WalkDirFunc) error {
+	n, rel, ok := fsys.find(root)
+	if ok && rel != "." {
+		// root is in a mounted file system
+		return fs.WalkDir(fsys, root, fn)
+	}
+
+	var err error
+	if ok {
+		err = fsys.walkDir(root, &n, fn)
+	} else {
+		_, err = fsys.Stat(root)
+		err = fn(root, nil, err)
+	}
+	if err == fs.SkipDir || err == fs.SkipAll {
+		return nil
+	}
+	return err
+}
+
+func (fsys *FileSystem) walkDir(name string, n *node, fn fs.WalkDirFunc) error {
+	d := n.entry()
+	if err := fn(name, d, nil); err != nil || !d.IsDir() {
+		if err == fs.SkipDir && d.IsDir() {
+			err = nil
+		}
+		return err
+	}
+
+	if n.mount != nil {
+		return walkMount(name, d, n.mount, fn)
+	}
+
+	for _, kid := range fsys.order(name, fsys.kids(n)) {
+		p := kid.name()
+		if name != "." {
+			p = name + "/" + p
+		}
+		if err := fsys.walkDir(p, kid, fn); err != nil {
+			if err == fs.SkipDir {
+				break
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// walkMount walks a mounted file system, with names relative to its mount point.
+func walkMount(name string, d fs.DirEntry, sub fs.FS, fn fs.WalkDirFunc) error {
+	var skipAll bool
+	err := fs.WalkDir(sub, ".", func(p string, d1 fs.DirEntry, err error) error {
+		if p == "." {
+			if err == nil {
+				return nil // already visited
+			}
+			d1 = d
+		}
+		err = fn(path.Join(name, p), d1, err)
+		skipAll = err == fs.SkipAll
+		return err
+	})
+	if skipAll {
+		return fs.SkipAll
+	}
+	return err
+}
+
+// kids returns the entries of a directory.
+func (fsys *FileSystem) kids(n *node) []*node {
+	if fsys.mtx != nil {
+		fsys.mtx.RLock()
+		defer fsys.mtx.RUnlock()
+	}
+	return n.kids
+}
+
+// Files returns the names of all files, sorted.
+// Files in mounted file systems are not included.
+// Useful to build sitemaps, or lists of assets to precache.
+func (fsys *FileSystem) Files() []string {
+	if fsys.mtx != nil {
+		fsys.mtx.RLock()
+		defer fsys.mtx.RUnlock()
+	}
+	names := make([]string, 0, len(fsys.files))
+	for name := range fsys.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Dirs returns the names of all directories (other than the root), sorted.
+// Mount points, and directories in mounted file systems, are not included.
+func (fsys *FileSystem) Dirs() []string {
+	if fsys.mtx != nil {
+		fsys.mtx.RLock()
+		defer fsys.mtx.RUnlock()
+	}
+	var names []string
+	var walk func(name string, n *node)
+	walk = func(name string, n *node) {
+		for _, kid := range n.kids {
+			if kid.dir {
+				p := path.Join(name, kid.name())
+				names = append(names, p)
+				walk(p, kid)
+			}
+		}
+	}
+	walk(".", &fsys.root)
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,98 @@
+package memfs_test
+
+import (
+	"errors"
+	"io/fs"
+	"reflect"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/ncruces/go-fs/memfs"
+)
+
+func TestFileSystem_WalkDir(t *testing.T) {
+	fsys := memfs.Create()
+	for _, name := range []string{"a.txt", "b/c.txt", "b/d/e.txt", "b/f.txt", "g/h.txt", "i.txt"} {
+		fsys.CreateString(name, "text/plain", time.Time{}, 0, 0, "")
+	}
+	if err := fsys.Mount("m", fstest.MapFS{"x/y.txt": {}, "z.txt": {}}); err != nil {
+		t.Fatal(err)
+	}
+
+	walk := func(walk func(string, fs.WalkDirFunc) error, root string, skip string) (names []string) {
+		err := walk(root, func(name string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			names = append(names, name+":"+d.Name())
+			if name == skip {
+				if d.IsDir() {
+					return fs.SkipDir
+				}
+				return fs.SkipAll
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return names
+	}
+	generic := func(root string, fn fs.WalkDirFunc) error {
+		return fs.WalkDir(fsys, root, fn)
+	}
+
+	for _, tt := range []struct{ root, skip string }{
+		{".", ""}, {"b", ""}, {"m", ""}, {"m/x", ""}, {"a.txt", ""},
+		{".", "b"}, {".", "b/d/e.txt"}, {".", "m/x"}, {".", "m/x/y.txt"},
+	} {
+		want := walk(generic, tt.root, tt.skip)
+		got := walk(fsys.WalkDir, tt.root, tt.skip)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("WalkDir(%q) skipping %q: got %v, want %v", tt.root, tt.skip, got, want)
+		}
+	}
+
+	if err := fsys.WalkDir("missing", func(name string, d fs.DirEntry, err error) error { return err }); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("got %v, want %v", err, fs.ErrNotExist)
+	}
+}
+
+func BenchmarkFileSystem_WalkDir(b *testing.B) {
+	fsys := memfs.Create()
+	for _, name := range benchmarkNames() {
+		fsys.CreateString(name, "text/plain", time.Time{}, 0, 0, "")
+	}
+	fn := func(name string, d fs.DirEntry, err error) error { return err }
+
+	b.Run("fs", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			fs.WalkDir(fsys, ".", fn)
+		}
+	})
+	b.Run("memfs", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			fsys.WalkDir(".", fn)
+		}
+	})
+}
+
+func TestFileSystem_Files(t *testing.T) {
+	fsys := memfs.Create()
+	for _, name := range []string{"b/f.txt", "a.txt", "b/d/e.txt", "b.txt", "g/h.txt"} {
+		fsys.CreateString(name, "text/plain", time.Time{}, 0, 0, "")
+	}
+	if err := fsys.Mount("m", fstest.MapFS{"x/y.txt": {}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := fsys.Files(), []string{"a.txt", "b.txt", "b/d/e.txt", "b/f.txt", "g/h.txt"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Files: got %v, want %v", got, want)
+	}
+	if got, want := fsys.Dirs(), []string{"b", "b/d", "g"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Dirs: got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,163 @@
+//go:build !tinygo && !memfs_small
+
+package memfs
+
+import (
+	"compress/gzip"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// WatchOption configures Watch.
+type WatchOption func(*watcher)
+
+// WatchCompressed sets the compression level used for loaded files.
+// The default is gzip.NoCompression.
+func WatchCompressed(level int) WatchOption {
+	return func(w *watcher) { w.level = level }
+}
+
+// WatchErrors sets a function that receives errors watching and reloading files.
+// By default, errors are ignored.
+func WatchErrors(fn func(error)) WatchOption {
+	return func(w *watcher) { w.errors = fn }
+}
+
+// Watch loads the contents of a directory into a new FileSystem instance,
+// and keeps it updated as files are created, changed and removed.
+// This is intended for development, so the same code serves assets without restarts.
+//
+// The returned FileSystem is safe for reads concurrent with updates.
+// Closing the io.Closer stops watching.
+func Watch(dir string, opts ...WatchOption) (*FileSystem, io.Closer, error) {
+	notify, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	w := &watcher{
+		root:   dir,
+		fsys:   Create(),
+		notify: notify,
+		level:  gzip.NoCompression,
+		done:   make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+
+	// start watching before loading, so no changes are missed
+	if err := w.load(dir); err != nil {
+		notify.Close()
+		return nil, nil, err
+	}
+	w.fsys.mtx = new(sync.RWMutex)
+
+	go w.run()
+	return w.fsys, w, nil
+}
+
+type watcher struct {
+	root   string
+	fsys   *FileSystem
+	notify *fsnotify.Watcher
+	level  int
+	errors func(error)
+	done   chan struct{}
+}
+
+func (w *watcher) Close() error {
+	err := w.notify.Close()
+	<-w.done
+	return err
+}
+
+func (w *watcher) run() {
+	defer close(w.done)
+	for {
+		select {
+		case event, ok := <-w.notify.Events:
+			if !ok {
+				return
+			}
+			w.handle(event)
+		case err, ok := <-w.notify.Errors:
+			if !ok {
+				return
+			}
+			w.error(err)
+		}
+	}
+}
+
+func (w *watcher) handle(event fsnotify.Event) {
+	name, err := w.name(event.Name)
+	if err != nil {
+		w.error(err)
+		return
+	}
+	switch {
+	case event.Has(fsnotify.Remove), event.Has(fsnotify.Rename):
+		w.fsys.removeAll(name)
+	case event.Has(fsnotify.Create), event.Has(fsnotify.Write):
+		if err := w.load(event.Name); err != nil && !os.IsNotExist(err) {
+			w.error(err)
+		}
+	}
+}
+
+// load watches and loads a file, or a directory and everything it contains.
+func (w *watcher) load(root string) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return w.notify.Add(path)
+		}
+		return w.create(path)
+	})
+}
+
+func (w *watcher) create(path string) error {
+	name, err := w.name(path)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return nil
+	}
+	// a file may have replaced a directory
+	if s, err := w.fsys.stat(name); err == nil && s.IsDir() {
+		w.fsys.removeAll(name)
+	}
+	return w.fsys.CreateCompressed(name, "", info.ModTime(), f, w.level)
+}
+
+func (w *watcher) name(path string) (string, error) {
+	name, err := filepath.Rel(w.root, path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.ToSlash(name), nil
+}
+
+func (w *watcher) error(err error) {
+	if w.errors != nil {
+		w.errors(err)
+	}
+}
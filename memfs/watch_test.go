@@ -0,0 +1,72 @@
+//go:build !tinygo && !memfs_small
+
+package memfs_test
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ncruces/go-fs/memfs"
+)
+
+func TestWatch(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "hi.txt"), []byte("Hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fsys, closer, err := memfs.Watch(dir, memfs.WatchErrors(func(err error) { t.Error(err) }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closer.Close()
+
+	if err := fsys.Mmap(1); err == nil {
+		t.Error("Mmap: want error")
+	}
+
+	eventually := func(name, want string) {
+		t.Helper()
+		var got string
+		for i := 0; i < 100; i++ {
+			data, err := fsys.ReadFile(name)
+			if err == nil {
+				got = string(data)
+			} else {
+				got = err.Error()
+			}
+			if got == want {
+				return
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		t.Errorf("%s: got %q, want %q", name, got, want)
+	}
+
+	eventually("hi.txt", "Hello")
+
+	if err := os.WriteFile(filepath.Join(dir, "hi.txt"), []byte("Hello, world!"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	eventually("hi.txt", "Hello, world!")
+
+	if err := os.MkdirAll(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "sub", "new.txt"), []byte("New"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	eventually("sub/new.txt", "New")
+
+	if err := os.RemoveAll(filepath.Join(dir, "sub")); err != nil {
+		t.Fatal(err)
+	}
+	eventually("sub/new.txt", "readfile sub/new.txt: file does not exist")
+	if _, err := fsys.Stat("sub"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Stat(sub): got %v, want %v", err, fs.ErrNotExist)
+	}
+}
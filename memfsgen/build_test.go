@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"math/rand"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// TestGenerate_build generates a package for each set of options,
+// then builds and runs a program that reads back every file.
+func TestGenerate_build(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping build in short mode")
+	}
+	gocmd := filepath.Join(runtime.GOROOT(), "bin", "go")
+	if _, err := os.Stat(gocmd); err != nil {
+		t.Skip(err)
+	}
+	root, err := filepath.Abs("..")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string][]byte{
+		"index.html":   []byte(strings.Repeat("<p>Hello, world!</p>\n", 100)),
+		"style.css":    []byte("p { color: red; }\n"),
+		"img/logo.png": random(3000),
+		"secret.txt":   []byte("secret\n"),
+		".gitignore":   []byte("secret.txt\n"),
+	}
+	public := []string{"img/logo.png", "index.html", "style.css"}
+
+	tests := []struct {
+		pkg      string
+		opts     Target
+		big      bool     // add a file larger than a chunk
+		want     []string // files read back, defaults to public
+		minified bool     // content of web assets differs
+		contains string   // in the generated file
+	}{
+		{pkg: "plain"},
+		{pkg: "etags", opts: Target{ETags: true}, contains: "var AssetsETags"},
+		{pkg: "accessors", opts: Target{Accessors: true}, contains: "func IndexHTML()"},
+		{pkg: "embed", opts: Target{Embed: true}, contains: "//go:embed all:static", want: []string{".gitignore", "img/logo.png", "index.html", "secret.txt", "style.css"}},
+		{pkg: "report", opts: Target{report: &bytes.Buffer{}}},
+		{pkg: "varperdir", opts: Target{VarPerDir: true}, contains: "var AssetsImg"},
+		{pkg: "noignore", opts: Target{NoIgnore: true}, want: []string{".gitignore", "img/logo.png", "index.html", "secret.txt", "style.css"}},
+		{pkg: "update", opts: Target{Update: true}},
+		{pkg: "base64", opts: Target{Encoding: "base64"}, contains: "AssetsBase64("},
+		{pkg: "raw", opts: Target{Encoding: "raw"}, contains: "`p { color: red; }"},
+		{pkg: "readable", opts: Target{Readable: 512}, contains: "`p { color: red; }"},
+		{pkg: "chunk", opts: Target{Chunk: 1, Width: 1 << 16}, big: true, contains: "_1 := ", want: append([]string{"big.bin"}, public...)},
+		{pkg: "comments", opts: Target{Comments: true, TOC: true}, contains: "// Assets contains"},
+		{pkg: "minify", opts: Target{Minify: true}, minified: true},
+		{pkg: "zopfli", opts: Target{Zopfli: true}},
+		{pkg: "emptydirs", opts: Target{EmptyDirs: true}, contains: `CreateDir("empty")`},
+		{pkg: "provenance", opts: Target{command: "-pkg provenance -var Assets static assets.go"}, contains: "-pkg provenance -var Assets static assets.go"},
+	}
+
+	mod := t.TempDir()
+	var imports, vars strings.Builder
+	want := map[string]string{}
+	for _, tt := range tests {
+		dir := filepath.Join(mod, tt.pkg)
+		source := filepath.Join(dir, "static")
+		for name, data := range files {
+			writeFile(t, filepath.Join(source, filepath.FromSlash(name)), data)
+		}
+		if err := os.MkdirAll(filepath.Join(source, "empty"), 0777); err != nil {
+			t.Fatal(err)
+		}
+		content := map[string][]byte{}
+		if tt.big {
+			content["big.bin"] = random(1<<20 + 1)
+			writeFile(t, filepath.Join(source, "big.bin"), content["big.bin"])
+		}
+
+		target := tt.opts
+		target.Source = source
+		target.Target = filepath.Join(dir, "assets.go")
+		target.Package = tt.pkg
+		target.Variable = "Assets"
+		if err := target.generate(); err != nil {
+			t.Fatalf("%s: %v", tt.pkg, err)
+		}
+		if target.Update {
+			// regenerate, keeping the previous build
+			if err := target.generate(); err != nil {
+				t.Fatalf("%s: %v", tt.pkg, err)
+			}
+		}
+		if buf, ok := target.report.(*bytes.Buffer); ok && buf.Len() == 0 {
+			t.Errorf("%s: empty report", tt.pkg)
+		}
+		if tt.contains != "" {
+			data, err := os.ReadFile(target.Target)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(data), tt.contains) {
+				t.Errorf("%s: missing %s", tt.pkg, tt.contains)
+			}
+		}
+
+		names := tt.want
+		if names == nil {
+			names = public
+		}
+		for _, name := range names {
+			data, ok := content[name]
+			if !ok {
+				data = files[name]
+			}
+			sum := fmt.Sprintf("%x", sha256.Sum256(data))
+			if tt.minified && !strings.HasSuffix(name, ".png") {
+				sum = "minified"
+			}
+			want[tt.pkg+" "+name] = sum
+		}
+		fmt.Fprintf(&imports, "\t%q\n", "test/"+tt.pkg)
+		fmt.Fprintf(&vars, "\t\t%q: %s.Assets,\n", tt.pkg, tt.pkg)
+	}
+
+	writeFile(t, filepath.Join(mod, "go.mod"), []byte(fmt.Sprintf(
+		"module test\n\ngo 1.20\n\nrequire github.com/ncruces/go-fs v0.0.0\n\nreplace github.com/ncruces/go-fs => %s\n", root)))
+	sum, err := os.ReadFile(filepath.Join(root, "go.sum"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(mod, "go.sum"), sum)
+	writeFile(t, filepath.Join(mod, "main.go"), []byte(`package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/fs"
+
+`+imports.String()+`)
+
+func main() {
+	for pkg, fsys := range map[string]fs.FS{
+`+vars.String()+`	} {
+		err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			data, err := fs.ReadFile(fsys, name)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("%s %s %x\n", pkg, name, sha256.Sum256(data))
+			return nil
+		})
+		if err != nil {
+			panic(err)
+		}
+	}
+}
+`))
+
+	cmd := exec.Command(gocmd, "run", ".")
+	cmd.Dir = mod
+	cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod", "GOPROXY=off", "GOWORK=off")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("%v\n%s", err, out)
+	}
+
+	got := map[string]string{}
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		i := strings.LastIndexByte(line, ' ')
+		got[line[:i]] = line[i+1:]
+	}
+	var keys []string
+	for key := range want {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		switch sum, ok := got[key]; {
+		case !ok:
+			t.Errorf("%s: missing", key)
+		case want[key] != "minified" && sum != want[key]:
+			t.Errorf("%s: content differs", key)
+		}
+		delete(got, key)
+	}
+	for key := range got {
+		t.Errorf("%s: unexpected", key)
+	}
+}
+
+func random(n int) []byte {
+	data := make([]byte, n)
+	rand.New(rand.NewSource(int64(n))).Read(data)
+	return data
+}
+
+func writeFile(t *testing.T, name string, data []byte) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(name), 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(name, data, 0666); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Target describes a file to generate, from a source directory.
+type Target struct {
+	Source    string            `json:"source"`              // source directory
+	Target    string            `json:"target"`              // target file
+	Tag       string            `json:"tag,omitempty"`       // build constraint
+	Package   string            `json:"pkg,omitempty"`       // package name
+	Variable  string            `json:"var,omitempty"`       // variable name
+	Minify    bool              `json:"minify,omitempty"`    // minify web assets
+	ETags     bool              `json:"etags,omitempty"`     // generate a map of ETags
+	Accessors bool              `json:"accessors,omitempty"` // generate typed accessor functions
+	Embed     bool              `json:"embed,omitempty"`     // wrap an embed.FS, instead of generating content
+	VarPerDir bool              `json:"varperdir,omitempty"` // generate a variable for each top-level directory
+	NoIgnore  bool              `json:"noignore,omitempty"`  // don't skip files listed in ignore files
+	Update    bool              `json:"update,omitempty"`    // keep the modification time of unchanged files
+	Portable  bool              `json:"portable,omitempty"`  // fail on non-portable file names, instead of warning
+	Names     string            `json:"names,omitempty"`     // handling of invalid names: reject or transliterate
+	Follow    bool              `json:"follow,omitempty"`    // follow symlinks to directories
+	EmptyDirs bool              `json:"emptydirs,omitempty"` // create empty directories
+	Encoding  string            `json:"encoding,omitempty"`  // encoding of content: hex, base64 or raw
+	Readable  int               `json:"readable,omitempty"`  // size up to which text files are raw encoded
+	Chunk     int               `json:"chunk,omitempty"`     // size (MiB) above which files are split into chunks
+	Width     int               `json:"width,omitempty"`     // maximum width of hex and base64 string literals
+	Comments  bool              `json:"comments,omitempty"`  // precede each file with a comment
+	TOC       bool              `json:"toc,omitempty"`       // generate a table of contents comment
+	Zopfli    bool              `json:"zopfli,omitempty"`    // compress with zopfli
+	MimeTypes map[string]string `json:"mimetypes,omitempty"` // MIME types, by extension ("png": "image/png")
+
+	report  io.Writer // writes a report of generated assets (set by -report)
+	command string    // the command line, for the header of generated files
+	check   bool      // check that target is up to date, instead of generating it
+}
+
+// Config describes several files to generate in one invocation.
+//
+//	{
+//		"targets": [
+//			{"source": "static", "target": "assets.go", "pkg": "main"},
+//			{"source": "docs", "target": "docs/assets.go", "var": "docs", "minify": true}
+//		]
+//	}
+type Config struct {
+	Targets []Target `json:"targets"`
+}
+
+// loadConfig reads a config file.
+// Paths are relative to the directory of the file,
+// and options not set for a target are taken from defaults
+// (options set to false, zero or empty override defaults).
+func loadConfig(name string, defaults Target) ([]Target, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	// decode each target over defaults, so only options present in the file override them
+	var config struct {
+		Targets []json.RawMessage `json:"targets"`
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, err
+	}
+
+	dir := filepath.Dir(name)
+	targets := make([]Target, len(config.Targets))
+	for i, raw := range config.Targets {
+		t := &targets[i]
+		*t = defaults
+		t.Source, t.Target, t.MimeTypes = "", "", nil
+		if err := json.Unmarshal(raw, t); err != nil {
+			return nil, err
+		}
+		t.Source = filepath.Join(dir, filepath.FromSlash(t.Source))
+		t.Target = filepath.Join(dir, filepath.FromSlash(t.Target))
+	}
+	return targets, nil
+}
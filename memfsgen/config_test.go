@@ -0,0 +1,64 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "memfsgen.json")
+	err := os.WriteFile(name, []byte(`{
+		"targets": [
+			{"source": "static", "target": "assets.go"},
+			{"source": "docs", "target": "docs/assets.go", "pkg": "docs", "var": "docs",
+				"minify": false, "update": false, "encoding": "base64",
+				"readable": 0, "chunk": 0, "width": 0, "mimetypes": {"md": "text/markdown"}}
+		]
+	}`), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defaults := Target{
+		Package:  "main",
+		Variable: "assets",
+		Minify:   true,
+		Update:   true,
+		Encoding: "hex",
+		Readable: 512,
+		Chunk:    16,
+		Width:    80,
+		command:  "-minify -update",
+		check:    true,
+	}
+	targets, err := loadConfig(name, defaults)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Target{defaults, {
+		Package:   "docs",
+		Variable:  "docs",
+		Encoding:  "base64",
+		MimeTypes: map[string]string{"md": "text/markdown"},
+		command:   "-minify -update",
+		check:     true,
+	}}
+	want[0].Source = filepath.Join(dir, "static")
+	want[0].Target = filepath.Join(dir, "assets.go")
+	want[1].Source = filepath.Join(dir, "docs")
+	want[1].Target = filepath.Join(dir, "docs", "assets.go")
+	if !reflect.DeepEqual(targets, want) {
+		t.Errorf("got %+v, want %+v", targets, want)
+	}
+
+	if err := os.WriteFile(name, []byte(`{"targets": [{"chunk": "16"}]}`), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadConfig(name, defaults); err == nil {
+		t.Error("want error")
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// walkTree walks the tree at root like filepath.Walk,
+// but follows symlinks to directories if w.follow is set.
+// A link back into a directory being walked is a cycle, and an error.
+func (w walker) walkTree(root string, fn filepath.WalkFunc) error {
+	if !w.follow {
+		return filepath.Walk(root, fn)
+	}
+	info, err := os.Stat(root)
+	if err != nil {
+		err = fn(root, nil, err)
+	} else {
+		err = follow(root, info, nil, fn)
+	}
+	if err == filepath.SkipDir {
+		return nil
+	}
+	return err
+}
+
+// A directory being walked, to detect cycles.
+type ancestor struct {
+	path string
+	info os.FileInfo
+}
+
+// follow walks path, with the info of its target, following symlinks.
+// Directories in parents are being walked, and can't be walked again.
+func follow(path string, info os.FileInfo, parents []ancestor, fn filepath.WalkFunc) error {
+	if err := fn(path, info, nil); err != nil || !info.IsDir() {
+		return err
+	}
+	// same device and inode as a directory being walked
+	for _, p := range parents {
+		if os.SameFile(p.info, info) {
+			return fmt.Errorf("symlink cycle: %s links back to %s", path, p.path)
+		}
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return fn(path, info, err)
+	}
+	parents = append(parents, ancestor{path, info})
+	for _, e := range entries {
+		name := filepath.Join(path, e.Name())
+		info, err := os.Stat(name)
+		if err != nil {
+			info, _ = os.Lstat(name)
+			err = fn(name, info, err)
+		} else {
+			err = follow(name, info, parents, fn)
+		}
+		if err == filepath.SkipDir && info != nil && info.IsDir() {
+			continue
+		}
+		if err != nil {
+			if err == filepath.SkipDir {
+				return nil // skip the rest of this directory
+			}
+			return err
+		}
+	}
+	return nil
+}
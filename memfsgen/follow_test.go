@@ -0,0 +1,51 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestGenerate_follow(t *testing.T) {
+	source := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(source, "a"), 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(source, "a", "f.txt"), []byte("f"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("a", filepath.Join(source, "b")); err != nil {
+		t.Skip(err)
+	}
+	if err := os.Symlink(filepath.Join("a", "f.txt"), filepath.Join(source, "g.txt")); err != nil {
+		t.Skip(err)
+	}
+
+	target := filepath.Join(t.TempDir(), "assets.go")
+	err := Target{Source: source, Target: target, Package: "assets", Variable: "assets"}.generate()
+	if err == nil || !strings.Contains(err.Error(), "symlink to a directory") {
+		t.Errorf("got %v", err)
+	}
+
+	err = Target{Source: source, Target: target, Package: "assets", Variable: "assets", Follow: true}.generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := createdNames(t, target)
+	sort.Strings(got)
+	if want := []string{"a/f.txt", "b/f.txt", "g.txt"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// a/loop links back to a
+	if err := os.Symlink("..", filepath.Join(source, "a", "loop")); err != nil {
+		t.Fatal(err)
+	}
+	err = Target{Source: source, Target: target, Package: "assets", Variable: "assets", Follow: true}.generate()
+	if err == nil || !strings.Contains(err.Error(), "symlink cycle: "+filepath.Join(source, "a", "loop")+" links back to "+source) {
+		t.Errorf("got %v", err)
+	}
+}
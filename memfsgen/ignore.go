@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Ignore files, read in this order from each directory,
+// with gitignore syntax.
+var ignoreFiles = []string{".gitignore", ".memfsignore"}
+
+// An ignore rule, from a line of an ignore file.
+type ignoreRule struct {
+	base    string // directory of the ignore file, relative to the root ("" for the root)
+	regexp  *regexp.Regexp
+	negate  bool // line starts with !
+	dirOnly bool // line ends with /
+	nested  bool // pattern matches the path relative to base, not the base name
+}
+
+// An ignorer tracks the ignore rules found walking a directory.
+type ignorer struct {
+	rules []ignoreRule
+}
+
+// ignoring wraps fn so it skips files and directories ignored by the ignore files found walking root.
+// Ignore files themselves are skipped.
+func (w walker) ignoring(root string, fn filepath.WalkFunc) filepath.WalkFunc {
+	if w.noignore {
+		return fn
+	}
+	var ig ignorer
+	return func(name string, info os.FileInfo, err error) error {
+		if err != nil {
+			return fn(name, info, err)
+		}
+		rel, err := filepath.Rel(root, name)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel != "." && ig.ignored(rel, info.IsDir()) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() {
+			if rel == "." {
+				rel = ""
+			}
+			if err := ig.load(name, rel); err != nil {
+				return err
+			}
+		} else if isIgnoreFile(path.Base(rel)) {
+			return nil
+		}
+		return fn(name, info, nil)
+	}
+}
+
+func isIgnoreFile(name string) bool {
+	for _, n := range ignoreFiles {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// load reads the ignore files of dir (base, relative to the root).
+func (ig *ignorer) load(dir, base string) error {
+	for _, name := range ignoreFiles {
+		f, err := os.Open(filepath.Join(dir, name))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			if rule, ok := parseIgnore(base, scanner.Text()); ok {
+				ig.rules = append(ig.rules, rule)
+			}
+		}
+		f.Close()
+		if err := scanner.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ignored reports if name (relative to the root) is ignored: the last matching rule wins.
+func (ig *ignorer) ignored(name string, isDir bool) bool {
+	ignored := false
+	for _, r := range ig.rules {
+		rel := name
+		if r.base != "" {
+			var ok bool
+			if rel, ok = strings.CutPrefix(name, r.base+"/"); !ok {
+				continue
+			}
+		}
+		if r.dirOnly && !isDir {
+			continue
+		}
+		if !r.nested {
+			rel = path.Base(rel)
+		}
+		if r.regexp.MatchString(rel) {
+			ignored = !r.negate
+		}
+	}
+	return ignored
+}
+
+// parseIgnore parses a line of an ignore file.
+func parseIgnore(base, line string) (rule ignoreRule, ok bool) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || line[0] == '#' {
+		return rule, false
+	}
+	rule.base = base
+	if line[0] == '!' {
+		rule.negate = true
+		line = line[1:]
+	} else if line[0] == '\\' {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if strings.Contains(line, "/") {
+		rule.nested = true
+		line = strings.TrimPrefix(line, "/")
+	}
+	if line == "" {
+		return rule, false
+	}
+	re, err := regexp.Compile(globRegexp(line))
+	if err != nil {
+		return rule, false
+	}
+	rule.regexp = re
+	return rule, true
+}
+
+// globRegexp converts a gitignore glob to a regular expression.
+func globRegexp(glob string) string {
+	var re strings.Builder
+	re.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; {
+		case strings.HasPrefix(glob[i:], "**/"):
+			re.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "/**") && i+3 == len(glob):
+			re.WriteString("/.*")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			re.WriteString(".*")
+			i++
+		case c == '*':
+			re.WriteString("[^/]*")
+		case c == '?':
+			re.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				re.WriteString(`\[`)
+				break
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			re.WriteString("[" + class + "]")
+			i += end + 1
+		case c == '\\' && i+1 < len(glob):
+			i++
+			re.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		default:
+			re.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+	re.WriteString("$")
+	return re.String()
+}
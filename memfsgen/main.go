@@ -4,20 +4,28 @@ package main
 import (
 	"bytes"
 	"compress/gzip"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/hex"
 	"errors"
 	"flag"
 	"fmt"
 	"go/token"
 	"hash/crc32"
+	"io"
+	"io/fs"
 	"mime"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 	"text/template"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/gabriel-vasile/mimetype"
 	"github.com/tdewolff/minify/v2"
@@ -30,41 +38,241 @@ import (
 )
 
 type Assets struct {
-	Tag      string
-	Package  string
-	Variable string
-	Assets   <-chan Asset
+	Header    string
+	Tag       string
+	Package   string
+	Variable  string
+	Accessors bool
+	Base64    bool
+	Comments  bool
+	BuildID   string
+	BuildTime int64
+	Contents  []Entry
+	Dirs      []Dir
+	EmptyDirs []EmptyDir
+	Assets    <-chan Asset
+}
+
+// An entry of the table of contents (set by -toc).
+type Entry struct {
+	Path string // path of the file, relative to the source directory
+	Size int64  // size of the source file
+}
+
+// A top-level directory, generated as its own variable.
+type Dir struct {
+	Name string
+	Var  string
+}
+
+// An empty directory, created explicitly (set by -empty-dirs).
+type EmptyDir struct {
+	FS   string // variable of the FileSystem the directory is created in
+	Name string // name of the directory, in its FileSystem
 }
 
 type Asset struct {
-	Name  string
+	FS    string // variable of the FileSystem the asset is created in
+	Path  string // path of the asset, relative to the source directory
+	Name  string // name of the asset, in its FileSystem
 	Type  string
 	Time  int64
 	Size  int
 	Hash  uint32
 	Lines <-chan string
+	Var   string // variable holding content shared by duplicate assets
+	Dup   bool   // Var was declared by a previous asset
+	Dec   string // function decoding Lines, if they're encoded
+
+	Chunks []Chunk // instead of Lines, content split into chunks concatenated into Var
+
+	Stored int // size of the generated content (zero for duplicates)
+}
+
+// A chunk of the content of a large asset.
+type Chunk struct {
+	Lines <-chan string
+	Dec   string
 }
 
-var generator = template.Must(template.New("").Parse(`// Code generated by memfsgen; DO NOT EDIT.
+var generator = template.Must(template.New("").Parse(`{{.Header}}
 
 {{- .Tag}}
 
 package {{.Package}}
 
-import "time"
+{{if .Base64}}import "encoding/base64"
+{{end}}{{if .Accessors}}import "io/fs"
+{{end}}import "time"
 import "github.com/ncruces/go-fs/memfs"
+{{- if .Contents}}
+
+// {{.Variable}} contains {{len .Contents}} files:
+//
+{{- range .Contents}}
+//	{{printf "%q" .Path}}: {{.Size}} bytes
+{{- end}}
+{{- end}}
 
 var {{.Variable}} = memfs.Create()
+{{- range .Dirs}}
+var {{.Var}} = memfs.Create()
+{{- end}}
+
+// {{.Variable}}BuildID is the hash of the source of {{.Variable}}.
+const {{.Variable}}BuildID = {{printf "%q" .BuildID}}
 
 func init() {
 	var fs = {{.Variable}}
+	fs.SetBuildID({{.Variable}}BuildID, time.Unix({{.BuildTime}}, 0))
 	{{- range .Assets}}
-	fs.CreateString({{printf "%#v" .Name}}, {{printf "%#v" .Type}}, time.Unix({{.Time}}, 0), {{printf "%#08x" .Hash}}, {{.Size}}, ""
-		{{- range .Lines}}+
-		"{{.}}"
-		{{- end}})
+	{{- if $.Comments}}
+
+	// {{printf "%q" .Path}}: {{.Size}} bytes
+	{{- end}}
+	{{- if and .Var (not .Dup)}}
+	{{- $var := .Var}}
+	{{- range $i, $c := .Chunks}}
+	{{$var}}_{{$i}} := {{template "content" $c}}
+	{{- end}}
+	{{.Var}} := {{if .Chunks}}{{range $i, $c := .Chunks}}{{if $i}} + {{end}}{{$var}}_{{$i}}{{end}}{{else}}{{template "content" .}}{{end}}
+	{{- end}}
+	{{.FS}}.CreateString({{printf "%#v" .Name}}, {{printf "%#v" .Type}}, time.Unix({{.Time}}, 0), {{printf "%#08x" .Hash}}, {{.Size}},
+		{{- if .Var}} {{.Var}}{{else}} {{template "content" .}}{{end}})
+	{{- end}}
+	{{- range .EmptyDirs}}
+	if err := {{.FS}}.CreateDir({{printf "%#v" .Name}}); err != nil {
+		panic(err)
+	}
+	{{- end}}
+	{{- range .Dirs}}
+	if err := fs.Mount({{printf "%#v" .Name}}, {{.Var}}); err != nil {
+		panic(err)
+	}
 	{{- end}}
 }
+
+{{- if .Base64}}
+
+// {{.Variable}}Base64 decodes base64 encoded content.
+func {{.Variable}}Base64(s string) string {
+	data, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return string(data)
+}
+{{- end}}
+{{define "content"}}{{if .Dec}}{{.Dec}}({{end}}""{{range .Lines}}+
+		{{.}}{{end}}{{if .Dec}}){{end}}{{end}}`))
+
+type ETags struct {
+	Variable string
+	ETags    []ETag
+}
+
+type ETag struct {
+	Name string
+	ETag string
+}
+
+var etags = template.Must(template.New("").Parse(`
+// {{.Variable}}ETags maps the names of files in {{.Variable}} to their ETags.
+// Compressed files served to clients that accept gzip get weak ETags (prefixed with W/).
+var {{.Variable}}ETags = map[string]string{
+	{{- range .ETags}}
+	{{printf "%#v" .Name}}: {{printf "%#v" .ETag}},
+	{{- end}}
+}
+`))
+
+type Accessors struct {
+	Variable  string
+	Accessors []Accessor
+}
+
+type Accessor struct {
+	Name string
+	Func string
+}
+
+var accessors = template.Must(template.New("").Parse(`
+{{- range .Accessors}}
+
+// {{.Func}} returns the content of {{printf "%q" .Name}}.
+func {{.Func}}() []byte {
+	data, err := {{$.Variable}}.ReadFile({{printf "%#v" .Name}})
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+// Open{{.Func}} opens {{printf "%q" .Name}}.
+func Open{{.Func}}() fs.File {
+	f, err := {{$.Variable}}.Open({{printf "%#v" .Name}})
+	if err != nil {
+		panic(err)
+	}
+	return f
+}
+{{- end}}
+`))
+
+type Embed struct {
+	Header   string
+	Tag      string
+	Package  string
+	Variable string
+	Source   string
+	Pattern  string // the //go:embed pattern for Source
+}
+
+var embedder = template.Must(template.New("").Parse(`{{.Header}}
+
+{{- .Tag}}
+
+package {{.Package}}
+
+import (
+	"compress/gzip"
+	"embed"
+	"io/fs"
+	"net/http"
+	"sync"
+
+	"github.com/ncruces/go-fs/memfs"
+)
+
+//go:embed {{.Pattern}}
+var {{.Variable}}Embed embed.FS
+
+var {{.Variable}} = new({{.Variable}}FS)
+
+// {{.Variable}}FS wraps {{.Variable}}Embed,
+// loading and compressing its files into a memfs.FileSystem on first use.
+type {{.Variable}}FS struct {
+	once sync.Once
+	fsys *memfs.FileSystem
+}
+
+// FileSystem returns the memfs.FileSystem.
+func (a *{{.Variable}}FS) FileSystem() *memfs.FileSystem {
+	a.once.Do(func() {
+		a.fsys = memfs.MustLoadCompressed(memfs.MustSub({{.Variable}}Embed, {{printf "%#v" .Source}}), gzip.BestCompression)
+	})
+	return a.fsys
+}
+
+// Open implements fs.FS.
+func (a *{{.Variable}}FS) Open(name string) (fs.File, error) {
+	return a.FileSystem().Open(name)
+}
+
+// ServeHTTP implements http.Handler.
+func (a *{{.Variable}}FS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.FileSystem().ServeHTTP(w, r)
+}
 `))
 
 var minifier *minify.M
@@ -74,57 +282,176 @@ func main() {
 	pkgName := flag.String("pkg", "", "package name (default: lowercase name of <target-file> directory)")
 	varName := flag.String("var", "assets", "variable name")
 	minifie := flag.Bool("minify", false, "minify web assets")
+	etagMap := flag.Bool("etags", false, "generate a map of ETags, named <var>ETags")
+	encodin := flag.String("encoding", "hex", "encoding of content: hex, base64 (decoded at init) or raw (readable text files, not compressed)")
+	readabl := flag.Int("readable", 0, "embed text files up to this size as readable raw string literals, not compressed")
+	chunkMB := flag.Int("chunk", 16, "split files larger than this many MiB into several literals, concatenated at init (0 to disable)")
+	updates := flag.Bool("update", false, "keep the modification time of files unchanged since <target-file> was generated, so only changes show in diffs")
+	noignor := flag.Bool("noignore", false, "don't skip files listed in .gitignore and .memfsignore files")
+	perDirs := flag.Bool("var-per-dir", false, "generate a variable for each top-level directory, named <var><Dir>")
+	getters := flag.Bool("accessors", false, "generate typed accessor functions for each file (IndexHTML, OpenIndexHTML…)")
+	report := flag.String("report", "", `write a report of generated sizes to file ("-" for standard output)`)
+	wrapper := flag.Bool("embed", false, "wrap an embed.FS of <source-dir>, loaded and compressed on first use, instead of generating content")
+	config := flag.String("config", "", "generate the targets of a JSON config file, instead of <source-dir> <target-file>")
+	invalid := flag.String("names", "", `handling of names that aren't valid paths (not UTF-8): "reject" or "transliterate" (from Latin-1) (default: skip, with a warning)`)
+	emptyDs := flag.Bool("empty-dirs", false, "create the empty directories of <source-dir>, which are otherwise dropped")
+	follows := flag.Bool("follow", false, "follow symlinks to directories (symlinks to files are always followed)")
+	portabl := flag.Bool("portable", false, "fail on file names that differ only by case, or are reserved on Windows, instead of warning")
+	widthLn := flag.Int("width", 80, "maximum width of hex and base64 string literals")
+	comment := flag.Bool("comments", false, "precede each file with a comment naming its path and size")
+	listing := flag.Bool("toc", false, "generate a table of contents comment, listing every file and its size")
+	zopflis := flag.Bool("zopfli", false, "compress with a zopfli encoder: 3-8% smaller, still gzip, but much slower to generate")
+	checker := flag.Bool("check", false, "check that <target-file> was generated with the same options from the same <source-dir>, instead of generating it")
 	flag.Var(mimeTypes, "mimetype", `register a MIME type ("png:image/png", "txt:text/plain"…)`)
 	flag.Usage = usage
 	flag.Parse()
 
-	if flag.NArg() != 2 {
-		usage()
+	defaults := Target{
+		Tag:       *tagName,
+		Package:   *pkgName,
+		Variable:  *varName,
+		Minify:    *minifie,
+		ETags:     *etagMap,
+		Accessors: *getters,
+		Embed:     *wrapper,
+		VarPerDir: *perDirs,
+		NoIgnore:  *noignor,
+		Update:    *updates,
+		Portable:  *portabl,
+		Names:     *invalid,
+		Follow:    *follows,
+		EmptyDirs: *emptyDs,
+		Encoding:  *encodin,
+		Readable:  *readabl,
+		Chunk:     *chunkMB,
+		Width:     *widthLn,
+		Comments:  *comment,
+		TOC:       *listing,
+		Zopfli:    *zopflis,
+
+		command: provenance(os.Args[1:]),
+		check:   *checker,
+	}
+
+	switch *report {
+	case "":
+	case "-":
+		defaults.report = os.Stdout
+	default:
+		f, err := os.Create(*report)
+		if err != nil {
+			fatal("report %s: %v", *report, err)
+		}
+		defer f.Close()
+		defaults.report = f
+	}
+
+	var targets []Target
+	if *config != "" {
+		if flag.NArg() != 0 {
+			usage()
+		}
+		var err error
+		targets, err = loadConfig(*config, defaults)
+		if err != nil {
+			fatal("config %s: %v", *config, err)
+		}
+	} else {
+		if flag.NArg() != 2 {
+			usage()
+		}
+		defaults.Source = flag.Arg(0)
+		defaults.Target = flag.Arg(1)
+		targets = []Target{defaults}
 	}
 
-	source := flag.Arg(0)
-	target := flag.Arg(1)
+	for _, t := range targets {
+		if err := t.generate(); err != nil {
+			fatal("%v", err)
+		}
+	}
+}
+
+func (t Target) generate() error {
+	source := t.Source
+	target := t.Target
 
 	// check that source exists and is a directory
 	if s, err := os.Stat(source); os.IsNotExist(err) {
-		fatal("source-dir %s: does not exist", source)
+		return fmt.Errorf("source-dir %s: does not exist", source)
 	} else if err != nil {
-		fatal("source-dir %s: %v", source, err)
+		return fmt.Errorf("source-dir %s: %v", source, err)
 	} else if !s.IsDir() {
-		fatal("source-dir %s: not a directory", source)
+		return fmt.Errorf("source-dir %s: not a directory", source)
 	}
 
 	// check that target is a go file
 	if filepath.Ext(target) != ".go" {
-		fatal("target-file %s: not a .go file", target)
+		return fmt.Errorf("target-file %s: not a .go file", target)
 	}
 	// create the target directory
 	tgtdir := filepath.Dir(target)
 	if err := os.MkdirAll(tgtdir, 0755); err != nil {
-		fatal("target-file %s: %v", target, err)
+		return fmt.Errorf("target-file %s: %v", target, err)
 	}
 	// package name defaults to directory name
-	if *pkgName == "" {
+	if t.Package == "" {
 		if tgtdir, err := filepath.Abs(tgtdir); err != nil {
-			fatal("target-file %s: %v", target, err)
+			return fmt.Errorf("target-file %s: %v", target, err)
 		} else {
-			*pkgName = strings.ToLower(filepath.Base(tgtdir))
+			t.Package = strings.ToLower(filepath.Base(tgtdir))
 		}
 	}
 	// build tags
-	if *tagName != "" {
-		*tagName = "\n\n// +build " + *tagName
+	if t.Tag != "" {
+		t.Tag = "\n\n// +build " + t.Tag
 	}
 
 	// identifiers should be valid
-	if !token.IsIdentifier(*pkgName) {
-		fatal("invalid package name: %s", *pkgName)
+	if !token.IsIdentifier(t.Package) {
+		return fmt.Errorf("invalid package name: %s", t.Package)
+	}
+	if !token.IsIdentifier(t.Variable) {
+		return fmt.Errorf("invalid variable name: %s", t.Variable)
+	}
+
+	if t.Embed {
+		return t.embed()
+	}
+
+	switch t.Encoding {
+	case "":
+		t.Encoding = "hex"
+	case "hex", "base64", "raw":
+	default:
+		return fmt.Errorf("invalid encoding: %s", t.Encoding)
+	}
+
+	switch {
+	case t.Width == 0:
+		t.Width = 80
+	case t.Width < 6:
+		return fmt.Errorf("invalid width: %d (at least 6)", t.Width)
+	}
+
+	switch t.Names {
+	case "", "reject", "transliterate":
+	default:
+		return fmt.Errorf("invalid names handling: %s", t.Names)
+	}
+
+	// MIME types for this target
+	types := MimeTypes{}
+	for ext, typ := range mimeTypes {
+		types[ext] = typ
 	}
-	if !token.IsIdentifier(*varName) {
-		fatal("invalid variable name: %s", *varName)
+	for ext, typ := range t.MimeTypes {
+		if err := types.Set(strings.TrimPrefix(ext, ".") + ":" + typ); err != nil {
+			return fmt.Errorf("invalid MIME type %s: %v", typ, err)
+		}
 	}
 
-	if *minifie {
+	if t.Minify && minifier == nil {
 		minifier = minify.New()
 		minifier.AddFunc("text/css", css.Minify)
 		minifier.AddFunc("text/html", html.Minify)
@@ -134,23 +461,236 @@ func main() {
 		minifier.AddFuncRegexp(regexp.MustCompile("[/+]xml$"), xml.Minify)
 	}
 
+	// modification times are clamped for reproducible builds
+	epoch, err := sourceDateEpoch()
+	if err != nil {
+		return err
+	}
+
+	// a variable for each top-level directory
+	w := walker{types: types, minify: t.Minify, noignore: t.NoIgnore, names: t.Names, follow: t.Follow, emptyDirs: t.EmptyDirs, zopfli: t.Zopfli, epoch: epoch, encoding: t.Encoding, decode: t.Variable + "Base64", readable: t.Readable, width: t.Width, chunk: t.Chunk << 20}
+	var dirs []Dir
+	if t.VarPerDir {
+		entries, err := os.ReadDir(source)
+		if err != nil {
+			return fmt.Errorf("source-dir %s: %v", source, err)
+		}
+		var ig ignorer
+		if !t.NoIgnore {
+			if err := ig.load(source, ""); err != nil {
+				return fmt.Errorf("source-dir %s: %v", source, err)
+			}
+		}
+		w.dirs = map[string]string{}
+		vars := map[string]string{t.Variable: ".", t.Variable + "ETags": ".", t.Variable + "BuildID": "."}
+		for _, e := range entries {
+			isDir := e.IsDir()
+			if t.Follow && e.Type()&fs.ModeSymlink != 0 {
+				s, err := os.Stat(filepath.Join(source, e.Name()))
+				isDir = err == nil && s.IsDir()
+			}
+			if !isDir || ig.ignored(e.Name(), true) {
+				continue
+			}
+			name, ok := w.rename(e.Name())
+			if !ok {
+				continue
+			}
+			v := t.Variable + camelCase(name)
+			if prev, ok := vars[v]; ok {
+				return fmt.Errorf("variable %s: conflicting directories %s and %s", v, prev, name)
+			}
+			vars[v] = name
+			w.dirs[name] = v
+			dirs = append(dirs, Dir{name, v})
+		}
+	}
+
+	// the hash of the source tree, and duplicate assets
+	src, err := w.scan(source)
+	if err != nil {
+		return fmt.Errorf("source-dir %s: %v", source, err)
+	}
+	w.count = src.count
+	hash := src.hash
+
+	// names that can't be opened
+	names, problems, err := validNames(src.names, t.Names)
+	if err != nil {
+		return fmt.Errorf("source-dir %s: %v", source, err)
+	}
+	if len(problems) > 0 {
+		if t.Names == "reject" {
+			return fmt.Errorf("source-dir %s: invalid names:\n\t%s", source, strings.Join(problems, "\n\t"))
+		}
+		for _, p := range problems {
+			fmt.Fprintf(os.Stderr, "source-dir %s: warning: %s\n", source, p)
+		}
+	}
+
+	// names that break checkouts on other systems
+	if problems := portability(names); len(problems) > 0 {
+		if t.Portable {
+			return fmt.Errorf("source-dir %s: non-portable names:\n\t%s", source, strings.Join(problems, "\n\t"))
+		}
+		for _, p := range problems {
+			fmt.Fprintf(os.Stderr, "source-dir %s: warning: %s\n", source, p)
+		}
+	}
+	header := t.header(hash)
+	if t.check {
+		return checkHeader(target, header, t.command)
+	}
+
+	// table of contents, with the sizes of source files
+	var contents []Entry
+	if t.TOC {
+		for _, name := range src.names {
+			size, ok := src.sizes[name]
+			if !ok {
+				continue // a directory
+			}
+			if name, ok := w.rename(name); ok {
+				contents = append(contents, Entry{name, size})
+			}
+		}
+	}
+
+	// empty directories, in the FileSystem of their top-level directory
+	var empty []EmptyDir
+	if t.EmptyDirs {
+		for _, dir := range src.empty {
+			name, ok := w.rename(dir)
+			if !ok || w.dirs[name] != "" {
+				continue // invalid, or mounted as its own (empty) FileSystem
+			}
+			e := EmptyDir{"fs", name}
+			if top, rest, ok := strings.Cut(name, "/"); ok && w.dirs[top] != "" {
+				e = EmptyDir{w.dirs[top], rest}
+			}
+			empty = append(empty, e)
+		}
+	}
+
+	// the build time is that of the newest source file, so output is reproducible
+	build := prevBuild{id: hash[:16]}
+	if w.epoch != nil {
+		build.time = w.epoch.Unix()
+	} else if !src.newest.IsZero() {
+		build.time = src.newest.Unix()
+	}
+
+	// assets generated by a previous run
+	if t.Update {
+		prev, prevBuild, err := previous(target)
+		if err != nil {
+			return fmt.Errorf("target-file %s: %v", target, err)
+		}
+		if prevBuild.id == build.id {
+			build = prevBuild
+		}
+		w.prev = prev
+	}
+
 	// create target
 	out, err := os.Create(target)
 	if err != nil {
-		fatal("target-file %s: %v", target, err)
+		return fmt.Errorf("target-file %s: %v", target, err)
 	}
 	defer out.Close()
 
 	assets := make(chan Asset)
-	go walk(source, assets)
+	go w.walk(source, assets)
+
+	// collect assets as they're generated
+	var generated []Asset
+	all := assets
+	assets = make(chan Asset)
+	go func() {
+		for a := range all {
+			assets <- a
+			a.Lines = nil
+			generated = append(generated, a)
+		}
+		close(assets)
+	}()
 
-	if err := generator.Execute(out, Assets{*tagName, *pkgName, *varName, assets}); err != nil {
-		fatal("generating output: %v", err)
+	if err := generator.Execute(out, Assets{header, t.Tag, t.Package, t.Variable, t.Accessors, t.Encoding == "base64", t.Comments, build.id, build.time, contents, dirs, empty, assets}); err != nil {
+		return fmt.Errorf("generating output: %v", err)
+	}
+	if t.ETags {
+		var tags []ETag
+		for _, a := range generated {
+			if a.Hash != 0 {
+				tags = append(tags, ETag{a.Path, `"` + strconv.FormatUint(uint64(a.Hash), 36) + `"`})
+			}
+		}
+		if err := etags.Execute(out, ETags{t.Variable, tags}); err != nil {
+			return fmt.Errorf("generating output: %v", err)
+		}
 	}
+	if t.Accessors {
+		var funcs []Accessor
+		names := map[string]string{}
+		for _, a := range generated {
+			fn := accessorName(a.Path)
+			if prev, ok := names[fn]; ok {
+				return fmt.Errorf("accessor %s: conflicting files %s and %s", fn, prev, a.Path)
+			}
+			names[fn] = a.Path
+			funcs = append(funcs, Accessor{a.Path, fn})
+		}
+		if err := accessors.Execute(out, Accessors{t.Variable, funcs}); err != nil {
+			return fmt.Errorf("generating output: %v", err)
+		}
+	}
+	if t.report != nil {
+		writeReport(t.report, t.Target, generated)
+	}
+	return out.Close()
+}
+
+// embed generates a wrapper of an embed.FS, with no content.
+func (t Target) embed() error {
+	if t.Minify || t.ETags || t.Accessors || t.VarPerDir || t.EmptyDirs || t.Zopfli || len(t.MimeTypes) > 0 {
+		return errors.New("embed: minify, etags, accessors, varperdir, emptydirs, zopfli and mimetypes are not supported")
+	}
+
+	// source must be in the package directory, or a subdirectory
+	src, err := filepath.Abs(t.Source)
+	if err != nil {
+		return fmt.Errorf("source-dir %s: %v", t.Source, err)
+	}
+	dir, err := filepath.Abs(filepath.Dir(t.Target))
+	if err != nil {
+		return fmt.Errorf("target-file %s: %v", t.Target, err)
+	}
+	rel, err := filepath.Rel(dir, src)
+	if err != nil || rel == "." || !fs.ValidPath(filepath.ToSlash(rel)) {
+		return fmt.Errorf("source-dir %s: not a subdirectory of the target-file directory", t.Source)
+	}
+
+	header := t.header("")
+	if t.check {
+		return checkHeader(t.Target, header, t.command)
+	}
+
+	out, err := os.Create(t.Target)
+	if err != nil {
+		return fmt.Errorf("target-file %s: %v", t.Target, err)
+	}
+	defer out.Close()
+
+	if err := embedder.Execute(out, Embed{header, t.Tag, t.Package, t.Variable, filepath.ToSlash(rel), embedPattern(filepath.ToSlash(rel))}); err != nil {
+		return fmt.Errorf("generating output: %v", err)
+	}
+	return out.Close()
 }
 
 func usage() {
-	fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [options] <source-dir> <target-file>\n", filepath.Base(os.Args[0]))
+	name := filepath.Base(os.Args[0])
+	fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [options] <source-dir> <target-file>\n", name)
+	fmt.Fprintf(flag.CommandLine.Output(), "       %s [options] -config <config-file>\n", name)
 	flag.PrintDefaults()
 	os.Exit(2)
 }
@@ -160,9 +700,192 @@ func fatal(format string, a ...interface{}) {
 	os.Exit(2)
 }
 
-func walk(root string, assets chan<- Asset) {
+// provenance returns the command line that generates a target (without -check),
+// for the header of generated files.
+func provenance(args []string) string {
+	cmd := []string{"memfsgen"}
+	for _, arg := range args {
+		if name, _, _ := strings.Cut(strings.TrimLeft(arg, "-"), "="); name == "check" && arg[0] == '-' {
+			continue
+		}
+		if arg == "" || strings.ContainsFunc(arg, func(r rune) bool {
+			return unicode.IsSpace(r) || !unicode.IsPrint(r) || strings.ContainsRune("\"'`$\\", r)
+		}) {
+			arg = strconv.Quote(arg)
+		}
+		cmd = append(cmd, arg)
+	}
+	return strings.Join(cmd, " ")
+}
+
+// header returns the header of a generated file:
+// the command that generates it, and the hash of its source (if any).
+func (t Target) header(hash string) string {
+	header := "// Code generated by " + t.command + "; DO NOT EDIT."
+	if hash != "" {
+		header += "\n// Source hash: " + hash
+	}
+	return header
+}
+
+// checkHeader checks that target starts with header,
+// so it was generated by the same command, from the same source.
+func checkHeader(target, header, command string) error {
+	f, err := os.Open(target)
+	if err != nil {
+		return fmt.Errorf("target-file %s: %v", target, err)
+	}
+	defer f.Close()
+
+	buf := make([]byte, len(header)+1)
+	if _, err := io.ReadFull(f, buf); err != nil || string(buf) != header+"\n" {
+		return fmt.Errorf("target-file %s: out of date, regenerate with: %s", target, command)
+	}
+	return nil
+}
+
+// embedPattern returns the //go:embed pattern for a directory,
+// quoted if it has spaces, quotes, or characters that aren't printable.
+func embedPattern(dir string) string {
+	pattern := "all:" + dir
+	if strings.ContainsFunc(pattern, func(r rune) bool {
+		return unicode.IsSpace(r) || !unicode.IsPrint(r) || r == '"' || r == '`'
+	}) {
+		return strconv.Quote(pattern)
+	}
+	return pattern
+}
+
+// accessorName returns an exported identifier for an asset:
+// "index.html" is IndexHTML, "css/site-main.css" is CssSiteMainCSS.
+func accessorName(name string) string {
+	ext := path.Ext(name)
+	id := camelCase(strings.TrimSuffix(name, ext))
+	for _, r := range ext {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			id += string(unicode.ToUpper(r))
+		}
+	}
+	if !token.IsExported(id) {
+		return "File" + id
+	}
+	return id
+}
+
+// camelCase joins the words (letters and digits) of s, capitalizing each.
+func camelCase(s string) string {
+	words := strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	var id strings.Builder
+	for _, w := range words {
+		r, n := utf8.DecodeRuneInString(w)
+		id.WriteRune(unicode.ToUpper(r))
+		id.WriteString(w[n:])
+	}
+	return id.String()
+}
+
+// Identifies assets with the same content.
+type content struct {
+	hash [sha256.Size]byte
+	mime string
+}
+
+// Walks a source directory, generating assets.
+type walker struct {
+	types     MimeTypes
+	minify    bool
+	encoding  string                // encoding of content: hex, base64 or raw
+	decode    string                // function decoding base64 content
+	readable  int                   // size up to which text files are raw encoded
+	width     int                   // maximum width of hex and base64 string literals
+	chunk     int                   // size above which content is split into chunks
+	noignore  bool                  // don't skip files listed in ignore files
+	names     string                // handling of invalid names: reject or transliterate
+	dirs      map[string]string     // variables for top-level directories, by name
+	count     map[content]int       // assets by content, to share duplicates
+	prev      map[prevKey]prevAsset // assets generated by a previous run (set by -update)
+	follow    bool                  // follow symlinks to directories
+	emptyDirs bool                  // record empty directories
+	zopfli    bool                  // compress with zopfli, instead of compress/gzip
+	epoch     *time.Time            // modification times are clamped to SOURCE_DATE_EPOCH
+}
+
+// A source tree, scanned before generating assets.
+type scanned struct {
+	count  map[content]int  // assets by content, so duplicates can share it
+	hash   string           // hash of the names and contents of files (and empty directories, with -empty-dirs)
+	names  []string         // names of files and directories
+	sizes  map[string]int64 // sizes of files, by name
+	empty  []string         // names of empty directories
+	newest time.Time        // modification time of the newest file
+}
+
+// scan hashes the source tree, counts assets by content,
+// and lists the names of files and directories.
+func (w walker) scan(root string) (scanned, error) {
+	count := map[content]int{}
+	sizes := map[string]int64{}
+	tree := sha256.New()
+	var names, empty []string
+	var newest time.Time
+	var pending string // a directory that's empty, unless the next name is in it
+	err := w.walkTree(root, w.ignoring(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			if s, err := os.Stat(path); err == nil && s.IsDir() {
+				return fmt.Errorf("%s: symlink to a directory (use -follow)", path)
+			}
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		names = append(names, rel)
+
+		if pending != "" && !strings.HasPrefix(rel, pending+"/") {
+			empty = append(empty, pending)
+		}
+		pending = ""
+		if info.IsDir() {
+			pending = rel
+		} else {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			sum := sha256.Sum256(data)
+			count[content{sum, w.types.sniff(path, data)}]++
+			sizes[rel] = int64(len(data))
+			if info.ModTime().After(newest) {
+				newest = info.ModTime()
+			}
+			fmt.Fprintf(tree, "%q %x\n", rel, sum)
+		}
+		return nil
+	}))
+	if pending != "" {
+		empty = append(empty, pending)
+	}
+	if w.emptyDirs {
+		for _, dir := range empty {
+			fmt.Fprintf(tree, "%q/\n", dir)
+		}
+	}
+	return scanned{count, hex.EncodeToString(tree.Sum(nil)), names, sizes, empty, newest}, err
+}
+
+func (w walker) walk(root string, assets chan<- Asset) {
 	var hash = crc32.New(crc32.MakeTable(crc32.Castagnoli))
-	filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+	var count = w.count
+	var shared = map[content]string{}
+	var big int
+	w.walkTree(root, w.ignoring(root, func(path string, info os.FileInfo, err error) error {
 		if err == nil && !info.IsDir() {
 			data, err := os.ReadFile(path)
 			if err != nil {
@@ -173,10 +896,14 @@ func walk(root string, assets chan<- Asset) {
 			if err != nil {
 				return err
 			}
-			path = filepath.ToSlash(path)
+			path, ok := w.rename(filepath.ToSlash(path))
+			if !ok {
+				return nil
+			}
 
-			mime := sniff(path, data)
-			if minifier != nil {
+			mime := w.types.sniff(path, data)
+			key := content{sha256.Sum256(data), mime}
+			if w.minify {
 				data, _ = minifier.Bytes(mime, data)
 			}
 
@@ -187,38 +914,175 @@ func walk(root string, assets chan<- Asset) {
 			}
 
 			modtime := info.ModTime()
-			lines := make(chan string)
-			assets <- Asset{path, mime, modtime.Unix(), len(data), hash.Sum32(), lines}
-			err = dump(compress(data, modtime), lines)
-			close(lines)
-			return err
+			if w.epoch != nil && modtime.After(*w.epoch) {
+				modtime = *w.epoch
+			}
+			asset := Asset{FS: "fs", Path: path, Name: path, Type: mime, Size: len(data), Hash: hash.Sum32()}
+			if dir, name, ok := strings.Cut(path, "/"); ok && w.dirs[dir] != "" {
+				asset.FS, asset.Name = w.dirs[dir], name
+			}
+			// keep the modification time of unchanged assets, so their output is unchanged
+			if p, ok := w.prev[prevKey{asset.FS, asset.Name}]; ok && p.hash == asset.Hash && p.size == asset.Size {
+				modtime = time.Unix(p.time, 0)
+			}
+			asset.Time = modtime.Unix()
+			if count[key] > 1 {
+				asset.Var, asset.Dup = shared[key]
+				if !asset.Dup {
+					asset.Var = fmt.Sprintf("dup%d", len(shared)+1)
+					shared[key] = asset.Var
+				}
+			}
+			if asset.Dup {
+				assets <- asset
+				return nil
+			}
+
+			encode, raw := dump, false
+			switch {
+			case (w.encoding == "raw" || len(data) <= w.readable) && isText(mime) && rawable(data):
+				encode, raw = dumpRaw, true
+			case w.encoding == "base64":
+				encode = dumpBase64
+				asset.Dec = w.decode
+				data = compress(data, modtime, w.zopfli)
+			default:
+				data = compress(data, modtime, w.zopfli)
+			}
+
+			asset.Stored = len(data)
+			chunks := w.split(data, raw)
+			if len(chunks) > 1 && asset.Var == "" {
+				big++
+				asset.Var = fmt.Sprintf("big%d", big)
+			}
+			lines := make([]chan string, len(chunks))
+			for i := range lines {
+				lines[i] = make(chan string)
+				asset.Chunks = append(asset.Chunks, Chunk{lines[i], asset.Dec})
+			}
+			if len(chunks) == 1 {
+				asset.Lines, asset.Chunks = lines[0], nil
+			}
+			assets <- asset
+			for i, chunk := range chunks {
+				encode(chunk, w.width, lines[i])
+				close(lines[i])
+			}
+			return nil
 		}
 		return err
-	})
+	}))
 	close(assets)
 }
 
-func dump(data []byte, lines chan<- string) error {
+// sourceDateEpoch returns the time set by the SOURCE_DATE_EPOCH environment variable
+// (https://reproducible-builds.org/specs/source-date-epoch/), or nil if it's unset.
+func sourceDateEpoch() (*time.Time, error) {
+	env, ok := os.LookupEnv("SOURCE_DATE_EPOCH")
+	if !ok || env == "" {
+		return nil, nil
+	}
+	epoch, err := strconv.ParseInt(env, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SOURCE_DATE_EPOCH: %s", env)
+	}
+	t := time.Unix(epoch, 0)
+	return &t, nil
+}
+
+// rename returns the name of an asset for a path, relative to the source directory,
+// transliterated if it isn't valid (and -names is transliterate),
+// and reports whether the asset is generated.
+func (w walker) rename(path string) (string, bool) {
+	if fs.ValidPath(path) {
+		return path, true
+	}
+	if w.names == "transliterate" {
+		return transliterate(path), true
+	}
+	return path, false
+}
+
+// split splits data into chunks of at most w.chunk bytes,
+// so no string literal is too large for the compiler.
+// Raw data is split at rune boundaries.
+func (w walker) split(data []byte, raw bool) [][]byte {
+	var chunks [][]byte
+	for w.chunk > 0 && len(data) > w.chunk {
+		n := w.chunk
+		for raw && n > 0 && !utf8.RuneStart(data[n]) {
+			n--
+		}
+		chunks = append(chunks, data[:n])
+		data = data[n:]
+	}
+	return append(chunks, data)
+}
+
+// dump encodes data as string literals with hex escapes, at most width wide.
+func dump(data []byte, width int, lines chan<- string) {
 	var line strings.Builder
 	var char = []byte(`\xXX`)
 	for i := 0; i < len(data); {
-		for line.Len() < 80 && i < len(data) {
+		line.WriteByte('"')
+		for line.Len()+len(char)+1 <= width && i < len(data) {
 			hex.Encode(char[2:], data[i:i+1])
 			line.Write(char)
 			i++
 		}
+		line.WriteByte('"')
 		lines <- line.String()
 		line.Reset()
 	}
+}
 
-	return nil
+// dumpBase64 encodes data as base64 string literals, at most width wide.
+func dumpBase64(data []byte, width int, lines chan<- string) {
+	str := base64.StdEncoding.EncodeToString(data)
+	width -= 2 // quotes
+	for len(str) > width {
+		lines <- `"` + str[:width] + `"`
+		str = str[width:]
+	}
+	if len(str) > 0 {
+		lines <- `"` + str + `"`
+	}
+}
+
+// dumpRaw encodes data as a single raw string literal.
+func dumpRaw(data []byte, _ int, lines chan<- string) {
+	if len(data) > 0 {
+		lines <- "`" + string(data) + "`"
+	}
 }
 
-func compress(data []byte, modtime time.Time) []byte {
+// rawable reports if data can be a raw string literal, unchanged.
+func rawable(data []byte) bool {
+	return utf8.Valid(data) &&
+		!bytes.ContainsAny(data, "`\r\x00\ufeff")
+}
+
+// isText reports if a MIME type is text, which benefits from being readable.
+func isText(mime string) bool {
+	mime, _, _ = strings.Cut(mime, ";")
+	return strings.HasPrefix(mime, "text/") ||
+		strings.HasSuffix(mime, "json") || strings.HasSuffix(mime, "xml") ||
+		strings.HasSuffix(mime, "javascript") || strings.HasSuffix(mime, "+xml")
+}
+
+func compress(data []byte, modtime time.Time, zopfli bool) []byte {
 	if len(data) < 24 {
 		return data
 	}
 
+	if zopfli {
+		if buf := zopfliGzip(data, modtime); 4*len(data) >= 5*len(buf) {
+			return buf
+		}
+		return data
+	}
+
 	var buf bytes.Buffer
 
 	gzip, _ := gzip.NewWriterLevel(&buf, gzip.BestCompression)
@@ -273,9 +1137,9 @@ var mimeTypes = MimeTypes{
 	".xml":  "text/xml; charset=utf-8",
 }
 
-func sniff(name string, data []byte) string {
+func (mt MimeTypes) sniff(name string, data []byte) string {
 	ext := strings.ToLower(filepath.Ext(name))
-	if ctype, ok := mimeTypes[ext]; ok {
+	if ctype, ok := mt[ext]; ok {
 		return ctype
 	}
 
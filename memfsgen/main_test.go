@@ -0,0 +1,178 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGenerate_sourceDateEpoch(t *testing.T) {
+	source := t.TempDir()
+	for name, modtime := range map[string]time.Time{
+		"new.txt": time.Unix(1800000000, 0),
+		"old.txt": time.Unix(1600000000, 0),
+	} {
+		path := filepath.Join(source, name)
+		if err := os.WriteFile(path, []byte(strings.Repeat(name, 100)), 0666); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modtime, modtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Setenv("SOURCE_DATE_EPOCH", "1700000000")
+	target := filepath.Join(t.TempDir(), "assets.go")
+	err := Target{Source: source, Target: target, Package: "assets", Variable: "assets"}.generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`fs.SetBuildID(assetsBuildID, time.Unix(1700000000, 0))`,
+		`fs.CreateString("new.txt", "text/plain; charset=utf-8", time.Unix(1700000000, 0),`,
+		`fs.CreateString("old.txt", "text/plain; charset=utf-8", time.Unix(1600000000, 0),`,
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("missing %s", want)
+		}
+	}
+
+	t.Setenv("SOURCE_DATE_EPOCH", "yesterday")
+	err = Target{Source: source, Target: target, Package: "assets", Variable: "assets"}.generate()
+	if err == nil {
+		t.Error("want error")
+	}
+}
+
+func TestGenerate_emptyDirs(t *testing.T) {
+	source := t.TempDir()
+	for _, dir := range []string{"a", "b/c", "d/e", "f"} {
+		if err := os.MkdirAll(filepath.Join(source, filepath.FromSlash(dir)), 0777); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(source, "d", "g.txt"), []byte("g"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		varPerDir bool
+		want      []string
+	}{
+		{false, []string{`fs.CreateDir("a")`, `fs.CreateDir("b/c")`, `fs.CreateDir("d/e")`, `fs.CreateDir("f")`}},
+		{true, []string{`assetsB.CreateDir("c")`, `assetsD.CreateDir("e")`}},
+	}
+	for _, tt := range tests {
+		target := filepath.Join(t.TempDir(), "assets.go")
+		err := Target{Source: source, Target: target, Package: "assets", Variable: "assets", EmptyDirs: true, VarPerDir: tt.varPerDir}.generate()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(target)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Count(string(data), "CreateDir("); got != len(tt.want) {
+			t.Errorf("var-per-dir %v: got %d directories, want %d", tt.varPerDir, got, len(tt.want))
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(string(data), want) {
+				t.Errorf("var-per-dir %v: missing %s", tt.varPerDir, want)
+			}
+		}
+	}
+}
+
+func TestGenerate_formatting(t *testing.T) {
+	source := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(source, "sub"), 0777); err != nil {
+		t.Fatal(err)
+	}
+	for name, size := range map[string]int{"a.bin": 100, "sub/b.bin": 1000} {
+		data := make([]byte, size)
+		for i := range data {
+			data[i] = byte(i * i)
+		}
+		if err := os.WriteFile(filepath.Join(source, filepath.FromSlash(name)), data, 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, encoding := range []string{"hex", "base64"} {
+		target := filepath.Join(t.TempDir(), "assets.go")
+		err := Target{Source: source, Target: target, Package: "assets", Variable: "assets", Encoding: encoding, Width: 42, Comments: true, TOC: true}.generate()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(target)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range []string{
+			"// assets contains 2 files:\n//\n//\t\"a.bin\": 100 bytes\n//\t\"sub/b.bin\": 1000 bytes\n",
+			"\n\n\t// \"a.bin\": 100 bytes\n\tfs.CreateString(\"a.bin\",",
+			"\n\n\t// \"sub/b.bin\": 1000 bytes\n\tfs.CreateString(\"sub/b.bin\",",
+		} {
+			if !strings.Contains(string(data), want) {
+				t.Errorf("%s: missing %q", encoding, want)
+			}
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			if lit := strings.TrimRight(strings.TrimPrefix(line, "\t\t"), "+)"); strings.HasPrefix(lit, `"`) && len(lit) > 42 {
+				t.Errorf("%s: literal too wide: %s", encoding, lit)
+			}
+		}
+		if got := createdNames(t, target); len(got) != 2 {
+			t.Errorf("%s: got %q", encoding, got)
+		}
+	}
+
+	target := filepath.Join(t.TempDir(), "assets.go")
+	err := Target{Source: source, Target: target, Package: "assets", Variable: "assets", Width: 2}.generate()
+	if err == nil {
+		t.Error("want error")
+	}
+}
+
+func TestGenerate_reproducible(t *testing.T) {
+	source := t.TempDir()
+	for name, modtime := range map[string]time.Time{
+		"a.txt": time.Unix(1600000000, 0),
+		"b.txt": time.Unix(1650000000, 0),
+	} {
+		path := filepath.Join(source, name)
+		if err := os.WriteFile(path, []byte(strings.Repeat(name, 100)), 0666); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modtime, modtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var outputs [2][]byte
+	for i := range outputs {
+		target := filepath.Join(t.TempDir(), "assets.go")
+		err := Target{Source: source, Target: target, Package: "assets", Variable: "assets"}.generate()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if outputs[i], err = os.ReadFile(target); err != nil {
+			t.Fatal(err)
+		}
+		if i == 0 {
+			time.Sleep(1100 * time.Millisecond) // the clock must tick
+		}
+	}
+	if string(outputs[0]) != string(outputs[1]) {
+		t.Error("output differs between runs")
+	}
+	if want := `fs.SetBuildID(assetsBuildID, time.Unix(1650000000, 0))`; !strings.Contains(string(outputs[0]), want) {
+		t.Errorf("missing %s", want)
+	}
+}
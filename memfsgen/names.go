@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/ncruces/go-fs/internal/walkorder"
+)
+
+// validNames handles names that aren't valid paths (fs.ValidPath),
+// which a memfs.FileSystem can't open (e.g. names that aren't UTF-8):
+// "reject" fails, "transliterate" renames them, and by default they're warned of, and skipped.
+// Returns the valid names, and the problems.
+func validNames(names []string, handling string) (valid []string, problems []string, err error) {
+	renamed := map[string]string{}
+	for _, name := range names {
+		renamed[name] = name
+	}
+	for _, name := range names {
+		if fs.ValidPath(name) {
+			valid = append(valid, name)
+			continue
+		}
+		switch handling {
+		case "transliterate":
+			to := transliterate(name)
+			if prev, ok := renamed[to]; ok && prev != name {
+				return nil, nil, fmt.Errorf("%s: transliterated to %s, which already exists", quoteName(name), to)
+			}
+			renamed[to] = name
+			// generated files must be in fs.WalkDir order
+			if len(valid) > 0 && !walkorder.Less(valid[len(valid)-1], to) {
+				return nil, nil, fmt.Errorf("%s: transliterated to %s, which is out of order", quoteName(name), to)
+			}
+			valid = append(valid, to)
+		case "reject":
+			problems = append(problems, fmt.Sprintf("%s is not a valid path", quoteName(name)))
+		default:
+			problems = append(problems, fmt.Sprintf("%s is not a valid path, skipped", quoteName(name)))
+		}
+	}
+	return valid, problems, nil
+}
+
+// transliterate converts the bytes of name that aren't valid UTF-8 from Latin-1,
+// the usual encoding of legacy file names, so it's a valid path.
+func transliterate(name string) string {
+	if utf8.ValidString(name) {
+		return name
+	}
+	var buf strings.Builder
+	for i := 0; i < len(name); {
+		r, n := utf8.DecodeRuneInString(name[i:])
+		if r == utf8.RuneError && n == 1 {
+			r = rune(name[i])
+		}
+		buf.WriteRune(r)
+		i += n
+	}
+	return buf.String()
+}
+
+// quoteName quotes a name for a report, if it isn't valid UTF-8, or has characters that aren't printable.
+func quoteName(name string) string {
+	if !utf8.ValidString(name) || strings.ContainsFunc(name, func(r rune) bool { return !unicode.IsPrint(r) }) {
+		return strconv.Quote(name)
+	}
+	return name
+}
+
+// portability returns the problems of file names that break checkouts on other systems:
+// names in the same directory that differ only by case (on case-insensitive file systems),
+// and names that are reserved, or invalid, on Windows.
+// Names are slash separated paths, relative to the source directory.
+func portability(names []string) []string {
+	var problems []string
+	folded := map[string]string{}
+	for _, name := range names {
+		dir, base := path.Split(name)
+		key := dir + strings.ToLower(base)
+		if prev, ok := folded[key]; ok {
+			problems = append(problems, fmt.Sprintf("%s and %s differ only by case", quoteName(prev), quoteName(name)))
+		} else {
+			folded[key] = name
+		}
+		if reason := windowsName(base); reason != "" {
+			problems = append(problems, fmt.Sprintf("%s %s on Windows", quoteName(name), reason))
+		}
+	}
+	return problems
+}
+
+// windowsName returns why a file name is not valid on Windows, or empty if it is.
+func windowsName(name string) string {
+	if i := strings.IndexAny(name, `<>:"\|?*`); i >= 0 {
+		return fmt.Sprintf("has reserved character %q", name[i])
+	}
+	if strings.ContainsFunc(name, func(r rune) bool { return r < ' ' }) {
+		return "has control characters"
+	}
+	if strings.HasSuffix(name, ".") || strings.HasSuffix(name, " ") {
+		return "ends in a dot or space"
+	}
+	// reserved device names, also with an extension (aux.txt)
+	stem, _, _ := strings.Cut(name, ".")
+	switch stem = strings.TrimRight(strings.ToUpper(stem), " "); stem {
+	case "CON", "PRN", "AUX", "NUL":
+		return "is a reserved name"
+	}
+	if len(stem) == 4 && (strings.HasPrefix(stem, "COM") || strings.HasPrefix(stem, "LPT")) &&
+		'0' <= stem[3] && stem[3] <= '9' {
+		return "is a reserved name"
+	}
+	return ""
+}
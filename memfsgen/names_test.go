@@ -0,0 +1,183 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestGenerate_names(t *testing.T) {
+	names := []string{
+		"café.txt",
+		"日本語/ファイル.txt",
+		"emoji 🎉.txt",
+		"new\nline.txt",
+		"tab\tand\x7fdel.txt",
+		"line\u2028separator.txt",
+		"quote\"and`backtick`.txt",
+		"back\\slash.txt",
+		"latin1\xe9.txt",
+	}
+
+	source := t.TempDir()
+	for _, name := range names {
+		path := filepath.Join(source, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(strings.Repeat(name, 10)), 0666); err != nil {
+			t.Skipf("file system doesn't support %q: %v", name, err)
+		}
+	}
+
+	tests := []struct {
+		names string
+		want  string // the name of latin1\xe9.txt
+	}{
+		{"", ""},
+		{"transliterate", "latin1é.txt"},
+	}
+	for _, tt := range tests {
+		target := filepath.Join(t.TempDir(), "assets.go")
+		err := Target{
+			Source:    source,
+			Target:    target,
+			Package:   "assets",
+			Variable:  "assets",
+			ETags:     true,
+			Accessors: true,
+			Names:     tt.names,
+		}.generate()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var want []string
+		for _, name := range names {
+			if name == "latin1\xe9.txt" {
+				name = tt.want
+			}
+			if name != "" {
+				want = append(want, name)
+			}
+		}
+		got := createdNames(t, target)
+		sort.Strings(want)
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("names: %q, got %q, want %q", tt.names, got, want)
+		}
+	}
+
+	target := filepath.Join(t.TempDir(), "assets.go")
+	err := Target{Source: source, Target: target, Package: "assets", Variable: "assets", Names: "reject"}.generate()
+	if err == nil || !strings.Contains(err.Error(), `"latin1\xe9.txt" is not a valid path`) {
+		t.Errorf("got %v", err)
+	}
+}
+
+// createdNames parses a generated file, and returns the names of the files it creates.
+func createdNames(t *testing.T, target string) []string {
+	f, err := parser.ParseFile(token.NewFileSet(), target, nil, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	ast.Inspect(f, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		if sel, ok := call.Fun.(*ast.SelectorExpr); ok && sel.Sel.Name == "CreateString" {
+			name, err := strconv.Unquote(call.Args[0].(*ast.BasicLit).Value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			names = append(names, name)
+		}
+		return true
+	})
+	return names
+}
+
+func TestEmbedPattern(t *testing.T) {
+	tests := map[string]string{
+		"static":       "all:static",
+		"my static":    `"all:my static"`,
+		"café":         "all:café",
+		"a\"b":         `"all:a\"b"`,
+		"new\nline":    `"all:new\nline"`,
+		"back`tick":    "\"all:back`tick\"",
+		"sep\u2028dir": `"all:sep\u2028dir"`,
+		"日本語/ファイル":     "all:日本語/ファイル",
+	}
+	for dir, want := range tests {
+		if got := embedPattern(dir); got != want {
+			t.Errorf("embedPattern(%q) = %s, want %s", dir, got, want)
+		}
+	}
+}
+
+func TestTransliterate(t *testing.T) {
+	tests := map[string]string{
+		"plain.txt":     "plain.txt",
+		"café.txt":      "café.txt",
+		"caf\xe9.txt":   "café.txt",
+		"\xff\xfe.txt":  "ÿþ.txt",
+		"mixed é \xe9":  "mixed é é",
+		"日本語\x80/a.txt": "日本語\u0080/a.txt",
+	}
+	for name, want := range tests {
+		if got := transliterate(name); got != want {
+			t.Errorf("transliterate(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestValidNames(t *testing.T) {
+	_, _, err := validNames([]string{"caf\xe9.txt", "café.txt"}, "transliterate")
+	if err == nil {
+		t.Error("want error")
+	}
+
+	// ñ (0xc3 0xb1 in UTF-8) sorts before the Latin-1 é (0xe9), but after é transliterated (0xc3 0xa9)
+	_, _, err = validNames([]string{"caf\xc3\xb1.txt", "caf\xe9.txt"}, "transliterate")
+	if err == nil || !strings.Contains(err.Error(), "out of order") {
+		t.Errorf("got %v", err)
+	}
+
+	valid, problems, err := validNames([]string{"a.txt", "b\xff.txt"}, "")
+	if err != nil || !reflect.DeepEqual(valid, []string{"a.txt"}) || len(problems) != 1 {
+		t.Errorf("got %q, %q, %v", valid, problems, err)
+	}
+}
+
+func TestPortability(t *testing.T) {
+	got := portability([]string{
+		"A.txt", "a.txt", "b.txt",
+		"Docs", "Docs/x.txt", "docs", "docs/x.txt",
+		"aux", "con.txt", "com1.js", "com10.js", "console.log",
+		"a:b", "trailing.", "ctrl\x01",
+	})
+	want := []string{
+		"A.txt and a.txt differ only by case",
+		"Docs and docs differ only by case",
+		"aux is a reserved name on Windows",
+		"con.txt is a reserved name on Windows",
+		"com1.js is a reserved name on Windows",
+		`a:b has reserved character ':' on Windows`,
+		"trailing. ends in a dot or space on Windows",
+		`"ctrl\x01" has control characters on Windows`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+)
+
+// writeReport writes a summary of the assets generated for target:
+// the raw and stored size of each, whether compression was kept,
+// and the largest assets.
+func writeReport(w io.Writer, target string, assets []Asset) {
+	var size, stored int
+	fmt.Fprintf(w, "%s:\n", target)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tSIZE\tSTORED\tRATIO\tCOMPRESSED")
+	for _, a := range assets {
+		size += a.Size
+		stored += a.Stored
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%s\t%s\n", a.Path, a.Size, a.Stored, ratio(a.Size, a.Stored), compressed(a))
+	}
+	fmt.Fprintf(tw, "TOTAL (%d files)\t%d\t%d\t%s\t\n", len(assets), size, stored, ratio(size, stored))
+	tw.Flush()
+
+	largest := append([]Asset(nil), assets...)
+	sort.SliceStable(largest, func(i, j int) bool { return largest[i].Stored > largest[j].Stored })
+	if len(largest) > 10 {
+		largest = largest[:10]
+	}
+	fmt.Fprintln(w, "Largest (stored size):")
+	for i, a := range largest {
+		fmt.Fprintf(w, "%3d. %s (%d bytes)\n", i+1, a.Path, a.Stored)
+	}
+	fmt.Fprintln(w)
+}
+
+func ratio(size, stored int) string {
+	if stored == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.2f", float64(size)/float64(stored))
+}
+
+func compressed(a Asset) string {
+	switch {
+	case a.Dup:
+		return "duplicate"
+	case a.Stored != a.Size:
+		return "yes"
+	default:
+		return "no"
+	}
+}
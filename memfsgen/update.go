@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"regexp"
+	"strconv"
+)
+
+// An asset generated by a previous run, by variable and name.
+type prevKey struct{ fs, name string }
+
+type prevAsset struct {
+	time int64
+	hash uint32
+	size int
+}
+
+// The build ID and time generated by a previous run.
+type prevBuild struct {
+	id   string
+	time int64
+}
+
+var buildID = regexp.MustCompile(`^const \w+BuildID = "([0-9a-f]*)"$`)
+var setBuildID = regexp.MustCompile(`^\s*\w+\.SetBuildID\(\w+, time\.Unix\((-?\d+), 0\)\)$`)
+
+var createString = regexp.MustCompile(`^\s*(\w+)\.CreateString\(("(?:[^"\\]|\\.)*"), "(?:[^"\\]|\\.)*", time\.Unix\((-?\d+), 0\), (0x[0-9a-f]+), (\d+),`)
+
+// previous reads the assets and build generated by a previous run into target.
+// A missing target has no assets.
+func previous(target string) (map[prevKey]prevAsset, prevBuild, error) {
+	var build prevBuild
+	f, err := os.Open(target)
+	if os.IsNotExist(err) {
+		return nil, build, nil
+	}
+	if err != nil {
+		return nil, build, err
+	}
+	defer f.Close()
+
+	prev := map[prevKey]prevAsset{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if m := buildID.FindStringSubmatch(line); m != nil {
+			build.id = m[1]
+			continue
+		}
+		if m := setBuildID.FindStringSubmatch(line); m != nil {
+			build.time, _ = strconv.ParseInt(m[1], 10, 64)
+			continue
+		}
+		m := createString.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		name, err := strconv.Unquote(m[2])
+		if err != nil {
+			continue
+		}
+		time, err1 := strconv.ParseInt(m[3], 10, 64)
+		hash, err2 := strconv.ParseUint(m[4], 0, 32)
+		size, err3 := strconv.Atoi(m[5])
+		if err1 != nil || err2 != nil || err3 != nil {
+			continue
+		}
+		prev[prevKey{m[1], name}] = prevAsset{time, uint32(hash), size}
+	}
+	return prev, build, scanner.Err()
+}
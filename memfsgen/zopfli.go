@@ -0,0 +1,757 @@
+package main
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"math"
+	"math/bits"
+	"sort"
+	"time"
+)
+
+// A DEFLATE encoder modeled on Zopfli (https://github.com/google/zopfli), used by -zopfli.
+// It spends much more time than compress/flate looking for a small encoding:
+// every match is considered at every position, and an optimal parse is iterated,
+// with the costs of symbols taken from the statistics of the previous parse.
+// Blocks are split where their Huffman codes are cheaper apart.
+// The output is standard DEFLATE, decoded by any inflater.
+
+const (
+	zopfliWindow     = 32768   // largest distance
+	zopfliMinMatch   = 3       // shortest match
+	zopfliMaxMatch   = 258     // longest match
+	zopfliMaxChain   = 8192    // candidate matches searched at each position
+	zopfliMaster     = 1000000 // bytes split into blocks at a time
+	zopfliMaxBlocks  = 15      // blocks a master block is split into
+	zopfliIterations = 15      // optimal parses of each block
+)
+
+// zopfliGzip compresses data into a gzip member, like compress/gzip at gzip.BestCompression.
+func zopfliGzip(data []byte, modtime time.Time) []byte {
+	buf := []byte{0x1f, 0x8b, 8, 0, 0, 0, 0, 0, 2, 255}
+	if modtime.After(time.Unix(0, 0)) {
+		binary.LittleEndian.PutUint32(buf[4:], uint32(modtime.Unix()))
+	}
+	buf = append(buf, zopfliDeflate(data)...)
+	buf = binary.LittleEndian.AppendUint32(buf, crc32.ChecksumIEEE(data))
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(data)))
+	return buf
+}
+
+// zopfliDeflate compresses data into a DEFLATE stream.
+func zopfliDeflate(data []byte) []byte {
+	var w bitWriter
+	if len(data) == 0 {
+		writeBlock(&w, nil, true)
+	}
+	m := newMatcher(data)
+	for start := 0; start < len(data); start += zopfliMaster {
+		end := start + zopfliMaster
+		if end > len(data) {
+			end = len(data)
+		}
+		p := m.parse(start, end)
+
+		// split the greedy parse into blocks, then parse each optimally
+		greedy := p.greedy()
+		splits := splitBlocks(greedy)
+		pos, from := start, 0
+		for i := 0; i <= len(splits); i++ {
+			to := len(greedy)
+			if i < len(splits) {
+				to = splits[i]
+			}
+			end := pos
+			for _, s := range greedy[from:to] {
+				end += s.size()
+			}
+			syms := p.optimal(pos, end, greedy[from:to])
+			writeBlock(&w, syms, end == len(data))
+			pos, from = end, to
+		}
+	}
+	return w.flush()
+}
+
+// An LZ77 symbol: a literal byte if dist is zero, or a match of length bytes, dist bytes back.
+type lzSymbol struct {
+	length uint16
+	dist   uint16
+}
+
+// size returns the number of bytes encoded by s.
+func (s lzSymbol) size() int {
+	if s.dist == 0 {
+		return 1
+	}
+	return int(s.length)
+}
+
+// A match, of up to length bytes, dist bytes back.
+type lzMatch struct {
+	length uint16
+	dist   uint16
+}
+
+// A matcher finds matches with hash chains.
+type matcher struct {
+	data []byte
+	head []int // last position of each hash
+	prev []int // previous position with the same hash, by position in the window
+}
+
+func newMatcher(data []byte) *matcher {
+	m := &matcher{data: data, head: make([]int, 1<<16), prev: make([]int, zopfliWindow)}
+	for i := range m.head {
+		m.head[i] = -1
+	}
+	return m
+}
+
+func (m *matcher) hash(i int) int {
+	d := m.data[i : i+3]
+	return int((uint32(d[0])<<16 | uint32(d[1])<<8 | uint32(d[2])) * 2654435761 >> 16)
+}
+
+// find appends the matches at position i (at most max bytes long) to out,
+// and inserts i into the hash chains.
+// For each length, the match with the smallest distance is found:
+// out lists the distances at which longer matches start.
+func (m *matcher) find(i, max int, out []lzMatch) []lzMatch {
+	if i+zopfliMinMatch > len(m.data) {
+		return out
+	}
+	h := m.hash(i)
+	if max > zopfliMaxMatch {
+		max = zopfliMaxMatch
+	}
+	if max >= zopfliMinMatch {
+		data := m.data
+		best := zopfliMinMatch - 1
+		for p, hits := m.head[h], 0; p >= 0 && i-p <= zopfliWindow && hits < zopfliMaxChain; hits++ {
+			if data[p+best] == data[i+best] {
+				l := 0
+				for l < max && data[p+l] == data[i+l] {
+					l++
+				}
+				if l > best {
+					out = append(out, lzMatch{uint16(l), uint16(i - p)})
+					if best = l; l == max {
+						break
+					}
+				}
+			}
+			next := m.prev[p%zopfliWindow]
+			if next >= p {
+				break
+			}
+			p = next
+		}
+	}
+	m.prev[i%zopfliWindow] = m.head[h]
+	m.head[h] = i
+	return out
+}
+
+// An lzParser parses a master block, from the matches found at each position.
+type lzParser struct {
+	data    []byte
+	start   int
+	offsets []int32 // matches at position start+i are matches[offsets[i]:offsets[i+1]]
+	matches []lzMatch
+}
+
+// parse finds the matches in data[start:end].
+func (m *matcher) parse(start, end int) *lzParser {
+	p := &lzParser{data: m.data, start: start, offsets: make([]int32, 0, end-start+1)}
+	for i := start; i < end; i++ {
+		p.offsets = append(p.offsets, int32(len(p.matches)))
+		p.matches = m.find(i, end-i, p.matches)
+	}
+	p.offsets = append(p.offsets, int32(len(p.matches)))
+	return p
+}
+
+// at returns the matches at position i.
+func (p *lzParser) at(i int) []lzMatch {
+	i -= p.start
+	return p.matches[p.offsets[i]:p.offsets[i+1]]
+}
+
+// longest returns the longest match at position i.
+func (p *lzParser) longest(i int) lzMatch {
+	if ms := p.at(i); len(ms) > 0 {
+		return ms[len(ms)-1]
+	}
+	return lzMatch{}
+}
+
+// greedy parses the master block with lazy matching.
+func (p *lzParser) greedy() []lzSymbol {
+	var syms []lzSymbol
+	end := p.start + len(p.offsets) - 1
+	for i := p.start; i < end; {
+		m := p.longest(i)
+		if m.length < zopfliMinMatch || i+1 < end && p.longest(i+1).length > m.length {
+			syms = append(syms, lzSymbol{uint16(p.data[i]), 0})
+			i++
+			continue
+		}
+		syms = append(syms, lzSymbol(m))
+		i += int(m.length)
+	}
+	return syms
+}
+
+// optimal parses data[start:end] with the lowest cost, iterating on the statistics of the previous parse,
+// starting with those of initial.
+func (p *lzParser) optimal(start, end int, initial []lzSymbol) []lzSymbol {
+	var h histogram
+	h.add(initial)
+	best, bestSize := initial, h.size()
+
+	n := end - start
+	cost := make([]float64, n+1)
+	from := make([]lzSymbol, n+1)
+	var prev []lzSymbol
+	for iter := 0; iter < zopfliIterations; iter++ {
+		c := h.costs()
+		for i := range cost {
+			cost[i] = math.Inf(1)
+		}
+		cost[0] = 0
+
+		for i := 0; i < n; i++ {
+			ci := cost[i]
+			b := p.data[start+i]
+			if v := ci + c.ll[b]; v < cost[i+1] {
+				cost[i+1], from[i+1] = v, lzSymbol{uint16(b), 0}
+			}
+			k := zopfliMinMatch
+			for _, m := range p.at(start + i) {
+				l := int(m.length)
+				if l > n-i {
+					l = n - i
+				}
+				d := c.distance(m.dist)
+				for ; k <= l; k++ {
+					if v := ci + d + c.length[k]; v < cost[i+k] {
+						cost[i+k], from[i+k] = v, lzSymbol{uint16(k), m.dist}
+					}
+				}
+			}
+		}
+
+		var syms []lzSymbol
+		for i := n; i > 0; i -= from[i].size() {
+			syms = append(syms, from[i])
+		}
+		for i, j := 0, len(syms)-1; i < j; i, j = i+1, j-1 {
+			syms[i], syms[j] = syms[j], syms[i]
+		}
+
+		h = histogram{}
+		h.add(syms)
+		if size := h.size(); size < bestSize {
+			best, bestSize = syms, size
+		}
+		if equalSymbols(syms, prev) {
+			break // converged
+		}
+		prev = syms
+	}
+	return best
+}
+
+func equalSymbols(a, b []lzSymbol) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// splitBlocks returns the positions at which syms should be split into blocks,
+// for the smallest estimated size.
+func splitBlocks(syms []lzSymbol) []int {
+	var splits []int
+	var split func(lo, hi int)
+	split = func(lo, hi int) {
+		if hi-lo < 10 || len(splits)+1 >= zopfliMaxBlocks {
+			return
+		}
+		cost := func(lo, hi int) int {
+			var h histogram
+			h.add(syms[lo:hi])
+			return h.size()
+		}
+		pos := findMinimum(func(i int) int { return cost(lo, i) + cost(i, hi) }, lo+1, hi)
+		if pos == lo+1 || pos == hi || cost(lo, pos)+cost(pos, hi) >= cost(lo, hi) {
+			return
+		}
+		splits = append(splits, pos)
+		split(lo, pos)
+		split(pos, hi)
+	}
+	split(0, len(syms))
+	sort.Ints(splits)
+	return splits
+}
+
+// findMinimum finds the position in [start, end) that minimizes f,
+// searching exhaustively small ranges, or narrowing down on samples of large ones.
+func findMinimum(f func(int) int, start, end int) int {
+	const samples = 9
+	if end-start < 1024 {
+		best, pos := math.MaxInt, start
+		for i := start; i < end; i++ {
+			if v := f(i); v < best {
+				best, pos = v, i
+			}
+		}
+		return pos
+	}
+
+	last, pos := math.MaxInt, start
+	for end-start > samples {
+		var p [samples]int
+		var v [samples]int
+		besti := 0
+		for i := range p {
+			p[i] = start + (i+1)*((end-start)/(samples+1))
+			v[i] = f(p[i])
+			if v[i] < v[besti] {
+				besti = i
+			}
+		}
+		if v[besti] > last {
+			break
+		}
+		if besti > 0 {
+			start = p[besti-1]
+		}
+		if besti < samples-1 {
+			end = p[besti+1]
+		}
+		pos, last = p[besti], v[besti]
+	}
+	return pos
+}
+
+// Tables of the length and distance codes of DEFLATE.
+var (
+	lengthCode  [zopfliMaxMatch + 1]uint16 // literal/length symbol, by length
+	lengthBase  = [29]uint16{3, 4, 5, 6, 7, 8, 9, 10, 11, 13, 15, 17, 19, 23, 27, 31, 35, 43, 51, 59, 67, 83, 99, 115, 131, 163, 195, 227, 258}
+	lengthExtra = [29]uint8{0, 0, 0, 0, 0, 0, 0, 0, 1, 1, 1, 1, 2, 2, 2, 2, 3, 3, 3, 3, 4, 4, 4, 4, 5, 5, 5, 5, 0}
+	distBase    = [30]uint16{1, 2, 3, 4, 5, 7, 9, 13, 17, 25, 33, 49, 65, 97, 129, 193, 257, 385, 513, 769, 1025, 1537, 2049, 3073, 4097, 6145, 8193, 12289, 16385, 24577}
+
+	// the order in which the lengths of the code length code are sent
+	codeLengthOrder = [19]uint8{16, 17, 18, 0, 8, 7, 9, 6, 10, 5, 11, 4, 12, 3, 13, 2, 14, 1, 15}
+)
+
+func init() {
+	for c, base := range lengthBase {
+		for l := int(base); l < int(base)+1<<lengthExtra[c] && l <= zopfliMaxMatch; l++ {
+			lengthCode[l] = uint16(257 + c)
+		}
+	}
+}
+
+// distCode returns the distance symbol of dist.
+func distCode(dist uint16) int {
+	if dist <= 4 {
+		return int(dist) - 1
+	}
+	d := uint(dist - 1)
+	n := bits.Len(d)
+	return 2*(n-1) + int(d>>(n-2)&1)
+}
+
+func distExtra(code int) uint {
+	if code < 4 {
+		return 0
+	}
+	return uint(code/2 - 1)
+}
+
+// A histogram of the symbols of a block.
+type histogram struct {
+	ll   [286]int // literal/length symbols
+	dist [30]int  // distance symbols
+}
+
+func (h *histogram) add(syms []lzSymbol) {
+	h.ll[256] = 1 // end of block
+	for _, s := range syms {
+		if s.dist == 0 {
+			h.ll[s.length]++
+		} else {
+			h.ll[lengthCode[s.length]]++
+			h.dist[distCode(s.dist)]++
+		}
+	}
+}
+
+// extra returns the number of extra bits of the lengths and distances.
+func (h *histogram) extra() int {
+	n := 0
+	for c, e := range lengthExtra {
+		n += h.ll[257+c] * int(e)
+	}
+	for c, k := range h.dist {
+		n += k * int(distExtra(c))
+	}
+	return n
+}
+
+// size returns the size in bits of a block with these symbols.
+func (h *histogram) size() int {
+	fixed := 3 + h.extra()
+	for s, k := range h.ll {
+		fixed += k * int(fixedLength(s))
+	}
+	for _, k := range h.dist {
+		fixed += k * 5
+	}
+	if dynamic := h.tree().size + h.extra(); dynamic < fixed {
+		return dynamic
+	}
+	return fixed
+}
+
+// fixedLength returns the length of a literal/length symbol in the fixed Huffman code.
+func fixedLength(sym int) uint8 {
+	switch {
+	case sym < 144:
+		return 8
+	case sym < 256:
+		return 9
+	case sym < 280:
+		return 7
+	default:
+		return 8
+	}
+}
+
+// The costs of symbols, in bits, for the optimal parse.
+type costModel struct {
+	ll     [286]float64
+	dist   [30]float64
+	length [zopfliMaxMatch + 1]float64 // cost of each length, with its extra bits
+}
+
+// costs estimates the cost of each symbol from its frequency.
+func (h *histogram) costs() *costModel {
+	c := new(costModel)
+	entropy(h.ll[:], c.ll[:])
+	entropy(h.dist[:], c.dist[:])
+	for l := zopfliMinMatch; l <= zopfliMaxMatch; l++ {
+		code := lengthCode[l]
+		c.length[l] = c.ll[code] + float64(lengthExtra[code-257])
+	}
+	return c
+}
+
+// distance returns the cost of a distance, with its extra bits.
+func (c *costModel) distance(dist uint16) float64 {
+	code := distCode(dist)
+	return c.dist[code] + float64(distExtra(code))
+}
+
+// entropy sets the cost of each symbol to -log2 of its probability.
+// Unused symbols cost as much as those used once.
+func entropy(counts []int, costs []float64) {
+	total := 0
+	for _, k := range counts {
+		total += k
+	}
+	if total == 0 {
+		total = 1
+	}
+	log := math.Log2(float64(total))
+	for s, k := range counts {
+		if k == 0 {
+			k = 1
+		}
+		costs[s] = log - math.Log2(float64(k))
+	}
+}
+
+// The Huffman codes of a dynamic block.
+type dynamicTree struct {
+	ll, dist   []uint8 // code lengths, trimmed to HLIT and HDIST
+	codeLength [19]uint8
+	hclen      int
+	rle        []lzSymbol // run length encoded code lengths: length is the symbol, dist the extra bits
+	size       int        // size of the header and symbols, in bits, without extra bits
+}
+
+func (h *histogram) tree() dynamicTree {
+	var t dynamicTree
+	ll := huffmanLengths(h.ll[:], 15)
+	dist := huffmanLengths(h.dist[:], 15)
+	if usedSymbols(dist) == 0 {
+		dist[0] = 1 // no distances: one unused code
+	}
+	hlit, hdist := 286, 30
+	for hlit > 257 && ll[hlit-1] == 0 {
+		hlit--
+	}
+	for hdist > 1 && dist[hdist-1] == 0 {
+		hdist--
+	}
+	t.ll, t.dist = ll[:hlit], dist[:hdist]
+
+	// run length encode the code lengths
+	lens := append(append([]uint8(nil), t.ll...), t.dist...)
+	for i := 0; i < len(lens); {
+		l := lens[i]
+		run := 1
+		for i+run < len(lens) && lens[i+run] == l {
+			run++
+		}
+		if l == 0 && run >= 3 {
+			n := run
+			if n > 138 {
+				n = 138
+			}
+			if n >= 11 {
+				t.rle = append(t.rle, lzSymbol{18, uint16(n - 11)})
+			} else {
+				t.rle = append(t.rle, lzSymbol{17, uint16(n - 3)})
+			}
+			i += n
+			continue
+		}
+		t.rle = append(t.rle, lzSymbol{uint16(l), 0})
+		i, run = i+1, run-1
+		for l != 0 && run >= 3 {
+			n := run
+			if n > 6 {
+				n = 6
+			}
+			t.rle = append(t.rle, lzSymbol{16, uint16(n - 3)})
+			i, run = i+n, run-n
+		}
+	}
+
+	var counts [19]int
+	for _, s := range t.rle {
+		counts[s.length]++
+	}
+	copy(t.codeLength[:], huffmanLengths(counts[:], 7))
+	t.hclen = 19
+	for t.hclen > 4 && t.codeLength[codeLengthOrder[t.hclen-1]] == 0 {
+		t.hclen--
+	}
+
+	t.size = 3 + 5 + 5 + 4 + 3*t.hclen
+	for _, s := range t.rle {
+		t.size += int(t.codeLength[s.length]) + int(rleExtra(s.length))
+	}
+	for s, l := range t.ll {
+		t.size += h.ll[s] * int(l)
+	}
+	for s, l := range t.dist {
+		t.size += h.dist[s] * int(l)
+	}
+	return t
+}
+
+// rleExtra returns the number of extra bits of a code length symbol.
+func rleExtra(sym uint16) uint {
+	switch sym {
+	case 16:
+		return 2
+	case 17:
+		return 3
+	case 18:
+		return 7
+	}
+	return 0
+}
+
+func usedSymbols(lengths []uint8) int {
+	n := 0
+	for _, l := range lengths {
+		if l != 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// huffmanLengths returns the code lengths of a Huffman code for counts,
+// limited to maxBits, using the package-merge algorithm.
+func huffmanLengths(counts []int, maxBits int) []uint8 {
+	lengths := make([]uint8, len(counts))
+
+	type leaf struct{ count, sym int }
+	var leaves []leaf
+	for s, k := range counts {
+		if k > 0 {
+			leaves = append(leaves, leaf{k, s})
+		}
+	}
+	switch len(leaves) {
+	case 0:
+		return lengths
+	case 1:
+		lengths[leaves[0].sym] = 1
+		return lengths
+	}
+	sort.Slice(leaves, func(i, j int) bool {
+		if leaves[i].count != leaves[j].count {
+			return leaves[i].count < leaves[j].count
+		}
+		return leaves[i].sym < leaves[j].sym
+	})
+
+	// an item is a leaf, or a package of two items of the previous list
+	type item struct {
+		weight int
+		leaf   int
+		a, b   int
+	}
+	lists := make([][]item, maxBits)
+	for i, l := range leaves {
+		lists[0] = append(lists[0], item{l.count, i, 0, 0})
+	}
+	for j := 1; j < maxBits; j++ {
+		prev := lists[j-1]
+		list := make([]item, 0, len(leaves)+len(prev)/2)
+		for li, pi := 0, 0; li < len(leaves) || pi+1 < len(prev); {
+			if pi+1 < len(prev) && (li == len(leaves) || prev[pi].weight+prev[pi+1].weight < leaves[li].count) {
+				list = append(list, item{prev[pi].weight + prev[pi+1].weight, -1, pi, pi + 1})
+				pi += 2
+			} else {
+				list = append(list, item{leaves[li].count, li, 0, 0})
+				li++
+			}
+		}
+		lists[j] = list
+	}
+
+	// each time a leaf is selected, its code gets one bit longer
+	var count func(j, i int)
+	count = func(j, i int) {
+		it := lists[j][i]
+		if it.leaf >= 0 {
+			lengths[leaves[it.leaf].sym]++
+		} else {
+			count(j-1, it.a)
+			count(j-1, it.b)
+		}
+	}
+	for i := 0; i < 2*len(leaves)-2; i++ {
+		count(maxBits-1, i)
+	}
+	return lengths
+}
+
+// huffmanCodes returns the canonical Huffman codes for lengths, bit reversed for writing.
+func huffmanCodes(lengths []uint8) []uint16 {
+	var count, next [16]uint16
+	for _, l := range lengths {
+		count[l]++
+	}
+	count[0] = 0
+	code := uint16(0)
+	for b := 1; b < 16; b++ {
+		code = (code + count[b-1]) << 1
+		next[b] = code
+	}
+	codes := make([]uint16, len(lengths))
+	for s, l := range lengths {
+		if l != 0 {
+			codes[s] = bits.Reverse16(next[l]) >> (16 - l)
+			next[l]++
+		}
+	}
+	return codes
+}
+
+// writeBlock writes syms as a block, with fixed or dynamic Huffman codes, whichever is smaller.
+func writeBlock(w *bitWriter, syms []lzSymbol, final bool) {
+	var h histogram
+	h.add(syms)
+	t := h.tree()
+
+	var ll, dist []uint8
+	if h.size() < t.size+h.extra() {
+		ll, dist = make([]uint8, 288), make([]uint8, 30)
+		for s := range ll {
+			ll[s] = fixedLength(s)
+		}
+		for s := range dist {
+			dist[s] = 5
+		}
+		w.write(b2u(final)|1<<1, 3)
+	} else {
+		ll, dist = t.ll, t.dist
+		w.write(b2u(final)|2<<1, 3)
+		w.write(uint32(len(t.ll)-257), 5)
+		w.write(uint32(len(t.dist)-1), 5)
+		w.write(uint32(t.hclen-4), 4)
+		for _, s := range codeLengthOrder[:t.hclen] {
+			w.write(uint32(t.codeLength[s]), 3)
+		}
+		codes := huffmanCodes(t.codeLength[:])
+		for _, s := range t.rle {
+			w.write(uint32(codes[s.length]), uint(t.codeLength[s.length]))
+			w.write(uint32(s.dist), rleExtra(s.length))
+		}
+	}
+
+	llCodes, distCodes := huffmanCodes(ll), huffmanCodes(dist)
+	for _, s := range syms {
+		if s.dist == 0 {
+			w.write(uint32(llCodes[s.length]), uint(ll[s.length]))
+			continue
+		}
+		lc := int(lengthCode[s.length])
+		w.write(uint32(llCodes[lc]), uint(ll[lc]))
+		w.write(uint32(s.length-lengthBase[lc-257]), uint(lengthExtra[lc-257]))
+		dc := distCode(s.dist)
+		w.write(uint32(distCodes[dc]), uint(dist[dc]))
+		w.write(uint32(s.dist-distBase[dc]), distExtra(dc))
+	}
+	w.write(uint32(llCodes[256]), uint(ll[256]))
+}
+
+func b2u(b bool) uint32 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// A bitWriter writes bits, least significant first.
+type bitWriter struct {
+	buf  []byte
+	bits uint64
+	n    uint
+}
+
+func (w *bitWriter) write(v uint32, n uint) {
+	w.bits |= uint64(v) << w.n
+	w.n += n
+	for w.n >= 8 {
+		w.buf = append(w.buf, byte(w.bits))
+		w.bits >>= 8
+		w.n -= 8
+	}
+}
+
+// flush pads the last byte, and returns the bytes written.
+func (w *bitWriter) flush() []byte {
+	if w.n > 0 {
+		w.buf = append(w.buf, byte(w.bits))
+		w.bits, w.n = 0, 0
+	}
+	return w.buf
+}
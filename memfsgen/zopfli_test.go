@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"math/rand"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestZopfliGzip(t *testing.T) {
+	text, err := os.ReadFile("main.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	random := make([]byte, 100000)
+	rand.New(rand.NewSource(1)).Read(random)
+
+	tests := map[string][]byte{
+		"empty":    nil,
+		"byte":     {42},
+		"short":    []byte("hello, hello, hello"),
+		"text":     text,
+		"random":   random,
+		"zeros":    make([]byte, 300000),
+		"repeated": []byte(strings.Repeat("abcdefghij", 10000)),
+		"mixed":    append(append(append([]byte{}, text...), random[:5000]...), text...),
+	}
+	modtime := time.Unix(1700000000, 0)
+	for name, data := range tests {
+		got := zopfliGzip(data, modtime)
+
+		r, err := gzip.NewReader(bytes.NewReader(got))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !r.ModTime.Equal(modtime) {
+			t.Errorf("%s: got time %v, want %v", name, r.ModTime, modtime)
+		}
+		out, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !bytes.Equal(out, data) {
+			t.Errorf("%s: round trip failed", name)
+		}
+
+		var buf bytes.Buffer
+		w, _ := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+		w.Write(data)
+		w.Close()
+		if len(data) > 1000 && name != "random" && len(got) > buf.Len() {
+			t.Errorf("%s: got %d bytes, want at most %d (gzip)", name, len(got), buf.Len())
+		}
+		t.Logf("%s: %d bytes, zopfli %d, gzip %d", name, len(data), len(got), buf.Len())
+	}
+}
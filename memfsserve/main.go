@@ -0,0 +1,93 @@
+// A command to serve a directory with memfs.
+package main
+
+import (
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ncruces/go-fs/memfs"
+)
+
+func main() {
+	addr := flag.String("addr", "localhost:8080", "address to listen on")
+	level := flag.Int("level", gzip.BestCompression, "gzip compression level (0 disables compression)")
+	reload := flag.Bool("watch", false, "reload files as they change (directories only)")
+	logs := flag.Bool("log", false, "log requests")
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		usage()
+	}
+
+	source := flag.Arg(0)
+	fsys, err := load(source, *level, *reload)
+	if err != nil {
+		fatal("source %s: %v", source, err)
+	}
+
+	if *logs {
+		fsys.Hook(memfs.Hooks{
+			OnDone: func(r *http.Request, status int, written int64) {
+				log.Printf("%s %s %d %d", r.Method, r.URL, status, written)
+			},
+		})
+	}
+
+	log.Printf("serving %s on http://%s/", source, *addr)
+	log.Fatal(http.ListenAndServe(*addr, fsys))
+}
+
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [options] <source-dir|source-zip>\n", filepath.Base(os.Args[0]))
+	flag.PrintDefaults()
+	os.Exit(2)
+}
+
+func fatal(format string, a ...interface{}) {
+	fmt.Fprintf(flag.CommandLine.Output(), format+"\n", a...)
+	os.Exit(2)
+}
+
+// load loads source, a directory or zip file, compressed with level.
+// If reload is true, a directory is reloaded as files change.
+func load(source string, level int, reload bool) (*memfs.FileSystem, error) {
+	s, err := os.Stat(source)
+	if err != nil {
+		return nil, err
+	}
+
+	var in fs.FS
+	switch {
+	case s.IsDir() && reload:
+		return watch(source, level)
+
+	case s.IsDir():
+		in = os.DirFS(source)
+
+	case strings.EqualFold(filepath.Ext(source), ".zip"):
+		if reload {
+			return nil, errors.New("can't watch a zip file")
+		}
+		archive, err := zip.OpenReader(source)
+		if err != nil {
+			return nil, err
+		}
+		defer archive.Close()
+		in = archive
+
+	default:
+		return nil, errors.New("not a directory or zip file")
+	}
+
+	return memfs.LoadCompressed(in, level)
+}
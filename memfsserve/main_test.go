@@ -0,0 +1,155 @@
+package main
+
+import (
+	"archive/zip"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var files = map[string]string{
+	"index.html":      strings.Repeat("<p>Hello, world!</p>\n", 100),
+	"404.html":        "<p>Not found!</p>",
+	"css/site.css":    "p { color: red; }",
+	"docs/index.html": "<p>Docs</p>",
+}
+
+func TestLoad_dir(t *testing.T) {
+	source := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(source, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fsys, err := load(source, gzip.BestCompression, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testHandler(t, fsys)
+
+	watched, err := load(source, gzip.BestCompression, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testHandler(t, watched)
+}
+
+func TestLoad_zip(t *testing.T) {
+	source := filepath.Join(t.TempDir(), "site.zip")
+	f, err := os.Create(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	z := zip.NewWriter(f)
+	for name, content := range files {
+		w, err := z.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(w, content)
+	}
+	if err := z.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	fsys, err := load(source, gzip.BestCompression, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testHandler(t, fsys)
+
+	if _, err := load(source, gzip.BestCompression, true); err == nil {
+		t.Error("watch zip: want error")
+	}
+}
+
+func TestLoad_invalid(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := load(filepath.Join(dir, "missing"), gzip.BestCompression, false); err == nil {
+		t.Error("missing: want error")
+	}
+
+	source := filepath.Join(dir, "site.tar")
+	if err := os.WriteFile(source, nil, 0666); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := load(source, gzip.BestCompression, false); err == nil {
+		t.Error("tar: want error")
+	}
+}
+
+func testHandler(t *testing.T, h http.Handler) {
+	t.Helper()
+	tests := []struct {
+		path     string
+		encoding string
+		status   int
+		body     string
+		location string
+	}{
+		{path: "/", status: http.StatusOK, body: files["index.html"]},
+		{path: "/", encoding: "gzip", status: http.StatusOK, body: files["index.html"]},
+		{path: "/index.html", status: http.StatusMovedPermanently, location: "./"},
+		{path: "/css/site.css", status: http.StatusOK, body: files["css/site.css"]},
+		{path: "/docs", status: http.StatusMovedPermanently, location: "docs/"},
+		{path: "/missing.html", status: http.StatusNotFound, body: files["404.html"]},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", tt.path, nil)
+		r.Header.Set("Accept-Encoding", tt.encoding)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if w.Code != tt.status {
+			t.Errorf("%s: got status %d, want %d", tt.path, w.Code, tt.status)
+		}
+		if got := w.Header().Get("Location"); got != tt.location {
+			t.Errorf("%s: got location %q, want %q", tt.path, got, tt.location)
+		}
+		if tt.body == "" {
+			continue
+		}
+		body := io.Reader(w.Body)
+		if w.Header().Get("Content-Encoding") == "gzip" {
+			z, err := gzip.NewReader(body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body = z
+		} else if tt.encoding == "gzip" {
+			t.Errorf("%s: not compressed", tt.path)
+		}
+		if got, err := io.ReadAll(body); err != nil || string(got) != tt.body {
+			t.Errorf("%s: got %q, %v", tt.path, got, err)
+		}
+	}
+
+	// revalidation
+	r := httptest.NewRequest("GET", "/css/site.css", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	etag := w.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("no ETag")
+	}
+	r = httptest.NewRequest("GET", "/css/site.css", nil)
+	r.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusNotModified {
+		t.Errorf("If-None-Match: got status %d, want %d", w.Code, http.StatusNotModified)
+	}
+}
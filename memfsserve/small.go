@@ -0,0 +1,17 @@
+//go:build tinygo || memfs_small
+
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/ncruces/go-fs/memfs"
+)
+
+// Watching isn't available in small builds:
+// the source directory is loaded once.
+func watch(source string, level int) (*memfs.FileSystem, error) {
+	log.Print("can't watch in this build, loading once")
+	return memfs.LoadCompressed(os.DirFS(source), level)
+}
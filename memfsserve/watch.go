@@ -0,0 +1,18 @@
+//go:build !tinygo && !memfs_small
+
+package main
+
+import (
+	"log"
+
+	"github.com/ncruces/go-fs/memfs"
+)
+
+// watch loads the source directory, compressed with level,
+// and reloads it as files change.
+func watch(source string, level int) (*memfs.FileSystem, error) {
+	fsys, _, err := memfs.Watch(source, memfs.WatchCompressed(level), memfs.WatchErrors(func(err error) {
+		log.Print(err)
+	}))
+	return fsys, err
+}